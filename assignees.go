@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// toggleAssignee assigns email to issue, or unassigns them if they already
+// are.
+func toggleAssignee(issue Issue, email string) Issue {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return issue
+	}
+
+	if i := slices.Index(issue.Assignees, email); i >= 0 {
+		issue.Assignees = slices.Delete(slices.Clone(issue.Assignees), i, i+1)
+	} else {
+		issue.Assignees = append(slices.Clone(issue.Assignees), email)
+	}
+	return issue
+}
+
+// AssigneeFilter narrows the issue list to issues with an assignee
+// containing the term, e.g. "assignee:alice". "assignee:me" is expanded to
+// the current git identity before filtering; see issueFilter.
+func AssigneeFilter(term string, targets []string) []list.Rank {
+	assignee := strings.ToLower(strings.TrimPrefix(term, "assignee:"))
+
+	var ranks []list.Rank
+	for i, t := range targets {
+		assigneesPart := strings.Split(t, "\n")[4]
+		for _, field := range strings.Fields(assigneesPart) {
+			if strings.Contains(strings.ToLower(field), assignee) {
+				ranks = append(ranks, list.Rank{Index: i})
+				break
+			}
+		}
+	}
+
+	return ranks
+}
+
+// issueFilter is CustomFilter with the terms that depend on who is looking
+// filled in.
+func (m Model) issueFilter() list.FilterFunc {
+	var email string
+	if m.gitConfig != nil {
+		email = m.gitConfig.User.Email
+	}
+
+	return func(term string, targets []string) []list.Rank {
+		return CustomFilter(expandFilterTerm(term, email), targets, m.workflow, m.fieldSchema)
+	}
+}
+
+// expandFilterTerm fills in the parts of a search that depend on who is
+// looking: "assignee:me" becomes the assignee filter for email.
+func expandFilterTerm(term, email string) string {
+	if email == "" {
+		return term
+	}
+	terms := strings.Fields(term)
+	for i, t := range terms {
+		if t == "assignee:me" {
+			terms[i] = "assignee:" + email
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+func (m Model) openAssignInput() (Model, tea.Cmd) {
+	m.assignInput = textinput.New()
+	m.assignInput.Prompt = "email: "
+	m.assignInput.Width = 50
+	m.assignInput.SetValue(m.gitConfig.User.Email)
+	m.underlayPath = m.path
+	m.path = issuesAssignPath
+	return m, m.assignInput.Focus()
+}
+
+func issuesAssignHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Submit):
+			m.path = m.underlayPath
+			m.underlayPath = 0
+			currentIssue := toggleAssignee(m.issueIndex.SelectedItem().(Issue), m.assignInput.Value())
+			return m, persistIssue(currentIssue, m.store)
+		case key.Matches(msg, keys.Back):
+			m.path = m.underlayPath
+			m.underlayPath = 0
+			return m, nil
+		}
+	}
+
+	m.assignInput, cmd = m.assignInput.Update(msg)
+	return m, cmd
+}
+
+func renderAssignees(assignees []string) string {
+	if len(assignees) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("assigned to %s", strings.Join(assignees, ", "))
+}
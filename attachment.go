@@ -0,0 +1,314 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Attachment points at a file stored as a git blob. The blob is kept
+// reachable by listing it, named by its hash, in an attachments/ tree next to
+// the issue record, so it travels with the issue on sync and goes away with
+// it on gc.
+type Attachment struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type"`
+	Hash     string `json:"hash"`
+}
+
+const (
+	attachmentsDir    = "attachments"
+	maxAttachmentSize = 25 << 20
+)
+
+var errAttachmentTooLarge = fmt.Errorf("attachments are limited to %s", formatBytes(maxAttachmentSize))
+
+// AttachmentStore keeps the contents of attached files.
+type AttachmentStore interface {
+	SaveAttachment(data []byte) (string, error)
+	ReadAttachment(hash string) ([]byte, error)
+}
+
+func (s *gitStore) SaveAttachment(data []byte) (string, error) {
+	hash, err := storeBlob(s.repo, data)
+	return hash.String(), err
+}
+
+func (s *gitStore) ReadAttachment(hash string) ([]byte, error) {
+	blob, err := s.repo.BlobObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, err
+	}
+	return readBlob(blob)
+}
+
+func (s *memoryStore) SaveAttachment(data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := plumbing.ComputeHash(plumbing.BlobObject, data).String()
+	s.attachments[hash] = slices.Clone(data)
+	return hash, nil
+}
+
+func (s *memoryStore) ReadAttachment(hash string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.attachments[hash]
+	if !ok {
+		return nil, plumbing.ErrObjectNotFound
+	}
+	return slices.Clone(data), nil
+}
+
+// attachFile reads the file at path into the store.
+func attachFile(store AttachmentStore, path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if info.IsDir() {
+		return Attachment{}, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxAttachmentSize {
+		return Attachment{}, fmt.Errorf("%s: %w", path, errAttachmentTooLarge)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	hash, err := store.SaveAttachment(data)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	name := filepath.Base(path)
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	return Attachment{Name: name, Size: int64(len(data)), MimeType: mimeType, Hash: hash}, nil
+}
+
+// allAttachments returns the issue's attachments followed by its comments',
+// in the order issueShow numbers them.
+func (i Issue) allAttachments() []Attachment {
+	attachments := slices.Clone(i.Attachments)
+	for _, comment := range i.Comments {
+		attachments = append(attachments, comment.Attachments...)
+	}
+	return attachments
+}
+
+// extractAttachment writes an attachment into dir without overwriting
+// anything, and returns the path it was written to.
+func extractAttachment(store AttachmentStore, attachment Attachment, dir string) (string, error) {
+	data, err := store.ReadAttachment(attachment.Hash)
+	if err != nil {
+		return "", err
+	}
+
+	// names come from other people's records, so never let them pick the
+	// directory
+	name := filepath.Base(filepath.Clean("/" + attachment.Name))
+	if name == "/" || name == "." {
+		name = attachment.Hash
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for n := 0; ; n++ {
+		path := filepath.Join(dir, name)
+		if n > 0 {
+			path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", base, n, ext))
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return path, err
+	}
+}
+
+func mergeAttachments(ours, theirs []Attachment) []Attachment {
+	merged := slices.Clone(ours)
+	for _, attachment := range theirs {
+		if !slices.ContainsFunc(merged, func(a Attachment) bool { return a.Hash == attachment.Hash }) {
+			merged = append(merged, attachment)
+		}
+	}
+	return merged
+}
+
+// attachmentHashes lists the blobs an issue refers to.
+func attachmentHashes(issues ...Issue) []plumbing.Hash {
+	var hashes []plumbing.Hash
+	for _, issue := range issues {
+		for _, attachment := range issue.allAttachments() {
+			hashes = append(hashes, plumbing.NewHash(attachment.Hash))
+		}
+	}
+	return hashes
+}
+
+// treeAttachmentHashes lists the blobs in a record tree's attachments/
+// subtree.
+func treeAttachmentHashes(tree *object.Tree) []plumbing.Hash {
+	subtree, err := tree.Tree(attachmentsDir)
+	if err != nil {
+		return nil
+	}
+
+	var hashes []plumbing.Hash
+	for _, entry := range subtree.Entries {
+		hashes = append(hashes, entry.Hash)
+	}
+	return hashes
+}
+
+// writeAttachmentTree stores a tree holding the given blobs, named by hash.
+func writeAttachmentTree(repo *git.Repository, hashes []plumbing.Hash) (plumbing.Hash, error) {
+	tree := &object.Tree{}
+	for _, hash := range hashes {
+		name := hash.String()
+		if slices.ContainsFunc(tree.Entries, func(e object.TreeEntry) bool { return e.Name == name }) {
+			continue
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: hash})
+	}
+	slices.SortFunc(tree.Entries, func(a, b object.TreeEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return storeObject(repo, tree)
+}
+
+func renderAttachments(attachments []Attachment, offset int) string {
+	var b strings.Builder
+	faint := lipgloss.NewStyle().Foreground(styles.Theme.FaintText)
+	for i, attachment := range attachments {
+		b.WriteString(fmt.Sprintf("📎 [%d] %s %s\n", offset+i+1, attachment.Name,
+			faint.Render(fmt.Sprintf("(%s, %s)", formatBytes(attachment.Size), attachment.MimeType))))
+	}
+	return b.String()
+}
+
+type attachmentsExtractedMsg struct {
+	Paths []string
+	Err   error
+}
+
+type attachmentFailedMsg struct {
+	Err error
+}
+
+// extractAttachments writes attachments into the current directory.
+func extractAttachments(attachments []Attachment, store AttachmentStore) tea.Cmd {
+	return func() tea.Msg {
+		var paths []string
+		for _, attachment := range attachments {
+			path, err := extractAttachment(store, attachment, ".")
+			if err != nil {
+				debug("%#v", err.Error())
+				return attachmentsExtractedMsg{Paths: paths, Err: err}
+			}
+			paths = append(paths, path)
+		}
+		return attachmentsExtractedMsg{Paths: paths}
+	}
+}
+
+// extractSelectedAttachments extracts the attachments of the comment under
+// the cursor, or the issue's only attachment. When there's more than one to
+// choose from, it asks which by the number issueShow gives it.
+func (m Model) extractSelectedAttachments() (Model, tea.Cmd) {
+	if issue, i, ok := m.selectedComment(); ok {
+		attachments := issue.Comments[i].Attachments
+		if len(attachments) == 0 {
+			return m, nil
+		}
+		return m, extractAttachments(attachments, m.store)
+	}
+
+	attachments := m.issueShow.issue.allAttachments()
+	switch len(attachments) {
+	case 0:
+		return m, nil
+	case 1:
+		return m, extractAttachments(attachments, m.store)
+	}
+
+	m.extractInput = textinput.New()
+	m.extractInput.Prompt = fmt.Sprintf("attachment (1-%d): ", len(attachments))
+	m.extractInput.Width = 10
+	m.underlayPath = m.path
+	m.path = issuesExtractPath
+	return m, m.extractInput.Focus()
+}
+
+func issuesExtractHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Submit):
+			m.path = issuesShowPath
+			m.underlayPath = 0
+			attachments := m.issueShow.issue.allAttachments()
+			n, err := strconv.Atoi(strings.TrimSpace(m.extractInput.Value()))
+			if err != nil || n < 1 || n > len(attachments) {
+				m.flash = fmt.Sprintf("no attachment [%s]", strings.TrimSpace(m.extractInput.Value()))
+				m.UpdateLayout(m.layout.TerminalSize)
+				return m, nil
+			}
+			return m, extractAttachments(attachments[n-1:n], m.store)
+		case key.Matches(msg, keys.Back):
+			m.path = issuesShowPath
+			m.underlayPath = 0
+			return m, nil
+		}
+	}
+
+	m.extractInput, cmd = m.extractInput.Update(msg)
+	return m, cmd
+}
+
+func attachToIssue(issue Issue, path string, store Store) tea.Cmd {
+	return func() tea.Msg {
+		attachment, err := attachFile(store, expandHome(strings.TrimSpace(path)))
+		if err != nil {
+			debug("%#v", err.Error())
+			return attachmentFailedMsg{Err: err}
+		}
+		issue.Attachments = append(issue.Attachments, attachment)
+		return persistIssue(issue, store)()
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestAttachmentsKeptReachable(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+	cfg := testGitConfig("alice@example.com")
+
+	stores := map[string]Store{
+		"refs":   newGitStore(repo, cfg),
+		"packed": &packedStore{gitStore: newGitStore(repo, cfg)},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			attachment, err := attachFile(store, writeTestFile(t, "crash.txt", "panic: "+name))
+			require.NoError(t, err)
+			assert.Equal(t, "crash.txt", attachment.Name)
+			assert.Equal(t, int64(len("panic: "+name)), attachment.Size)
+			assert.Contains(t, attachment.MimeType, "text/plain")
+
+			issue := Issue{Id: "issue-" + name, Title: "Crash", Attachments: []Attachment{attachment}}
+			require.NoError(t, store.SaveIssue(issue))
+
+			var tip plumbing.ReferenceName = packedIndexRef
+			if name == "refs" {
+				tip = issueRefName(issue.Id)
+			}
+			ref, err := repo.Reference(tip, true)
+			require.NoError(t, err)
+			commit, err := repo.CommitObject(ref.Hash())
+			require.NoError(t, err)
+			tree, err := commit.Tree()
+			require.NoError(t, err)
+			assert.Equal(t, []plumbing.Hash{plumbing.NewHash(attachment.Hash)}, treeAttachmentHashes(tree))
+
+			issues, err := store.Issues()
+			require.NoError(t, err)
+			require.NotEmpty(t, issues)
+			data, err := store.ReadAttachment(issues[0].Attachments[0].Hash)
+			require.NoError(t, err)
+			assert.Equal(t, "panic: "+name, string(data))
+
+			require.NoError(t, store.PurgeIssue(issue.Id))
+			if name == "packed" {
+				ref, err := repo.Reference(packedIndexRef, true)
+				require.NoError(t, err)
+				commit, err := repo.CommitObject(ref.Hash())
+				require.NoError(t, err)
+				tree, err := commit.Tree()
+				require.NoError(t, err)
+				assert.Empty(t, treeAttachmentHashes(tree))
+			}
+		})
+	}
+}
+
+func TestExtractAttachment(t *testing.T) {
+	store := newMemoryStore()
+	attachment, err := attachFile(store, writeTestFile(t, "notes.txt", "hello"))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	first, err := extractAttachment(store, attachment, dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "notes.txt"), first)
+
+	second, err := extractAttachment(store, attachment, dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "notes-1.txt"), second, "existing files are never overwritten")
+
+	attachment.Name = "../../escape.txt"
+	escaped, err := extractAttachment(store, attachment, dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "escape.txt"), escaped)
+
+	data, err := os.ReadFile(escaped)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
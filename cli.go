@@ -0,0 +1,371 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+)
+
+const usage = `usage: ubik [command]
+
+Run without a command to open the tracker.
+
+commands:
+  sync [remote]    fetch, merge and push issues and actions
+  migrate          upgrade stored issues and actions to the current schema
+                   (--dry-run to only report what would change,
+                   --layout packed to move issues into a single tree)
+  gc               prune old action runs and purge deleted issues
+                   (--dry-run to only report, --action-retention and
+                   --issue-grace take durations like 720h or 30d)
+  attach <issue> <file>...
+                   attach files to an issue, or to its nth comment with
+                   --comment n; <issue> is any unique prefix of a shortcode
+  import <file>    import issues from a GitHub JSON, GitLab project or Jira
+                   CSV export (--from github|gitlab|jira if it can't tell,
+                   --user name=email to map people, --dry-run to only
+                   report); importing again updates what changed
+  export           write issues out as --format json, csv or markdown
+                   (--filter takes the issue list's search syntax,
+                   --comments includes comments, --output a file instead
+                   of stdout)
+  site build <dir> render issues and action results as a static HTML site
+                   in <dir>
+`
+
+// runCommand dispatches the non-interactive subcommands.
+func runCommand(args []string) error {
+	switch args[0] {
+	case "sync":
+		return syncCommand(args[1:])
+	case "migrate":
+		return migrateCommand(args[1:])
+	case "gc":
+		return gcCommand(args[1:])
+	case "attach":
+		return attachCommand(args[1:])
+	case "import":
+		return importCommand(args[1:])
+	case "export":
+		return exportCommand(args[1:])
+	case "site":
+		return siteCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+}
+
+func openRepository() (*git.Repository, *config.Config, error) {
+	repo, err := git.PlainOpen(".")
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := repo.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, cfg, nil
+}
+
+// readRepoConfig reads the workflow and custom fields the repo defines in
+// .ubik/, for the commands that show or take statuses and fields.
+func readRepoConfig(repo *git.Repository) (Workflow, FieldSchema, error) {
+	w, err := readWorkflow(repoConfigPath(repo, workflowPath))
+	if err != nil {
+		return w, FieldSchema{}, fmt.Errorf("%s: %w", workflowPath, err)
+	}
+	schema, err := readFieldSchema(repoConfigPath(repo, fieldsPath))
+	if err != nil {
+		return w, schema, fmt.Errorf("%s: %w", fieldsPath, err)
+	}
+	return w, schema, nil
+}
+
+func syncCommand(args []string) error {
+	repo, cfg, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	remote := syncRemoteName(repo)
+	if len(args) > 0 {
+		remote = args[0]
+	}
+
+	report, err := syncRepo(repo, remote, gitSignature(cfg), loadSigner(cfg))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, report)
+	return nil
+}
+
+func migrateCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be migrated without writing anything")
+	layout := flags.String("layout", "", "move issues into the given storage layout (packed)")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	repo, cfg, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	if *layout != "" {
+		if *layout != layoutPacked {
+			return fmt.Errorf("unsupported layout %q", *layout)
+		}
+		if storageLayout(repo) == layoutPacked {
+			return fmt.Errorf("issues already use the %s layout", layoutPacked)
+		}
+		if *dryRun {
+			issues, err := newGitStore(repo, cfg).Issues()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "would pack %d issue(s)\n", len(issues))
+			return nil
+		}
+		count, err := packIssues(repo, cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "packed %d issue(s) into %s\n", count, packedIndexRef)
+		return nil
+	}
+
+	report, err := migrateRepo(repo, gitSignature(cfg), loadSigner(cfg), *dryRun)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, report)
+	return nil
+}
+
+func gcCommand(args []string) error {
+	opts := defaultGCOptions()
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "report what would be removed without deleting anything")
+	flags.Func("action-retention", "keep action runs finished within this duration (default 30d)", func(value string) error {
+		var err error
+		opts.ActionRetention, err = parseGCDuration(value)
+		return err
+	})
+	flags.Func("issue-grace", "keep deleted issues for this duration before purging (default 30d)", func(value string) error {
+		var err error
+		opts.IssueGracePeriod, err = parseGCDuration(value)
+		return err
+	})
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	repo, cfg, err := openRepository()
+	if err != nil {
+		return err
+	}
+
+	report, err := collectGarbage(repo, openStore(repo, cfg), opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, report)
+	return nil
+}
+
+func attachCommand(args []string) error {
+	flags := flag.NewFlagSet("attach", flag.ContinueOnError)
+	commentNumber := flags.Int("comment", 0, "attach to the nth comment instead of the issue")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		return fmt.Errorf("usage: ubik attach [--comment n] <issue> <file>...")
+	}
+
+	repo, cfg, err := openRepository()
+	if err != nil {
+		return err
+	}
+	store := openStore(repo, cfg)
+
+	issues, err := store.Issues()
+	if err != nil {
+		return err
+	}
+	issue, err := resolveIssueRef(issues, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *commentNumber < 0 || *commentNumber > len(issue.Comments) {
+		return fmt.Errorf("#%s has %d comment(s)", issue.Shortcode, len(issue.Comments))
+	}
+	if *commentNumber > 0 && issue.Comments[*commentNumber-1].Author != cfg.User.Email {
+		return fmt.Errorf("comment %d on #%s isn't yours", *commentNumber, issue.Shortcode)
+	}
+
+	for _, path := range flags.Args()[1:] {
+		attachment, err := attachFile(store, path)
+		if err != nil {
+			return err
+		}
+		if *commentNumber > 0 {
+			comment := &issue.Comments[*commentNumber-1]
+			comment.Attachments = append(comment.Attachments, attachment)
+		} else {
+			issue.Attachments = append(issue.Attachments, attachment)
+		}
+		fmt.Fprintf(os.Stdout, "attached %s (%s) to #%s\n", attachment.Name, formatBytes(attachment.Size), issue.Shortcode)
+	}
+
+	issue.UpdatedAt = time.Now().UTC()
+	return store.SaveIssue(issue)
+}
+
+func importCommand(args []string) error {
+	users := userMap{}
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	from := flags.String("from", "", "the tracker the export is from: github, gitlab or jira")
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without saving anything")
+	flags.Func("user", "map a person in the export to an email, name=email (repeatable)", users.parseUserMapping)
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: ubik import [--from github|gitlab|jira] [--user name=email]... [--dry-run] <file>")
+	}
+
+	importer, err := findImporter(*from, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	repo, cfg, err := openRepository()
+	if err != nil {
+		return err
+	}
+	w, _, err := readRepoConfig(repo)
+	if err != nil {
+		return err
+	}
+
+	issues, err := importer.read(flags.Arg(0), users, w)
+	if err != nil {
+		return err
+	}
+	report, err := importIssues(openStore(repo, cfg), issues, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, report)
+	return nil
+}
+
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "json", "the format to write: "+strings.Join(exportFormats(), ", "))
+	filter := flags.String("filter", "", "only export issues matching this search, e.g. \"label:bug status:todo\"")
+	withComments := flags.Bool("comments", false, "include comments")
+	output := flags.String("output", "", "write to this file instead of stdout")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("usage: ubik export [--format %s] [--filter search] [--comments] [--output file]", strings.Join(exportFormats(), "|"))
+	}
+	exporter, ok := issueExporters[*format]
+	if !ok {
+		return fmt.Errorf("unknown export format %q; use %s", *format, strings.Join(exportFormats(), ", "))
+	}
+
+	repo, cfg, err := openRepository()
+	if err != nil {
+		return err
+	}
+	w, schema, err := readRepoConfig(repo)
+	if err != nil {
+		return err
+	}
+
+	issues, err := exportableIssues(openStore(repo, cfg), w)
+	if err != nil {
+		return err
+	}
+	issues = filterIssues(issues, *filter, cfg.User.Email, w, schema)
+	exported := convertSlice(issues, func(issue Issue) exportedIssue { return exportIssue(issue, *withComments) })
+
+	if *output == "" {
+		return exporter(os.Stdout, exported, schema, *withComments)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = exporter(file, exported, schema, *withComments)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d issue(s) to %s\n", len(exported), *output)
+	return nil
+}
+
+func siteCommand(args []string) error {
+	if len(args) != 2 || args[0] != "build" {
+		return fmt.Errorf("usage: ubik site build <dir>")
+	}
+	dir := args[1]
+
+	repo, cfg, err := openRepository()
+	if err != nil {
+		return err
+	}
+	w, schema, err := readRepoConfig(repo)
+	if err != nil {
+		return err
+	}
+
+	store := openStore(repo, cfg)
+	issues, err := exportableIssues(store, w)
+	if err != nil {
+		return err
+	}
+	commits, err := loadCommits(repo, store)
+	if err != nil {
+		return fmt.Errorf("read commits: %w", err)
+	}
+	issues, linked := linkCommits(issues, commits)
+
+	title := filepath.Base(repoConfigPath(repo, ""))
+	report, err := buildSite(dir, title, issues, linked, store, w, schema, time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, report)
+	return nil
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Comments can be edited or deleted by whoever wrote them, answered with a
+// reply, and reacted to. Replies are one level deep: answering a reply adds
+// to its parent's thread. Deleted comments stay behind as tombstones so that
+// merging with a copy that still has them doesn't bring them back.
+
+// reactionEmoji are the emoji offered by the reaction picker.
+var reactionEmoji = []string{"👍", "👎", "😄", "🎉", "😕", "❤️", "🚀", "👀"}
+
+type threadedComment struct {
+	index int
+	reply bool
+}
+
+// threadComments lays comments out for display: each top-level comment
+// followed by its replies, oldest first. A reply whose parent is missing, or
+// is itself a reply, is shown at the top level.
+func threadComments(comments []Comment) []threadedComment {
+	topLevel := make(map[string]bool)
+	for _, comment := range comments {
+		if comment.ReplyTo == "" {
+			topLevel[commentKey(comment)] = true
+		}
+	}
+
+	var threaded []threadedComment
+	for i, comment := range comments {
+		if topLevel[comment.ReplyTo] {
+			continue
+		}
+		threaded = append(threaded, threadedComment{index: i})
+		if comment.ReplyTo != "" {
+			continue
+		}
+		key := commentKey(comment)
+		for j, reply := range comments {
+			if reply.ReplyTo == key {
+				threaded = append(threaded, threadedComment{index: j, reply: true})
+			}
+		}
+	}
+
+	return threaded
+}
+
+func findComment(comments []Comment, key string) int {
+	if key == "" {
+		return -1
+	}
+	return slices.IndexFunc(comments, func(c Comment) bool { return commentKey(c) == key })
+}
+
+// toggleReaction adds user's emoji reaction to comment, or takes it back.
+func toggleReaction(comment Comment, emoji, user string) Comment {
+	reactions := maps.Clone(comment.Reactions)
+	if reactions == nil {
+		reactions = make(map[string][]string)
+	}
+
+	users := reactions[emoji]
+	if i := slices.Index(users, user); i >= 0 {
+		users = slices.Delete(slices.Clone(users), i, i+1)
+	} else {
+		users = append(slices.Clone(users), user)
+	}
+
+	if len(users) == 0 {
+		delete(reactions, emoji)
+	} else {
+		reactions[emoji] = users
+	}
+	if len(reactions) == 0 {
+		reactions = nil
+	}
+
+	comment.Reactions = reactions
+	return comment
+}
+
+// mergeReactions merges each emoji's users like labels, so concurrent
+// reactions from different people are all kept.
+func mergeReactions(base, ours, theirs map[string][]string) map[string][]string {
+	merged := make(map[string][]string)
+	for emoji := range maps.Keys(ours) {
+		merged[emoji] = nil
+	}
+	for emoji := range maps.Keys(theirs) {
+		merged[emoji] = nil
+	}
+	for emoji := range merged {
+		users := mergeLabels(base[emoji], ours[emoji], theirs[emoji])
+		if len(users) == 0 {
+			delete(merged, emoji)
+			continue
+		}
+		merged[emoji] = users
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+func renderReactions(commentReactions map[string][]string) string {
+	emoji := slices.Sorted(maps.Keys(commentReactions))
+	slices.SortStableFunc(emoji, func(a, b string) int {
+		return reactionRank(a) - reactionRank(b)
+	})
+
+	var counts []string
+	for _, e := range emoji {
+		counts = append(counts, fmt.Sprintf("%s %d", e, len(commentReactions[e])))
+	}
+	return lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render(strings.Join(counts, "  "))
+}
+
+// reactionRank puts the picker's emoji first, in picker order, and anything
+// else after them.
+func reactionRank(emoji string) int {
+	if i := slices.Index(reactionEmoji, emoji); i >= 0 {
+		return i
+	}
+	return len(reactionEmoji)
+}
+
+func renderComment(comment Comment, width, attachmentOffset int, reply, selected, raw bool) string {
+	style := commentContentStyle
+	verb := "commented"
+	if reply {
+		style = style.MarginLeft(4)
+		verb = "replied"
+	}
+	if selected {
+		style = style.BorderForeground(styles.Theme.PrimaryText)
+	}
+	frameX, _ := style.GetFrameSize()
+	w := lipgloss.NewStyle().Width(width - frameX)
+	faint := lipgloss.NewStyle().Foreground(styles.Theme.FaintText)
+
+	header := fmt.Sprintf("%s %s at %s", comment.Author, verb, comment.CreatedAt.Format(time.RFC822))
+	if selected {
+		header = "▸ " + header
+	}
+	if comment.Deleted {
+		header = commentHeaderStyle.Inherit(w).Render(header)
+		return style.Inherit(w).Render(fmt.Sprintf("%s\n%s\n", header, faint.Italic(true).Render("comment deleted")))
+	}
+	header = fmt.Sprintf("%s %s", header, verificationBadge(comment.Verified, comment.Signature))
+	if comment.Edited {
+		header = fmt.Sprintf("%s %s", header, faint.Render("(edited)"))
+	}
+	header = commentHeaderStyle.Inherit(w).Render(header)
+
+	content := comment.Content
+	if !raw {
+		content = renderMarkdown(content, width-frameX)
+	}
+	if len(comment.Attachments) > 0 {
+		content = fmt.Sprintf("%s\n\n%s", content, strings.TrimSuffix(renderAttachments(comment.Attachments, attachmentOffset), "\n"))
+	}
+	if len(comment.Reactions) > 0 {
+		content = fmt.Sprintf("%s\n\n%s", content, renderReactions(comment.Reactions))
+	}
+	return style.Inherit(w).Render(fmt.Sprintf("%s\n%s\n", header, content))
+}
+
+// commentChanges describes what happened to an issue's comments between two
+// revisions for the history view.
+func commentChanges(before, after []Comment) []string {
+	var changes []string
+	var added int
+	for _, comment := range after {
+		i := findComment(before, commentKey(comment))
+		switch {
+		case i < 0:
+			added++
+		case comment.Deleted && !before[i].Deleted:
+			changes = append(changes, fmt.Sprintf("comment by %s deleted", comment.Author))
+		case comment.Content != before[i].Content && !comment.Deleted:
+			changes = append(changes, fmt.Sprintf("comment by %s edited", comment.Author))
+		}
+	}
+	if added > 0 {
+		changes = append([]string{fmt.Sprintf("%d comment(s) added", added)}, changes...)
+	}
+	return changes
+}
+
+// selectComment highlights the comment with the given key on the shown
+// issue and scrolls to it.
+func (m Model) selectComment(key string) issueShow {
+	show := m.buildIssueShow(m.issueShow.issue, key, m.issueShow.raw)
+	show.viewport.SetYOffset(show.commentOffsets[key])
+	return show
+}
+
+// selectedComment finds the comment under the cursor on the selected issue.
+func (m Model) selectedComment() (Issue, int, bool) {
+	selectedItem := m.issueIndex.SelectedItem()
+	if selectedItem == nil || m.issueShow.selectedComment == "" {
+		return Issue{}, -1, false
+	}
+	issue := selectedItem.(Issue)
+	i := findComment(issue.Comments, m.issueShow.selectedComment)
+	return issue, i, i >= 0
+}
+
+func (m Model) moveCommentCursor(delta int) Model {
+	threaded := threadComments(m.issueShow.issue.Comments)
+	if len(threaded) == 0 {
+		return m
+	}
+
+	position := slices.IndexFunc(threaded, func(t threadedComment) bool {
+		return commentKey(m.issueShow.issue.Comments[t.index]) == m.issueShow.selectedComment
+	})
+	switch {
+	case position < 0 && delta > 0:
+		position = 0
+	case position < 0:
+		position = len(threaded) - 1
+	default:
+		position = clamp(position+delta, 0, len(threaded)-1)
+	}
+
+	m.issueShow = m.selectComment(commentKey(m.issueShow.issue.Comments[threaded[position].index]))
+	return m
+}
+
+// ownSelectedComment is selectedComment for the actions only a comment's
+// author can take, flashing when the comment isn't theirs to change.
+func (m *Model) ownSelectedComment() (Issue, int, bool) {
+	issue, i, ok := m.selectedComment()
+	if !ok {
+		return issue, i, false
+	}
+	comment := issue.Comments[i]
+	switch {
+	case comment.Deleted:
+		m.flash = "that comment was deleted"
+	case comment.Author != m.gitConfig.User.Email:
+		m.flash = "that comment isn't yours"
+	default:
+		return issue, i, true
+	}
+	m.UpdateLayout(m.layout.TerminalSize)
+	return issue, i, false
+}
+
+// openCommentForm focuses the comment form for a new comment, a reply to
+// the selected comment's thread, or an edit of it.
+func (m Model) openCommentForm(editing, replyTo string, content string) (Model, tea.Cmd) {
+	m.commentForm = newCommentForm()
+	m.commentForm.editing = editing
+	m.commentForm.replyTo = replyTo
+	m.commentForm.contentInput.SetValue(content)
+	m.path = issuesCommentContentPath
+	m.UpdateLayout(m.layout.TerminalSize)
+	if selected := m.issueShow.selectedComment; selected != "" {
+		m.issueShow = m.selectComment(selected)
+	}
+	return m, m.commentForm.Init()
+}
+
+func (m Model) editComment() (Model, tea.Cmd) {
+	issue, i, ok := m.ownSelectedComment()
+	if !ok {
+		return m, nil
+	}
+	comment := issue.Comments[i]
+	return m.openCommentForm(commentKey(comment), "", comment.Content)
+}
+
+func (m Model) replyToComment() (Model, tea.Cmd) {
+	issue, i, ok := m.selectedComment()
+	if !ok {
+		return m, nil
+	}
+	parent := issue.Comments[i]
+	if parent.Deleted {
+		m.flash = "that comment was deleted"
+		m.UpdateLayout(m.layout.TerminalSize)
+		return m, nil
+	}
+	// replies stay one level deep
+	if parent.ReplyTo != "" && findComment(issue.Comments, parent.ReplyTo) >= 0 {
+		return m.openCommentForm("", parent.ReplyTo, "")
+	}
+	return m.openCommentForm("", commentKey(parent), "")
+}
+
+// apply adds the form's comment to issue, or saves the edit it was opened
+// for.
+func (f commentForm) apply(issue Issue, author string) Issue {
+	issue.Comments = slices.Clone(issue.Comments)
+	content := f.contentInput.Value()
+
+	if i := findComment(issue.Comments, f.editing); i >= 0 {
+		issue.Comments[i].Content = content
+		issue.Comments[i].Edited = true
+		issue.Comments[i].UpdatedAt = time.Now().UTC()
+		return issue
+	}
+
+	issue.Comments = append(issue.Comments, Comment{
+		Author:  author,
+		Content: content,
+		ReplyTo: f.replyTo,
+	})
+	return issue
+}
+
+func (f commentForm) heading() string {
+	switch {
+	case f.editing != "":
+		return "Editing comment"
+	case f.replyTo != "":
+		author, _, _ := strings.Cut(f.replyTo, " ")
+		return fmt.Sprintf("Replying to %s", author)
+	default:
+		return ""
+	}
+}
+
+func issuesCommentDeleteHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.IssueConfirmDelete):
+			m.path = issuesShowPath
+			m.underlayPath = 0
+			issue, i, ok := m.ownSelectedComment()
+			if !ok {
+				return m, nil
+			}
+			issue.Comments = slices.Clone(issue.Comments)
+			comment := issue.Comments[i]
+			comment.Deleted = true
+			comment.Content = ""
+			comment.Attachments = nil
+			comment.Reactions = nil
+			comment.UpdatedAt = time.Now().UTC()
+			issue.Comments[i] = comment
+			return m, persistIssue(issue, m.store)
+		case key.Matches(msg, keys.Back):
+			m.path = issuesShowPath
+			m.underlayPath = 0
+			return m, cmd
+		}
+	}
+
+	return m, cmd
+}
+
+func (m Model) openReactionPicker() (Model, tea.Cmd) {
+	issue, i, ok := m.selectedComment()
+	if !ok {
+		return m, nil
+	}
+	if issue.Comments[i].Deleted {
+		m.flash = "that comment was deleted"
+		m.UpdateLayout(m.layout.TerminalSize)
+		return m, nil
+	}
+	m.reactionPicker = 0
+	m.underlayPath = m.path
+	m.path = issuesReactPath
+	return m, nil
+}
+
+func issuesReactHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.PickerPrev):
+			m.reactionPicker = (m.reactionPicker + len(reactionEmoji) - 1) % len(reactionEmoji)
+			return m, cmd
+		case key.Matches(msg, keys.PickerNext):
+			m.reactionPicker = (m.reactionPicker + 1) % len(reactionEmoji)
+			return m, cmd
+		case key.Matches(msg, keys.Submit):
+			m.path = issuesShowPath
+			m.underlayPath = 0
+			issue, i, ok := m.selectedComment()
+			if !ok {
+				return m, nil
+			}
+			issue.Comments = slices.Clone(issue.Comments)
+			issue.Comments[i] = toggleReaction(issue.Comments[i], reactionEmoji[m.reactionPicker], m.gitConfig.User.Email)
+			return m, persistIssue(issue, m.store)
+		case key.Matches(msg, keys.Back):
+			m.path = issuesShowPath
+			m.underlayPath = 0
+			return m, cmd
+		}
+	}
+
+	return m, cmd
+}
+
+func (m Model) reactionPickerView() string {
+	var options []string
+	for i, emoji := range reactionEmoji {
+		if i == m.reactionPicker {
+			options = append(options, lipgloss.NewStyle().Background(styles.Theme.SelectedBackground).Render(fmt.Sprintf(" %s ", emoji)))
+		} else {
+			options = append(options, fmt.Sprintf(" %s ", emoji))
+		}
+	}
+	return fmt.Sprintf("React to comment\n\n%s", strings.Join(options, " "))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToggleReaction(t *testing.T) {
+	comment := toggleReaction(Comment{}, "🎉", "a@example.com")
+	comment = toggleReaction(comment, "🎉", "b@example.com")
+	assert.Equal(t, map[string][]string{"🎉": {"a@example.com", "b@example.com"}}, comment.Reactions)
+
+	comment = toggleReaction(comment, "🎉", "a@example.com")
+	comment = toggleReaction(comment, "🎉", "b@example.com")
+	assert.Nil(t, comment.Reactions)
+}
+
+func TestMergeCommentEdits(t *testing.T) {
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	base := []Comment{{Author: "a@example.com", Content: "first", CreatedAt: created, UpdatedAt: created,
+		Reactions: map[string][]string{"👍": {"c@example.com"}}}}
+
+	ours := []Comment{toggleReaction(base[0], "👍", "a@example.com")}
+	ours[0].Content = "first, edited"
+	ours[0].Edited = true
+	ours[0].UpdatedAt = created.Add(time.Hour)
+	theirs := []Comment{toggleReaction(toggleReaction(base[0], "🚀", "b@example.com"), "👍", "c@example.com")}
+
+	merged := mergeComments(base, ours, theirs)
+	require.Len(t, merged, 1)
+	assert.Equal(t, "first, edited", merged[0].Content)
+	assert.Equal(t, map[string][]string{"👍": {"a@example.com"}, "🚀": {"b@example.com"}}, merged[0].Reactions)
+
+	theirs[0].Deleted = true
+	theirs[0].Content = ""
+	merged = mergeComments(base, ours, theirs)
+	assert.True(t, merged[0].Deleted, "a deletion wins over a later edit")
+}
+
+func TestCommentChanges(t *testing.T) {
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	before := []Comment{
+		{Author: "a@example.com", Content: "one", CreatedAt: created},
+		{Author: "b@example.com", Content: "two", CreatedAt: created.Add(time.Minute)},
+	}
+	after := []Comment{
+		{Author: "a@example.com", Content: "one!", CreatedAt: created, Edited: true},
+		{Author: "b@example.com", CreatedAt: created.Add(time.Minute), Deleted: true},
+		{Author: "a@example.com", Content: "three", CreatedAt: created.Add(time.Hour)},
+	}
+
+	assert.Equal(t, []string{
+		"1 comment(s) added",
+		"comment by a@example.com edited",
+		"comment by b@example.com deleted",
+	}, commentChanges(before, after))
+}
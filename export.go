@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// exportedIssue is an issue as `ubik export` writes it: what people read and
+// put in spreadsheets, without signatures, conflicts and the like.
+type exportedIssue struct {
+	Id          string            `json:"id"`
+	Shortcode   string            `json:"shortcode"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Status      issueStatus       `json:"status"`
+	Author      string            `json:"author"`
+	Labels      []string          `json:"labels"`
+	Assignees   []string          `json:"assignees"`
+	Milestone   string            `json:"milestone,omitempty"`
+	Priority    issuePriority     `json:"priority,omitempty"`
+	DueDate     string            `json:"due_date,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	Relations   []string          `json:"relations,omitempty"`
+	Imported    *ImportedFrom     `json:"imported,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Comments    []exportedComment `json:"comments,omitempty"`
+}
+
+type exportedComment struct {
+	Author    string    `json:"author"`
+	Content   string    `json:"content"`
+	ReplyTo   string    `json:"reply_to,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// exportIssue converts a linked issue for export. Deleted comments are left
+// out, and all comments are unless withComments is set.
+func exportIssue(issue Issue, withComments bool) exportedIssue {
+	exported := exportedIssue{
+		Id:          issue.Id,
+		Shortcode:   issue.Shortcode,
+		Title:       issue.Title,
+		Description: issue.Description,
+		Status:      issue.Status,
+		Author:      issue.Author,
+		Labels:      append([]string{}, issue.Labels...),
+		Assignees:   append([]string{}, issue.Assignees...),
+		Milestone:   issue.MilestoneTitle,
+		Priority:    issue.Priority,
+		DueDate:     formatDueDate(issue.DueDate),
+		Fields:      issue.Fields,
+		Imported:    issue.Imported,
+		CreatedAt:   issue.CreatedAt,
+		UpdatedAt:   issue.UpdatedAt,
+	}
+	for _, related := range issue.RelatedIssues {
+		exported.Relations = append(exported.Relations, fmt.Sprintf("%s #%s", related.Kind, related.Shortcode))
+	}
+	if withComments {
+		for _, comment := range issue.Comments {
+			if comment.Deleted {
+				continue
+			}
+			exported.Comments = append(exported.Comments, exportedComment{
+				Author:    comment.Author,
+				Content:   comment.Content,
+				ReplyTo:   comment.ReplyTo,
+				CreatedAt: comment.CreatedAt,
+				UpdatedAt: comment.UpdatedAt,
+			})
+		}
+	}
+	return exported
+}
+
+// exportableIssues loads the issues that aren't deleted, linked up and in
+// the order the issue list shows them under the workflow w.
+func exportableIssues(store Store, w Workflow) ([]Issue, error) {
+	stored, err := store.Issues()
+	if err != nil {
+		return nil, err
+	}
+	milestones, err := store.Milestones()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := slices.DeleteFunc(stored, func(issue Issue) bool { return !issue.DeletedAt.IsZero() })
+	issues, _ = linkMilestones(linkRelations(issues), milestones, w)
+	return SortIssues(issues, w), nil
+}
+
+// filterIssues keeps the issues that match term, in the search syntax of
+// the issue list (see CustomFilter), in their original order. email is who
+// "assignee:me" means.
+func filterIssues(issues []Issue, term, email string, w Workflow, schema FieldSchema) []Issue {
+	if strings.TrimSpace(term) == "" {
+		return issues
+	}
+	ranks := CustomFilter(expandFilterTerm(term, email), convertSlice(issues, Issue.FilterValue), w, schema)
+	indexes := convertSlice(ranks, func(rank list.Rank) int { return rank.Index })
+	slices.Sort(indexes)
+	return convertSlice(indexes, func(i int) Issue { return issues[i] })
+}
+
+// issueExporters write issues out in each of the formats `ubik export`
+// supports. schema gives the custom fields to write out; withComments says
+// whether comments were asked for, for formats that need to know even when
+// there aren't any.
+var issueExporters = map[string]func(w io.Writer, issues []exportedIssue, schema FieldSchema, withComments bool) error{
+	"json":     exportJSON,
+	"csv":      exportCSV,
+	"markdown": exportMarkdown,
+}
+
+func exportFormats() []string {
+	return slices.Sorted(maps.Keys(issueExporters))
+}
+
+func exportJSON(w io.Writer, issues []exportedIssue, _ FieldSchema, _ bool) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(append([]exportedIssue{}, issues...))
+}
+
+// exportCSV writes a row per issue, with a column per custom field. Lists
+// are joined with spaces like in the issue form; comments all go in the last
+// column.
+func exportCSV(w io.Writer, issues []exportedIssue, schema FieldSchema, withComments bool) error {
+	header := []string{"shortcode", "id", "title", "status", "author", "labels", "assignees", "milestone", "priority", "due_date", "created_at", "updated_at", "description"}
+	for _, field := range schema.Fields {
+		header = append(header, field.Name)
+	}
+	if withComments {
+		header = append(header, "comments")
+	}
+
+	writer := csv.NewWriter(w)
+	err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		row := []string{
+			issue.Shortcode,
+			issue.Id,
+			issue.Title,
+			string(issue.Status),
+			issue.Author,
+			strings.Join(issue.Labels, " "),
+			strings.Join(issue.Assignees, " "),
+			issue.Milestone,
+			string(issue.Priority),
+			issue.DueDate,
+			issue.CreatedAt.Format(time.RFC3339),
+			issue.UpdatedAt.Format(time.RFC3339),
+			issue.Description,
+		}
+		for _, field := range schema.Fields {
+			row = append(row, issue.Fields[field.Name])
+		}
+		if withComments {
+			var comments []string
+			for _, comment := range issue.Comments {
+				comments = append(comments, fmt.Sprintf("%s on %s:\n%s", comment.Author, comment.CreatedAt.Format(time.RFC3339), comment.Content))
+			}
+			row = append(row, strings.Join(comments, "\n\n"))
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportMarkdown writes a document with a section per issue, ready to paste
+// into a report.
+func exportMarkdown(w io.Writer, issues []exportedIssue, schema FieldSchema, _ bool) error {
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("# Issues\n\n%d issue(s)\n", len(issues)))
+
+	for _, issue := range issues {
+		s.WriteString(fmt.Sprintf("\n## #%s %s\n\n", issue.Shortcode, issue.Title))
+
+		details := [][2]string{
+			{"Status", string(issue.Status)},
+			{"Author", issue.Author},
+			{"Opened", issue.CreatedAt.Format(time.DateOnly)},
+			{"Labels", strings.Join(issue.Labels, ", ")},
+			{"Assignees", strings.Join(issue.Assignees, ", ")},
+			{"Milestone", issue.Milestone},
+			{"Priority", string(issue.Priority)},
+			{"Due", issue.DueDate},
+			{"Relationships", strings.Join(issue.Relations, ", ")},
+		}
+		for _, field := range schema.Fields {
+			details = append(details, [2]string{field.Name, issue.Fields[field.Name]})
+		}
+		if issue.Imported != nil {
+			details = append(details, [2]string{"Imported from", issue.Imported.String()})
+		}
+		for _, detail := range details {
+			if detail[1] != "" {
+				s.WriteString(fmt.Sprintf("- **%s:** %s\n", detail[0], detail[1]))
+			}
+		}
+
+		if description := strings.TrimSpace(issue.Description); description != "" {
+			s.WriteString("\n" + description + "\n")
+		}
+
+		if len(issue.Comments) > 0 {
+			s.WriteString("\n### Comments\n")
+			for _, comment := range issue.Comments {
+				s.WriteString(fmt.Sprintf("\n**%s** on %s:\n\n", comment.Author, comment.CreatedAt.Format("2006-01-02 15:04")))
+				for _, line := range strings.Split(strings.TrimSpace(comment.Content), "\n") {
+					s.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+				}
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, s.String())
+	return err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTestStore(t *testing.T) *memoryStore {
+	t.Helper()
+	store := newMemoryStore()
+	require.NoError(t, store.SaveMilestone(Milestone{Id: "m1", Title: "v1.0"}))
+
+	crash := testIssue("one", "Crash on start")
+	crash.Labels = []string{"bug"}
+	crash.Assignees = []string{"someone@example.com"}
+	crash.MilestoneId = "m1"
+	crash.Fields = map[string]string{"component": "ui"}
+	crash.Comments = []Comment{
+		{Author: "alice@example.com", Content: "Same here,\n\"on mobile\"", CreatedAt: time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)},
+		{Author: "bob@example.com", Deleted: true, CreatedAt: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	docs := testIssue("two", "Write docs")
+	docs.Labels = []string{"docs"}
+	docs.Assignees = []string{"alice@example.com"}
+	gone := testIssue("three", "Deleted bug")
+	gone.Labels = []string{"bug"}
+	gone.DeletedAt = time.Now()
+
+	for _, issue := range []Issue{crash, docs, gone} {
+		require.NoError(t, store.SaveIssue(issue))
+	}
+	return store
+}
+
+func TestExportableIssues(t *testing.T) {
+	issues, err := exportableIssues(exportTestStore(t), defaultWorkflow())
+	require.NoError(t, err)
+	require.Len(t, issues, 2, "deleted issues aren't exported")
+
+	bugs := filterIssues(issues, "label:bug", "alice@example.com", defaultWorkflow(), testFieldSchema)
+	require.Len(t, bugs, 1)
+	assert.Equal(t, "v1.0", bugs[0].MilestoneTitle)
+	assert.Len(t, filterIssues(issues, "milestone:v1.0", "alice@example.com", defaultWorkflow(), testFieldSchema), 1)
+	assert.Len(t, filterIssues(issues, "", "alice@example.com", defaultWorkflow(), testFieldSchema), 2)
+	assert.Empty(t, filterIssues(issues, "label:feature", "alice@example.com", defaultWorkflow(), testFieldSchema))
+
+	mine := filterIssues(issues, "assignee:me", "alice@example.com", defaultWorkflow(), testFieldSchema)
+	require.Len(t, mine, 1, `"me" is who's exporting, not any assignee containing "me"`)
+	assert.Equal(t, "Write docs", mine[0].Title)
+	mine = filterIssues(issues, "assignee:me label:bug", "someone@example.com", defaultWorkflow(), testFieldSchema)
+	require.Len(t, mine, 1)
+	assert.Equal(t, "Crash on start", mine[0].Title)
+}
+
+func TestExportFormats(t *testing.T) {
+	issues, err := exportableIssues(exportTestStore(t), defaultWorkflow())
+	require.NoError(t, err)
+	bugs := filterIssues(issues, "label:bug", "alice@example.com", defaultWorkflow(), testFieldSchema)
+	withComments := convertSlice(bugs, func(issue Issue) exportedIssue { return exportIssue(issue, true) })
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, exportJSON(&out, withComments, testFieldSchema, true))
+		var decoded []exportedIssue
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		require.Len(t, decoded, 1)
+		assert.Equal(t, "v1.0", decoded[0].Milestone)
+		require.Len(t, decoded[0].Comments, 1, "deleted comments are left out")
+		assert.Equal(t, "alice@example.com", decoded[0].Comments[0].Author)
+
+		out.Reset()
+		require.NoError(t, exportJSON(&out, nil, testFieldSchema, false))
+		assert.Equal(t, "[]\n", out.String())
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, exportCSV(&out, withComments, testFieldSchema, true))
+		records, err := csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		header, row := records[0], records[1]
+		assert.Equal(t, []string{"shortcode", "id", "title"}, header[:3])
+		assert.Equal(t, "comments", header[len(header)-1])
+		assert.Contains(t, header, "component")
+		assert.Equal(t, "Crash on start", row[2])
+		assert.Equal(t, "ui", row[13])
+		assert.Contains(t, row[len(row)-1], "Same here,\n\"on mobile\"")
+
+		out.Reset()
+		without := convertSlice(issues, func(issue Issue) exportedIssue { return exportIssue(issue, false) })
+		require.NoError(t, exportCSV(&out, without, testFieldSchema, false))
+		records, err = csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		assert.Len(t, records, 3)
+		assert.NotContains(t, records[0], "comments")
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, exportMarkdown(&out, withComments, testFieldSchema, true))
+		markdown := out.String()
+		assert.Contains(t, markdown, "## #"+StringToShortcode("one")+" Crash on start")
+		assert.Contains(t, markdown, "- **Milestone:** v1.0")
+		assert.Contains(t, markdown, "- **component:** ui")
+		assert.Contains(t, markdown, "**alice@example.com** on 2024-01-02 03:04:\n\n> Same here,\n> \"on mobile\"\n")
+	})
+}
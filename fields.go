@@ -0,0 +1,453 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/go-git/go-git/v5"
+)
+
+// A repo can add its own fields to issues in .ubik/fields.json, e.g.
+//
+//	{
+//	  "fields": [
+//	    {"name": "component", "type": "enum", "options": ["ui", "api", "cli"]},
+//	    {"name": "estimate", "type": "number"},
+//	    {"name": "customer", "type": "string"},
+//	    {"name": "reviewer", "type": "user"},
+//	    {"name": "deadline", "type": "date"}
+//	  ]
+//	}
+//
+// Values are stored on the issue as text. Fields that are later dropped from
+// the schema keep their values but are no longer shown or editable.
+const fieldsPath = ".ubik/fields.json"
+
+type fieldType string
+
+const (
+	stringField fieldType = "string"
+	numberField fieldType = "number"
+	enumField   fieldType = "enum"
+	dateField   fieldType = "date"
+	userField   fieldType = "user"
+)
+
+type CustomField struct {
+	Name    string    `json:"name"`
+	Type    fieldType `json:"type"`
+	Options []string  `json:"options,omitempty"`
+}
+
+type FieldSchema struct {
+	Fields []CustomField `json:"fields"`
+}
+
+var (
+	errInvalidFieldSchema = errors.New("invalid custom fields")
+	errInvalidFieldValue  = errors.New("invalid value")
+	fieldNamePattern      = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+	// builtInFilters can't be used as field names, or the field couldn't be
+	// searched for.
+	builtInFilters = []string{"label", "status", "assignee", "milestone", "priority", "due"}
+)
+
+func (s FieldSchema) validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", errInvalidFieldSchema, fmt.Sprintf(format, args...))
+	}
+
+	var names []string
+	for _, field := range s.Fields {
+		switch {
+		case !fieldNamePattern.MatchString(field.Name):
+			return invalid("field names are lowercase words, not %q", field.Name)
+		case slices.Contains(builtInFilters, field.Name):
+			return invalid("%q is a built-in search term", field.Name)
+		case slices.Contains(names, field.Name):
+			return invalid("field %q is listed twice", field.Name)
+		case !slices.Contains([]fieldType{stringField, numberField, enumField, dateField, userField}, field.Type):
+			return invalid("field %q has unknown type %q", field.Name, field.Type)
+		case field.Type == enumField && len(field.Options) == 0:
+			return invalid("enum field %q needs options", field.Name)
+		}
+		names = append(names, field.Name)
+	}
+
+	return nil
+}
+
+// readFieldSchema reads the custom fields at path; no file means no custom
+// fields.
+func readFieldSchema(path string) (FieldSchema, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return FieldSchema{}, nil
+	}
+	if err != nil {
+		return FieldSchema{}, err
+	}
+
+	var schema FieldSchema
+	err = json.Unmarshal(data, &schema)
+	if err != nil {
+		return FieldSchema{}, fmt.Errorf("%w: %v", errInvalidFieldSchema, err)
+	}
+	err = schema.validate()
+	if err != nil {
+		return FieldSchema{}, err
+	}
+
+	return schema, nil
+}
+
+type fieldsLoadedMsg struct {
+	Schema FieldSchema
+	Err    error
+}
+
+func loadFieldSchema(repo *git.Repository) tea.Cmd {
+	return func() tea.Msg {
+		schema, err := readFieldSchema(repoConfigPath(repo, fieldsPath))
+		if err != nil {
+			debug("%#v", err.Error())
+		}
+		return fieldsLoadedMsg{Schema: schema, Err: err}
+	}
+}
+
+func (s FieldSchema) field(name string) (CustomField, bool) {
+	i := slices.IndexFunc(s.Fields, func(f CustomField) bool { return f.Name == name })
+	if i < 0 {
+		return CustomField{}, false
+	}
+	return s.Fields[i], true
+}
+
+// parse checks a value typed into the form and returns it in the form it's
+// stored in.
+func (f CustomField) parse(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	invalid := func(expected string) error {
+		return fmt.Errorf("%w for %s: %q isn't %s", errInvalidFieldValue, f.Name, value, expected)
+	}
+
+	switch f.Type {
+	case numberField:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", invalid("a number")
+		}
+	case enumField:
+		i := slices.IndexFunc(f.Options, func(option string) bool { return strings.EqualFold(option, value) })
+		if i < 0 {
+			return "", invalid("one of " + strings.Join(f.Options, ", "))
+		}
+		value = f.Options[i]
+	case dateField:
+		if _, err := time.Parse(time.DateOnly, value); err != nil {
+			return "", invalid("a date like 2006-01-02")
+		}
+	case userField:
+		if !strings.Contains(value, "@") {
+			return "", invalid("an email address")
+		}
+	}
+
+	return value, nil
+}
+
+func (f CustomField) placeholder() string {
+	switch f.Type {
+	case numberField:
+		return "a number"
+	case enumField:
+		return strings.Join(f.Options, " | ")
+	case dateField:
+		return "YYYY-MM-DD"
+	case userField:
+		return "email"
+	default:
+		return ""
+	}
+}
+
+// encodeFields puts an issue's fields on one line of its FilterValue, as
+// tab-separated name=value pairs.
+func encodeFields(fields map[string]string) string {
+	var pairs []string
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, fields[name]))
+	}
+	return strings.Join(pairs, "\t")
+}
+
+func decodeFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, pair := range strings.Split(line, "\t") {
+		if name, value, ok := strings.Cut(pair, "="); ok {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// isFieldTerm reports whether a search term names a custom field.
+func (s FieldSchema) isFieldTerm(term string) bool {
+	name, _, ok := strings.Cut(term, ":")
+	if !ok {
+		return false
+	}
+	_, ok = s.field(name)
+	return ok
+}
+
+// FieldFilter narrows the issue list by a custom field. Text fields match by
+// substring; numbers and dates can also be compared:
+//
+//	component:api
+//	estimate:>=3
+//	deadline:<2024-07-01
+func FieldFilter(term string, targets []string, schema FieldSchema) []list.Rank {
+	name, query, _ := strings.Cut(term, ":")
+	field, _ := schema.field(name)
+
+	operator := ""
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(query, op) {
+			operator, query = op, strings.TrimPrefix(query, op)
+			break
+		}
+	}
+
+	compare := func(value string) (int, bool) {
+		switch field.Type {
+		case numberField:
+			a, errA := strconv.ParseFloat(value, 64)
+			b, errB := strconv.ParseFloat(query, 64)
+			if errA != nil || errB != nil {
+				return 0, false
+			}
+			return cmp.Compare(a, b), true
+		case dateField:
+			// dates in this form sort as strings
+			return strings.Compare(value, query), true
+		default:
+			return strings.Compare(strings.ToLower(value), strings.ToLower(query)), true
+		}
+	}
+
+	var ranks []list.Rank
+	for i, t := range targets {
+		value, ok := decodeFields(strings.Split(t, "\n")[8])[name]
+		if !ok {
+			continue
+		}
+
+		var match bool
+		if operator == "" && field.Type != numberField && field.Type != dateField {
+			match = strings.Contains(strings.ToLower(value), strings.ToLower(query))
+		} else if c, ok := compare(value); ok {
+			switch operator {
+			case ">=":
+				match = c >= 0
+			case "<=":
+				match = c <= 0
+			case ">":
+				match = c > 0
+			case "<":
+				match = c < 0
+			default:
+				match = c == 0
+			}
+		}
+		if match {
+			ranks = append(ranks, list.Rank{Index: i})
+		}
+	}
+
+	return ranks
+}
+
+// renderFields lists the issue's custom fields for the issue header, in
+// schema order.
+func renderFields(fields map[string]string, schema FieldSchema) string {
+	var s strings.Builder
+	for _, field := range schema.Fields {
+		if value := fields[field.Name]; value != "" {
+			s.WriteString(fmt.Sprintf("%s: %s\n", field.Name, value))
+		}
+	}
+	return s.String()
+}
+
+func newFieldInputs(schema FieldSchema, values map[string]string) []textinput.Model {
+	var inputs []textinput.Model
+	for _, field := range schema.Fields {
+		input := textinput.New()
+		input.Prompt = field.Name + ": "
+		input.Placeholder = field.placeholder()
+		input.CharLimit = 200
+		input.SetValue(values[field.Name])
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// fieldValues applies the form's field inputs on top of an issue's existing
+// fields, dropping any that were cleared.
+func (f issueForm) fieldValues(schema FieldSchema, existing map[string]string) (map[string]string, error) {
+	fields := maps.Clone(existing)
+	if fields == nil {
+		fields = make(map[string]string)
+	}
+
+	for i, field := range schema.Fields {
+		if i >= len(f.fieldInputs) {
+			break
+		}
+		value, err := field.parse(f.fieldInputs[i].Value())
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			delete(fields, field.Name)
+		} else {
+			fields[field.Name] = value
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+func (m Model) issueFieldsView(labelStyle, fieldStyle func(...string) string) string {
+	var s strings.Builder
+	s.WriteString(labelStyle("Fields"))
+	s.WriteString("\n")
+	for _, input := range m.issueForm.fieldInputs {
+		s.WriteString(fieldStyle(input.View()))
+		s.WriteString("\n")
+	}
+	s.WriteString("\n")
+	return s.String()
+}
+
+// focusFields moves the form onto its first custom field, or straight on to
+// the description when the repo has none.
+func (m Model) focusFields() (Model, tea.Cmd) {
+	if len(m.issueForm.fieldInputs) == 0 {
+		if m.issueForm.editing {
+			m.path = issuesEditDescriptionPath
+		} else {
+			m.path = issuesNewDescriptionPath
+		}
+		return m, m.issueForm.descriptionInput.Focus()
+	}
+
+	if m.issueForm.editing {
+		m.path = issuesEditFieldsPath
+	} else {
+		m.path = issuesNewFieldsPath
+	}
+	m.issueForm.focusedField = 0
+	m.issueForm.fieldInputs = slices.Clone(m.issueForm.fieldInputs)
+	return m, m.issueForm.fieldInputs[0].Focus()
+}
+
+func issuesFieldsHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+	// copy the inputs so the previous model's form is left alone
+	inputs := slices.Clone(m.issueForm.fieldInputs)
+	m.issueForm.fieldInputs = inputs
+	focused := m.issueForm.focusedField
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Back):
+			if m.issueForm.editing {
+				m.path = issuesShowPath
+			} else {
+				m.path = issuesIndexPath
+			}
+			return m, cmd
+		case key.Matches(msg, keys.NextInput):
+			inputs[focused].Blur()
+			if focused+1 < len(inputs) {
+				m.issueForm.focusedField++
+				return m, inputs[focused+1].Focus()
+			}
+			if m.issueForm.editing {
+				m.path = issuesEditDescriptionPath
+			} else {
+				m.path = issuesNewDescriptionPath
+			}
+			return m, m.issueForm.descriptionInput.Focus()
+		}
+	}
+
+	inputs[focused], cmd = inputs[focused].Update(msg)
+	return m, cmd
+}
+
+func mergeFields(base, ours, theirs map[string]string) map[string]string {
+	names := slices.Sorted(maps.Keys(ours))
+	for name := range maps.Keys(theirs) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	merged := make(map[string]string)
+	for _, name := range names {
+		// a clash over a custom field isn't worth a conflict; ours wins
+		value, _ := mergeScalar(name, base[name], ours[name], theirs[name])
+		if value != "" {
+			merged[name] = value
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+func fieldChanges(before, after map[string]string) []string {
+	var changes []string
+	names := slices.Sorted(maps.Keys(after))
+	for name := range maps.Keys(before) {
+		if _, ok := after[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		switch {
+		case before[name] == after[name]:
+		case after[name] == "":
+			changes = append(changes, fmt.Sprintf("%s cleared", name))
+		default:
+			changes = append(changes, fmt.Sprintf("%s changed to %q", name, after[name]))
+		}
+	}
+	return changes
+}
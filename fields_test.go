@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFieldSchema = FieldSchema{Fields: []CustomField{
+	{Name: "component", Type: enumField, Options: []string{"ui", "api"}},
+	{Name: "estimate", Type: numberField},
+	{Name: "customer", Type: stringField},
+	{Name: "reviewer", Type: userField},
+	{Name: "deadline", Type: dateField},
+}}
+
+func TestReadFieldSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fields.json")
+
+	schema, err := readFieldSchema(path)
+	require.NoError(t, err)
+	assert.Empty(t, schema.Fields)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"fields": [{"name": "severity", "type": "enum", "options": ["s1", "s2"]}]}`), 0o644))
+	schema, err = readFieldSchema(path)
+	require.NoError(t, err)
+	assert.Equal(t, []CustomField{{Name: "severity", Type: enumField, Options: []string{"s1", "s2"}}}, schema.Fields)
+
+	tests := map[string]string{
+		"built-in name":   `{"fields": [{"name": "status", "type": "string"}]}`,
+		"bad name":        `{"fields": [{"name": "Due Soon", "type": "string"}]}`,
+		"unknown type":    `{"fields": [{"name": "size", "type": "bytes"}]}`,
+		"enum no options": `{"fields": [{"name": "size", "type": "enum"}]}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			_, err := readFieldSchema(path)
+			assert.ErrorIs(t, err, errInvalidFieldSchema)
+		})
+	}
+}
+
+func TestCustomFieldParse(t *testing.T) {
+	tests := []struct {
+		field    CustomField
+		input    string
+		expected string
+		valid    bool
+	}{
+		{testFieldSchema.Fields[0], "API", "api", true},
+		{testFieldSchema.Fields[0], "cli", "", false},
+		{testFieldSchema.Fields[1], " 2.5 ", "2.5", true},
+		{testFieldSchema.Fields[1], "lots", "", false},
+		{testFieldSchema.Fields[3], "bob", "", false},
+		{testFieldSchema.Fields[4], "2024-02-30", "", false},
+		{testFieldSchema.Fields[4], "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field.Name+" "+tt.input, func(t *testing.T) {
+			value, err := tt.field.parse(tt.input)
+			if !tt.valid {
+				assert.ErrorIs(t, err, errInvalidFieldValue)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestFieldFilter(t *testing.T) {
+	issues := []Issue{
+		{Title: "small ui", Fields: map[string]string{"component": "ui", "estimate": "1", "customer": "Acme Corp"}},
+		{Title: "big api", Fields: map[string]string{"component": "api", "estimate": "8", "deadline": "2024-06-01"}},
+		{Title: "unsized"},
+	}
+	targets := convertSlice(issues, Issue.FilterValue)
+
+	titles := func(term string) []string {
+		var titles []string
+		for _, rank := range CustomFilter(term, targets, defaultWorkflow(), testFieldSchema) {
+			titles = append(titles, issues[rank.Index].Title)
+		}
+		return titles
+	}
+
+	assert.ElementsMatch(t, []string{"big api"}, titles("component:api"))
+	assert.ElementsMatch(t, []string{"small ui"}, titles("customer:acme"))
+	assert.ElementsMatch(t, []string{"big api"}, titles("estimate:>=3"))
+	assert.ElementsMatch(t, []string{"small ui"}, titles("estimate:1"))
+	assert.ElementsMatch(t, []string{"big api"}, titles("deadline:<2024-07-01"))
+	assert.ElementsMatch(t, []string{"big api"}, titles("estimate:>2 component:api"))
+}
+
+func TestMergeFields(t *testing.T) {
+	base := map[string]string{"component": "ui", "estimate": "1"}
+	ours := map[string]string{"component": "api", "estimate": "1"}
+	theirs := map[string]string{"component": "ui", "customer": "Acme"}
+
+	assert.Equal(t, map[string]string{"component": "api", "customer": "Acme"}, mergeFields(base, ours, theirs))
+	assert.Equal(t, []string{`customer changed to "Acme"`, "estimate cleared"}, fieldChanges(base, theirs))
+}
@@ -43,7 +43,7 @@
       default = pkgs.buildGoModule.override {go = pkgs.go_1_23;} {
         pname = "ubik";
         version = "pre-alpha";
-        vendorHash = "sha256-Wj0XPigjXWRf4/8Y/EdQ/1P1HypghiP0402ZegUxYvk=";
+        vendorHash = "sha256-TSVvWZchMrJJ1kVKvF9qVqfj2P9uB7/TVFuW2YyvrQc=";
 
         buildInputs = with pkgs; [
           git
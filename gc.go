@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/revlist"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+type GCOptions struct {
+	// ActionRetention is how long finished action runs are kept.
+	ActionRetention time.Duration
+	// IssueGracePeriod is how long soft-deleted issues are kept before they
+	// are purged.
+	IssueGracePeriod time.Duration
+	DryRun           bool
+	Now              time.Time
+}
+
+func defaultGCOptions() GCOptions {
+	return GCOptions{
+		ActionRetention:  30 * 24 * time.Hour,
+		IssueGracePeriod: 30 * 24 * time.Hour,
+		Now:              time.Now(),
+	}
+}
+
+// Orphaned objects younger than this are left alone in case they belong to a
+// write that is still in flight.
+const gcObjectGracePeriod = time.Hour
+
+type GCReport struct {
+	ActionsPruned  int
+	IssuesPurged   int
+	ObjectsRemoved int
+	BytesReclaimed int64
+	DryRun         bool
+}
+
+func (r GCReport) String() string {
+	verb := "removed"
+	if r.DryRun {
+		verb = "would remove"
+	}
+	return fmt.Sprintf(
+		"%s %d action run(s), %d deleted issue(s) and %d object(s), reclaiming %s",
+		verb, r.ActionsPruned, r.IssuesPurged, r.ObjectsRemoved, formatBytes(r.BytesReclaimed),
+	)
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// collectGarbage prunes old action runs and purges issues that were deleted
+// more than the grace period ago. It then removes the loose objects that only
+// those records (or earlier, overwritten versions of ubik records) used.
+func collectGarbage(repo *git.Repository, store Store, opts GCOptions) (GCReport, error) {
+	report := GCReport{DryRun: opts.DryRun}
+
+	var removedRefs []plumbing.ReferenceName
+
+	actions, err := store.Actions()
+	if err != nil {
+		return report, err
+	}
+	var expiredActions []string
+	for _, action := range actions {
+		if actionExpired(action, opts.Now, opts.ActionRetention) {
+			expiredActions = append(expiredActions, action.Id)
+			removedRefs = append(removedRefs, actionRefName(action.Id))
+		}
+	}
+
+	issues, err := store.Issues()
+	if err != nil {
+		return report, err
+	}
+	var expiredIssues []string
+	for _, issue := range issues {
+		if !issue.DeletedAt.IsZero() && opts.Now.Sub(issue.DeletedAt) > opts.IssueGracePeriod {
+			expiredIssues = append(expiredIssues, issue.Id)
+			removedRefs = append(removedRefs, issueRefName(issue.Id))
+		}
+	}
+
+	report.ActionsPruned = len(expiredActions)
+	report.IssuesPurged = len(expiredIssues)
+
+	// A nil repo means the store is not backed by git objects, so there is
+	// nothing to reclaim beyond the records themselves.
+	var garbage []gcObject
+	if repo != nil {
+		garbage, err = unreachableUbikObjects(repo, removedRefs, opts.Now)
+		if err != nil {
+			return report, err
+		}
+	}
+	for _, object := range garbage {
+		report.ObjectsRemoved++
+		report.BytesReclaimed += object.size
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, id := range expiredActions {
+		err = store.PruneAction(id)
+		if err != nil {
+			return report, err
+		}
+	}
+	for _, id := range expiredIssues {
+		err = store.PurgeIssue(id)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if len(garbage) == 0 {
+		return report, nil
+	}
+	loose, ok := repo.Storer.(storer.LooseObjectStorer)
+	if !ok {
+		return report, nil
+	}
+	for _, object := range garbage {
+		err = loose.DeleteLooseObject(object.hash)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// actionExpired reports whether action finished more than retention before
+// now. Runs that never finished count from when they started.
+func actionExpired(action Action, now time.Time, retention time.Duration) bool {
+	finished := action.FinishedAt
+	if finished.IsZero() {
+		finished = action.StartedAt
+	}
+	return now.Sub(finished) > retention
+}
+
+type gcObject struct {
+	hash plumbing.Hash
+	size int64
+}
+
+// unreachableUbikObjects finds the loose objects that would be unreachable
+// once removedRefs are gone and that ubik wrote itself: anything reachable
+// from removedRefs, and orphaned blobs that hold ubik records. Objects still
+// reachable from any other ref or staged in the index are never returned.
+func unreachableUbikObjects(repo *git.Repository, removedRefs []plumbing.ReferenceName, now time.Time) ([]gcObject, error) {
+	loose, ok := repo.Storer.(storer.LooseObjectStorer)
+	if !ok {
+		return nil, nil
+	}
+
+	var candidates []plumbing.Hash
+	for _, name := range removedRefs {
+		ref, err := repo.Storer.Reference(name)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, ref.Hash())
+	}
+
+	err := loose.ForEachObjectHash(func(hash plumbing.Hash) error {
+		modified, err := loose.LooseObjectTime(hash)
+		if err != nil || now.Sub(modified) < gcObjectGracePeriod {
+			return nil
+		}
+		if isUbikRecord(repo, hash) {
+			candidates = append(candidates, hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	var keep []plumbing.Hash
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && !containsRef(removedRefs, ref.Name()) {
+			keep = append(keep, ref.Hash())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if idx, err := repo.Storer.Index(); err == nil {
+		for _, entry := range idx.Entries {
+			keep = append(keep, entry.Hash)
+		}
+	}
+
+	unreachable, err := revlist.Objects(repo.Storer, candidates, keep)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []gcObject
+	for _, hash := range unreachable {
+		if _, err := loose.LooseObjectTime(hash); err != nil {
+			// packed objects stay until `git gc` repacks
+			continue
+		}
+		objects = append(objects, gcObject{hash: hash, size: looseObjectSize(repo, hash)})
+	}
+
+	return objects, nil
+}
+
+func containsRef(names []plumbing.ReferenceName, name plumbing.ReferenceName) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isUbikRecord reports whether hash is a blob holding an issue, action or
+// milestone record.
+func isUbikRecord(repo *git.Repository, hash plumbing.Hash) bool {
+	blob, err := repo.BlobObject(hash)
+	if err != nil {
+		return false
+	}
+	data, err := readBlob(blob)
+	if err != nil {
+		return false
+	}
+
+	var record map[string]json.RawMessage
+	if json.Unmarshal(data, &record) != nil {
+		return false
+	}
+	_, hasId := record["id"]
+	_, isIssue := record["shortcode"]
+	_, isAction := record["commitId"]
+	_, hasDueDate := record["due_date"]
+	_, hasClosed := record["closed"]
+	isMilestone := hasDueDate && hasClosed
+	return hasId && (isIssue || isAction || isMilestone)
+}
+
+// looseObjectSize returns the on-disk size of a loose object, falling back to
+// its uncompressed size.
+func looseObjectSize(repo *git.Repository, hash plumbing.Hash) int64 {
+	if fs, ok := repo.Storer.(*filesystem.Storage); ok {
+		hex := hash.String()
+		info, err := fs.Filesystem().Stat(filepath.Join("objects", hex[:2], hex[2:]))
+		if err == nil {
+			return info.Size()
+		}
+	}
+
+	obj, err := repo.Storer.EncodedObject(plumbing.AnyObject, hash)
+	if err != nil {
+		return 0
+	}
+	return obj.Size()
+}
+
+type gcFinishedMsg struct {
+	Report GCReport
+	Err    error
+}
+
+func garbageCollect(repo *git.Repository, store Store, dryRun bool) tea.Cmd {
+	return func() tea.Msg {
+		opts := defaultGCOptions()
+		opts.DryRun = dryRun
+		report, err := collectGarbage(repo, store, opts)
+		if err != nil {
+			debug("%#v", err.Error())
+		}
+		return gcFinishedMsg{Report: report, Err: err}
+	}
+}
+
+// gcConfirmationHandler asks before running the garbage collection whose
+// dry run is in m.gcReport.
+func gcConfirmationHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.GarbageCollectConfirm):
+			m.path = m.underlayPath
+			m.underlayPath = 0
+			m.flash = "collecting garbage..."
+			m.UpdateLayout(m.layout.TerminalSize)
+			return m, garbageCollect(m.repo, m.store, false)
+		case key.Matches(msg, keys.Back):
+			m.path = m.underlayPath
+			m.underlayPath = 0
+			m.UpdateLayout(m.layout.TerminalSize)
+			return m, nil
+		}
+	}
+
+	return m, nil
+}
+
+func parseGCDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		_, err := fmt.Sscanf(days, "%d", &n)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
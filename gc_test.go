@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	repo, err := git.PlainInit(t.TempDir(), false)
+	require.NoError(t, err)
+	store := newGitStore(repo, testGitConfig("alice@example.com"))
+
+	now := time.Now()
+	old := now.Add(-60 * 24 * time.Hour)
+
+	purged := Issue{Id: "purged", Shortcode: "aaaaaa", Title: "Gone", DeletedAt: old}
+	recent := Issue{Id: "recent", Shortcode: "bbbbbb", Title: "Trash", DeletedAt: now}
+	live := Issue{Id: "live", Shortcode: "cccccc", Title: "Live"}
+	for _, issue := range []Issue{purged, recent, live} {
+		require.NoError(t, store.SaveIssue(issue))
+	}
+	require.NoError(t, store.SaveAction(Action{Id: "stale", CommitId: "c1", FinishedAt: old}))
+	require.NoError(t, store.SaveAction(Action{Id: "fresh", CommitId: "c1", FinishedAt: now}))
+
+	purgedRef, err := repo.Reference(issueRefName("purged"), true)
+	require.NoError(t, err)
+	milestone := Milestone{Id: "m1", Title: "v1"}
+	require.NoError(t, store.SaveMilestone(milestone))
+	oldMilestone, err := repo.Reference(milestoneRefName("m1"), true)
+	require.NoError(t, err)
+	milestone.Title = "v1.0"
+	require.NoError(t, store.SaveMilestone(milestone))
+	orphan, err := storeBlob(repo, []byte(`{"id":"orphan","shortcode":"dddddd"}`))
+	require.NoError(t, err)
+	unrelated, err := storeBlob(repo, []byte(`{"id":"not ubik"}`))
+	require.NoError(t, err)
+
+	opts := GCOptions{
+		ActionRetention:  30 * 24 * time.Hour,
+		IssueGracePeriod: 30 * 24 * time.Hour,
+		DryRun:           true,
+		// past the grace period for freshly written loose objects
+		Now: now.Add(2 * time.Hour),
+	}
+
+	report, err := collectGarbage(repo, store, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.ActionsPruned)
+	assert.Equal(t, 1, report.IssuesPurged)
+	assert.Greater(t, report.ObjectsRemoved, 0)
+	assert.Greater(t, report.BytesReclaimed, int64(0))
+
+	_, err = repo.Reference(issueRefName("purged"), true)
+	assert.NoError(t, err, "a dry run leaves everything in place")
+
+	opts.DryRun = false
+	applied, err := collectGarbage(repo, store, opts)
+	require.NoError(t, err)
+	assert.Equal(t, report.ObjectsRemoved, applied.ObjectsRemoved)
+
+	issues, err := store.Issues()
+	require.NoError(t, err)
+	var ids []string
+	for _, issue := range issues {
+		ids = append(ids, issue.Id)
+	}
+	assert.ElementsMatch(t, []string{"recent", "live"}, ids)
+
+	actions, err := store.Actions()
+	require.NoError(t, err)
+	require.Len(t, actions, 1)
+	assert.Equal(t, "fresh", actions[0].Id)
+
+	for _, hash := range []plumbing.Hash{purgedRef.Hash(), orphan, oldMilestone.Hash()} {
+		_, err = repo.Storer.EncodedObject(plumbing.AnyObject, hash)
+		assert.ErrorIs(t, err, plumbing.ErrObjectNotFound)
+	}
+	_, err = repo.Storer.EncodedObject(plumbing.AnyObject, unrelated)
+	assert.NoError(t, err, "blobs that are not ubik records are left alone")
+	milestones, err := store.Milestones()
+	require.NoError(t, err)
+	require.Len(t, milestones, 1)
+	assert.Equal(t, "v1.0", milestones[0].Title)
+
+	again, err := collectGarbage(repo, store, opts)
+	require.NoError(t, err)
+	assert.Equal(t, GCReport{}, again)
+}
+
+func TestParseGCDuration(t *testing.T) {
+	d, err := parseGCDuration("30d")
+	require.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, d)
+
+	d, err = parseGCDuration("12h")
+	require.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, d)
+
+	_, err = parseGCDuration("xd")
+	assert.Error(t, err)
+}
@@ -0,0 +1,856 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestModel(t *testing.T, issues ...Issue) (Model, *memoryStore) {
+	t.Helper()
+
+	store := newMemoryStore()
+	for _, issue := range issues {
+		require.NoError(t, store.SaveIssue(issue))
+	}
+
+	m := InitialModel()
+	m.store = store
+	m.gitConfig = config.NewConfig()
+	m.gitConfig.User.Name = "Alice"
+	m.gitConfig.User.Email = "alice@example.com"
+	m = update(t, m, tea.WindowSizeMsg{Width: 200, Height: 60})
+	m = update(t, m, getIssues(store)())
+	m = update(t, m, getTrash(store)())
+
+	return m, store
+}
+
+func update(t *testing.T, m Model, msg tea.Msg) Model {
+	t.Helper()
+	next, _ := m.Update(msg)
+	return next.(Model)
+}
+
+// press sends a key to the model without running the command it returns.
+func press(t *testing.T, m Model, keys ...string) (Model, tea.Cmd) {
+	t.Helper()
+
+	var cmd tea.Cmd
+	for _, k := range keys {
+		var next tea.Model
+		next, cmd = m.Update(keyMsg(k))
+		m = next.(Model)
+	}
+	return m, cmd
+}
+
+// deliver runs cmd and feeds the resulting message back into the model,
+// returning the command the model answered with.
+func deliver(t *testing.T, m Model, cmd tea.Cmd) (Model, tea.Cmd) {
+	t.Helper()
+	require.NotNil(t, cmd)
+
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		var cmds []tea.Cmd
+		for _, c := range batch {
+			if c != nil {
+				m, c = deliver(t, m, c)
+				cmds = append(cmds, c)
+			}
+		}
+		return m, tea.Batch(cmds...)
+	}
+
+	next, cmd := m.Update(msg)
+	return next.(Model), cmd
+}
+
+func keyMsg(k string) tea.KeyMsg {
+	switch k {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "tab":
+		return tea.KeyMsg{Type: tea.KeyTab}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	case "backspace":
+		return tea.KeyMsg{Type: tea.KeyBackspace}
+	case " ":
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+	default:
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+	}
+}
+
+func storedIssue(t *testing.T, store *memoryStore, id string) Issue {
+	t.Helper()
+	revisions, err := store.IssueHistory(id)
+	require.NoError(t, err)
+	return revisions[0].Issue
+}
+
+func testIssue(id, title string) Issue {
+	return Issue{
+		Id:        id,
+		Shortcode: StringToShortcode(id),
+		Title:     title,
+		Status:    todo,
+		Author:    "bob@example.com",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+}
+
+func selectIssue(t *testing.T, m Model, id string) Model {
+	t.Helper()
+	for i, item := range m.issueIndex.Items() {
+		if item.(Issue).Id == id {
+			m.issueIndex.Select(i)
+			return m
+		}
+	}
+	t.Fatalf("issue %s isn't listed", id)
+	return m
+}
+
+func TestIssuesIndexHandlerStatusToggles(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected issueStatus
+	}{
+		{" ", done},
+		{"w", wontDo},
+		{"p", inProgress},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.expected), func(t *testing.T) {
+			m, store := newTestModel(t, testIssue("one", "First"))
+
+			m, cmd := press(t, m, tt.key)
+			m, _ = deliver(t, m, cmd)
+			assert.Equal(t, tt.expected, storedIssue(t, store, "one").Status)
+			assert.Equal(t, tt.expected, m.issueIndex.SelectedItem().(Issue).Status)
+
+			m, cmd = press(t, m, tt.key)
+			deliver(t, m, cmd)
+			assert.Equal(t, todo, storedIssue(t, store, "one").Status)
+		})
+	}
+}
+
+func TestIssuesShowHandler(t *testing.T) {
+	m, store := newTestModel(t, testIssue("one", "First"))
+
+	m, _ = press(t, m, "enter")
+	assert.Equal(t, issuesShowPath, m.path)
+	assert.Equal(t, "one", m.issueShow.issue.Id)
+
+	m, cmd := press(t, m, "w")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, wontDo, storedIssue(t, store, "one").Status)
+	assert.Equal(t, issuesShowPath, m.path)
+
+	m, cmd = press(t, m, "h")
+	m, _ = deliver(t, m, cmd)
+	assert.True(t, m.issueShow.showHistory)
+	assert.Len(t, m.issueShow.history, 2)
+
+	m, _ = press(t, m, "h")
+	assert.False(t, m.issueShow.showHistory)
+
+	m, _ = press(t, m, "esc")
+	assert.Equal(t, issuesIndexPath, m.path)
+}
+
+func TestIssuesShowHandlerResolvesConflicts(t *testing.T) {
+	issue := testIssue("one", "Ours")
+	issue.Conflicts = []IssueConflict{{Field: conflictFieldTitle, Ours: "Ours", Theirs: "Theirs"}}
+	m, store := newTestModel(t, issue)
+
+	m, _ = press(t, m, "enter")
+	m, cmd := press(t, m, "t")
+	deliver(t, m, cmd)
+
+	stored := storedIssue(t, store, "one")
+	assert.Equal(t, "Theirs", stored.Title)
+	assert.Empty(t, stored.Conflicts)
+}
+
+func TestIssuesDeleteHandler(t *testing.T) {
+	m, store := newTestModel(t, testIssue("one", "First"), testIssue("two", "Second"))
+
+	m, _ = press(t, m, "backspace")
+	assert.Equal(t, issuesDeleteConfirmationPath, m.path)
+
+	m, _ = press(t, m, "esc")
+	assert.Equal(t, issuesIndexPath, m.path)
+
+	selected := m.issueIndex.SelectedItem().(Issue)
+	m, _ = press(t, m, "backspace")
+	m, cmd := press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+
+	assert.False(t, storedIssue(t, store, selected.Id).DeletedAt.IsZero())
+	assert.Len(t, m.issueIndex.Items(), 1)
+	assert.Equal(t, issuesIndexPath, m.path)
+}
+
+func TestIssuesNewHandlers(t *testing.T) {
+	m, store := newTestModel(t)
+
+	m, _ = press(t, m, "n")
+	assert.Equal(t, issuesNewTitlePath, m.path)
+	m, _ = press(t, m, "H", "i")
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesNewLabelsPath, m.path)
+	m, _ = press(t, m, "b", "u", "g")
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesNewRelationsPath, m.path)
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesNewMilestonePath, m.path)
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesNewPriorityPath, m.path)
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesNewDuePath, m.path)
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesNewDescriptionPath, m.path)
+	m, _ = press(t, m, "o", "k")
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesNewConfirmationPath, m.path)
+
+	m, cmd := press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+
+	issues, err := store.Issues()
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "Hi", issues[0].Title)
+	assert.Equal(t, []string{"bug"}, issues[0].Labels)
+	assert.Equal(t, "ok", issues[0].Description)
+	assert.Equal(t, "alice@example.com", issues[0].Author)
+	assert.Len(t, m.issueIndex.Items(), 1)
+}
+
+func TestIssuesNewHandlersBack(t *testing.T) {
+	m, _ := newTestModel(t)
+
+	for _, steps := range [][]string{{"n"}, {"n", "tab"}, {"n", "tab", "tab"}, {"n", "tab", "tab", "tab"}, {"n", "tab", "tab", "tab", "tab"}, {"n", "tab", "tab", "tab", "tab", "tab"}, {"n", "tab", "tab", "tab", "tab", "tab", "tab"}, {"n", "tab", "tab", "tab", "tab", "tab", "tab", "tab"}} {
+		m, _ = press(t, m, steps...)
+		m, _ = press(t, m, "esc")
+		assert.Equal(t, issuesIndexPath, m.path)
+	}
+}
+
+func TestIssuesEditHandlers(t *testing.T) {
+	m, store := newTestModel(t, testIssue("one", "First"))
+
+	m, _ = press(t, m, "enter", "enter")
+	assert.Equal(t, issuesEditTitlePath, m.path)
+	m, _ = press(t, m, "!")
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesEditLabelsPath, m.path)
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesEditRelationsPath, m.path)
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesEditMilestonePath, m.path)
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesEditPriorityPath, m.path)
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesEditDuePath, m.path)
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesEditDescriptionPath, m.path)
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesEditConfirmationPath, m.path)
+
+	m, cmd := press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+
+	assert.Equal(t, "First!", storedIssue(t, store, "one").Title)
+	assert.Equal(t, issuesShowPath, m.path)
+}
+
+func TestIssuesCommentHandlers(t *testing.T) {
+	m, store := newTestModel(t, testIssue("one", "First"))
+
+	m, _ = press(t, m, "c")
+	assert.Equal(t, issuesCommentContentPath, m.path)
+	m, _ = press(t, m, "y", "o")
+	m, _ = press(t, m, "tab")
+	assert.Equal(t, issuesCommentConfirmationPath, m.path)
+
+	m, cmd := press(t, m, "enter")
+	m, cmd = deliver(t, m, cmd)
+	m, _ = deliver(t, m, cmd)
+
+	comments := storedIssue(t, store, "one").Comments
+	require.NotEmpty(t, comments)
+	assert.Equal(t, "yo", comments[0].Content)
+	assert.Equal(t, "alice@example.com", comments[0].Author)
+	assert.False(t, comments[0].CreatedAt.IsZero())
+	assert.Equal(t, issuesShowPath, m.path)
+}
+
+func TestActionsIndexHandler(t *testing.T) {
+	m, store := newTestModel(t)
+	commit := Commit{
+		Hash:          "deadbeef",
+		LatestActions: []Action{{Id: "a1", CommitId: "deadbeef", Status: running}},
+	}
+	m = update(t, m, CommitListReadyMsg{commit})
+
+	m, _ = press(t, m, "right")
+	assert.Equal(t, milestonesIndexPath, m.path)
+	m, _ = press(t, m, "right")
+	assert.Equal(t, actionsIndexPath, m.path)
+
+	finished := Action{Id: "a1", CommitId: "deadbeef", Status: succeeded}
+	m, cmd := deliver(t, m, func() tea.Msg { return actionResult(finished) })
+	m, _ = deliver(t, m, cmd)
+
+	actions, err := store.Actions()
+	require.NoError(t, err)
+	assert.Equal(t, []Action{{Id: "a1", CommitId: "deadbeef", Status: succeeded}}, actions)
+	assert.Equal(t, succeeded, m.commitIndex.Items()[0].(Commit).AggregateActionStatus())
+
+	m, _ = press(t, m, "enter")
+	assert.Equal(t, actionsShowPath, m.path)
+	m, _ = press(t, m, "esc")
+	assert.Equal(t, actionsIndexPath, m.path)
+}
+
+func TestGarbageCollectKey(t *testing.T) {
+	deleted := testIssue("one", "First")
+	deleted.DeletedAt = time.Now().Add(-60 * 24 * time.Hour)
+	m, store := newTestModel(t, deleted, testIssue("two", "Second"))
+	storedIssues := func() []Issue {
+		issues, err := store.Issues()
+		require.NoError(t, err)
+		return issues
+	}
+
+	m, cmd := press(t, m, "G")
+	m = update(t, m, cmd())
+	require.Equal(t, gcConfirmationPath, m.path)
+	assert.Contains(t, m.View(), "would remove 0 action run(s), 1 deleted issue(s)")
+	assert.Len(t, storedIssues(), 2, "nothing goes before it's confirmed")
+
+	m, _ = press(t, m, "esc")
+	assert.Equal(t, issuesIndexPath, m.path)
+	assert.Len(t, storedIssues(), 2)
+
+	m, cmd = press(t, m, "G")
+	m = update(t, m, cmd())
+	m, cmd = press(t, m, "enter")
+	assert.Equal(t, issuesIndexPath, m.path)
+	m = update(t, m, cmd())
+
+	issues := storedIssues()
+	require.Len(t, issues, 1)
+	assert.Equal(t, "two", issues[0].Id)
+	assert.Contains(t, m.flash, "removed 0 action run(s), 1 deleted issue(s)")
+
+	m, cmd = press(t, m, "G")
+	m = update(t, m, cmd())
+	assert.Equal(t, issuesIndexPath, m.path)
+	assert.Equal(t, "nothing to collect", m.flash)
+}
+
+// brokenStore fails every read, like a store with a corrupt packed index.
+type brokenStore struct {
+	*memoryStore
+}
+
+var errBrokenStore = errors.New("bad packed index")
+
+func (brokenStore) Issues() ([]Issue, error) {
+	return nil, errBrokenStore
+}
+
+func (brokenStore) Milestones() ([]Milestone, error) {
+	return nil, errBrokenStore
+}
+
+func TestTrashLoadFailure(t *testing.T) {
+	m, _ := newTestModel(t)
+
+	m = update(t, m, getTrash(brokenStore{newMemoryStore()})())
+	assert.Contains(t, m.flash, "loading trash failed: bad packed index")
+}
+
+func TestMilestonesLoadFailure(t *testing.T) {
+	m, _ := newTestModel(t)
+
+	m = update(t, m, getMilestones(brokenStore{newMemoryStore()})())
+	assert.Contains(t, m.flash, "loading milestones failed: bad packed index")
+}
+
+func TestTrashHandlers(t *testing.T) {
+	trashed := testIssue("old", "Old")
+	trashed.DeletedAt = time.Now().Add(-time.Hour)
+	trashed.DeletedBy = "bob@example.com"
+	m, store := newTestModel(t, testIssue("one", "First"), trashed)
+	require.Len(t, m.trashIndex.Items(), 1)
+
+	m, cmd := press(t, m, "backspace", "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, "alice@example.com", storedIssue(t, store, "one").DeletedBy)
+	assert.Empty(t, m.issueIndex.Items())
+	require.Len(t, m.trashIndex.Items(), 2)
+
+	m, _ = press(t, m, "left", "left")
+	assert.Equal(t, trashIndexPath, m.path)
+	assert.Equal(t, "one", m.trashIndex.SelectedItem().(deletedIssue).Id)
+
+	m, cmd = press(t, m, "r")
+	m, _ = deliver(t, m, cmd)
+	restored := storedIssue(t, store, "one")
+	assert.True(t, restored.DeletedAt.IsZero())
+	assert.Empty(t, restored.DeletedBy)
+	require.Len(t, m.issueIndex.Items(), 1)
+	require.Len(t, m.trashIndex.Items(), 1)
+
+	m, _ = press(t, m, "backspace")
+	assert.Equal(t, trashPurgeConfirmationPath, m.path)
+	m, _ = press(t, m, "esc")
+	assert.Equal(t, trashIndexPath, m.path)
+
+	m, _ = press(t, m, "backspace")
+	m, cmd = press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	_, err := store.IssueHistory("old")
+	assert.ErrorIs(t, err, errIssueNotFound)
+	assert.Empty(t, m.trashIndex.Items())
+	assert.Equal(t, trashIndexPath, m.path)
+}
+
+func TestIssuesAttachHandler(t *testing.T) {
+	m, store := newTestModel(t, testIssue("one", "First"))
+	path := writeTestFile(t, "trace.txt", "stack")
+
+	m, _ = press(t, m, "enter", "f")
+	assert.Equal(t, issuesAttachPath, m.path)
+	m, _ = press(t, m, "q")
+	assert.Equal(t, issuesAttachPath, m.path, "typing a path doesn't quit")
+	m.attachInput.SetValue(path)
+
+	m, cmd := press(t, m, "enter")
+	assert.Equal(t, issuesShowPath, m.path)
+	m, _ = deliver(t, m, cmd)
+
+	attachments := storedIssue(t, store, "one").Attachments
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "trace.txt", attachments[0].Name)
+	assert.Len(t, m.issueShow.issue.Attachments, 1)
+	assert.Contains(t, m.issueShow.viewport.View(), "trace.txt")
+}
+
+func TestIssuesExtractHandler(t *testing.T) {
+	store := newMemoryStore()
+	notes, err := attachFile(store, writeTestFile(t, "notes.txt", "notes"))
+	require.NoError(t, err)
+	trace, err := attachFile(store, writeTestFile(t, "trace.txt", "trace"))
+	require.NoError(t, err)
+	screenshot, err := attachFile(store, writeTestFile(t, "screenshot.png", "png"))
+	require.NoError(t, err)
+
+	issue := testIssue("one", "First")
+	issue.Attachments = []Attachment{notes, trace}
+	issue.Comments = []Comment{{Author: "bob@example.com", Content: "see", Attachments: []Attachment{screenshot}, CreatedAt: issue.CreatedAt, UpdatedAt: issue.CreatedAt}}
+	m, _ := newTestModel(t, issue)
+	m.store = store
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	m, _ = press(t, m, "enter", "x")
+	require.Equal(t, issuesExtractPath, m.path)
+	assert.Contains(t, m.View(), "attachment (1-3)")
+	m, _ = press(t, m, "2")
+	m, cmd := press(t, m, "enter")
+	assert.Equal(t, issuesShowPath, m.path)
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, "extracted trace.txt", m.flash)
+
+	m, _ = press(t, m, "x", "9", "enter")
+	assert.Equal(t, "no attachment [9]", m.flash)
+
+	m, cmd = press(t, m, "]", "x")
+	assert.Equal(t, issuesShowPath, m.path, "a selected comment's attachments are extracted directly")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, "extracted screenshot.png", m.flash)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"screenshot.png", "trace.txt"}, names)
+}
+
+func TestIssueRelationships(t *testing.T) {
+	blocker := testIssue("one", "Blocker")
+	blocker.Shortcode = "aaaaaa"
+	original := testIssue("two", "Original")
+	original.Shortcode = "bbbbbb"
+	m, store := newTestModel(t, blocker, original, testIssue("three", "Blocked"))
+
+	m = selectIssue(t, m, "three")
+	m, _ = press(t, m, "enter", "enter", "tab", "tab")
+	require.Equal(t, issuesEditRelationsPath, m.path)
+	m.issueForm.relationsInput.SetValue("blocked-by:#aaaaaa bogus:#bbbbbb")
+	m, _ = press(t, m, "tab", "tab", "tab", "tab", "tab")
+	m, cmd := press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Contains(t, m.flash, "invalid relationship")
+	assert.Empty(t, storedIssue(t, store, "three").Relations)
+
+	m.issueForm.relationsInput.SetValue("blocked-by:#aaaaaa")
+	m, cmd = press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, []Relation{{Kind: relationBlockedBy, IssueId: "one"}}, storedIssue(t, store, "three").Relations)
+	assert.Contains(t, m.issueShow.viewport.View(), "blocked by")
+	assert.Contains(t, m.issueShow.viewport.View(), "#aaaaaa Blocker")
+
+	m, _ = press(t, m, " ")
+	assert.Contains(t, m.flash, "is still blocked by #aaaaaa")
+
+	m, _ = press(t, m, "esc")
+	m = selectIssue(t, m, "one")
+	assert.Equal(t, relationBlocks, m.issueIndex.SelectedItem().(Issue).RelatedIssues[0].Kind, "the blocker sees the inverse")
+
+	m = selectIssue(t, m, "two")
+	m, _ = press(t, m, "enter", "enter", "tab", "tab")
+	m.issueForm.relationsInput.SetValue("duplicate-of:#aaaaaa")
+	m, _ = press(t, m, "tab", "tab", "tab", "tab", "tab")
+	m, cmd = press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, wontDo, storedIssue(t, store, "two").Status, "duplicates are closed")
+}
+
+func TestIssueRelatedToDeletedIssue(t *testing.T) {
+	gone := testIssue("gone", "Gone")
+	gone.Shortcode = "aaaaaa"
+	gone.DeletedAt = time.Now()
+	blocked := testIssue("blocked", "Blocked")
+	blocked.Relations = []Relation{{Kind: relationBlockedBy, IssueId: "gone"}}
+	m, store := newTestModel(t, gone, blocked)
+
+	m = selectIssue(t, m, "blocked")
+	m, _ = press(t, m, "enter", "enter", "tab", "tab")
+	require.Equal(t, issuesEditRelationsPath, m.path)
+	assert.Equal(t, "blocked-by:#gone", m.issueForm.relationsInput.Value())
+	m.issueForm.titleInput.SetValue("Still blocked")
+	m, _ = press(t, m, "tab", "tab", "tab", "tab", "tab")
+	m, cmd := press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+
+	stored := storedIssue(t, store, "blocked")
+	assert.Equal(t, "Still blocked", stored.Title, "the edit isn't rejected")
+	assert.Equal(t, blocked.Relations, stored.Relations)
+}
+
+func TestIssueAssignees(t *testing.T) {
+	m, store := newTestModel(t, testIssue("one", "First"))
+
+	m, cmd := press(t, m, "a")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, []string{"alice@example.com"}, storedIssue(t, store, "one").Assignees)
+	assert.Contains(t, m.issueIndex.View(), "assigned to alice@example.com")
+
+	m, _ = press(t, m, "enter", "A")
+	require.Equal(t, issuesAssignPath, m.path)
+	assert.Equal(t, "alice@example.com", m.assignInput.Value(), "defaults to the current identity")
+	m.assignInput.SetValue("bob@example.com")
+	m, cmd = press(t, m, "enter")
+	assert.Equal(t, issuesShowPath, m.path)
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com"}, storedIssue(t, store, "one").Assignees)
+	assert.Contains(t, m.issueShow.viewport.View(), "Assignees: alice@example.com, bob@example.com")
+
+	m, cmd = press(t, m, "a")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, []string{"bob@example.com"}, storedIssue(t, store, "one").Assignees)
+}
+
+func TestAssigneeFilter(t *testing.T) {
+	mine := testIssue("one", "Mine")
+	mine.Assignees = []string{"alice@example.com"}
+	theirs := testIssue("two", "Theirs")
+	theirs.Assignees = []string{"bob@example.com"}
+	m, _ := newTestModel(t, mine, theirs, testIssue("three", "Nobody's"))
+
+	var targets []string
+	for _, item := range m.issueIndex.Items() {
+		targets = append(targets, item.FilterValue())
+	}
+	titles := func(ranks []list.Rank) []string {
+		var titles []string
+		for _, rank := range ranks {
+			titles = append(titles, m.issueIndex.Items()[rank.Index].(Issue).Title)
+		}
+		return titles
+	}
+
+	assert.Equal(t, []string{"Mine"}, titles(m.issueFilter()("assignee:me", targets)))
+	assert.Equal(t, []string{"Theirs"}, titles(m.issueFilter()("assignee:bob", targets)))
+	assert.Empty(t, m.issueFilter()("assignee:me Theirs", targets))
+}
+
+func TestMilestoneHandlers(t *testing.T) {
+	m, store := newTestModel(t, testIssue("one", "First"))
+
+	m, _ = press(t, m, "right", "n")
+	require.Equal(t, milestonesFormPath, m.path)
+	m, _ = press(t, m, "q")
+	assert.Equal(t, milestonesFormPath, m.path, "typing a title doesn't quit")
+	m.milestoneForm.titleInput.SetValue("v1")
+	m, _ = press(t, m, "tab")
+	m.milestoneForm.dueInput.SetValue("soon")
+	m, _ = press(t, m, "enter")
+	assert.Contains(t, m.flash, "due dates look like")
+
+	m.milestoneForm.dueInput.SetValue("2030-01-31")
+	m, cmd := press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, milestonesIndexPath, m.path)
+	milestones, err := store.Milestones()
+	require.NoError(t, err)
+	require.Len(t, milestones, 1)
+	assert.Equal(t, "2030-01-31", milestones[0].DueDate.Local().Format(time.DateOnly))
+
+	m, _ = press(t, m, "left", "enter", "enter", "tab", "tab", "tab")
+	require.Equal(t, issuesEditMilestonePath, m.path)
+	m, _ = press(t, m, "right", "tab", "tab", "tab", "tab")
+	m, cmd = press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, milestones[0].Id, storedIssue(t, store, "one").MilestoneId)
+	assert.Contains(t, m.issueShow.viewport.View(), "Milestone: v1")
+
+	m, _ = press(t, m, "esc", "right", "enter")
+	require.Equal(t, milestonesShowPath, m.path)
+	assert.Contains(t, m.milestoneShow.View(), "(0/1 closed)")
+	m, cmd = press(t, m, " ")
+	m, _ = deliver(t, m, cmd)
+	milestones, err = store.Milestones()
+	require.NoError(t, err)
+	assert.True(t, milestones[0].Closed)
+}
+
+func TestIssuePriorityAndDueDate(t *testing.T) {
+	m, store := newTestModel(t, testIssue("one", "First"), testIssue("two", "Second"))
+
+	m = selectIssue(t, m, "two")
+	m, _ = press(t, m, "enter", "enter", "tab", "tab", "tab", "tab")
+	require.Equal(t, issuesEditPriorityPath, m.path)
+	m, _ = press(t, m, "right", "tab")
+	require.Equal(t, issuesEditDuePath, m.path)
+	m, _ = press(t, m, "q")
+	assert.Equal(t, issuesEditDuePath, m.path, "typing a date doesn't quit")
+	m.issueForm.dueInput.SetValue("someday")
+	m, _ = press(t, m, "tab", "tab")
+	m, cmd := press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Contains(t, m.flash, "due dates look like")
+
+	m.issueForm.dueInput.SetValue("2001-02-03")
+	m, cmd = press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	stored := storedIssue(t, store, "two")
+	assert.Equal(t, urgentPriority, stored.Priority)
+	assert.Equal(t, "2001-02-03", formatDueDate(stored.DueDate))
+	assert.Contains(t, m.issueShow.viewport.View(), "due 2001-02-03 (overdue)")
+
+	m, _ = press(t, m, "esc")
+	m = selectIssue(t, m, "one")
+	m, _ = press(t, m, "o")
+	assert.Contains(t, m.flash, "sorted by priority")
+	assert.Equal(t, "two", m.issueIndex.Items()[0].(Issue).Id)
+	assert.Equal(t, "one", m.issueIndex.SelectedItem().(Issue).Id, "the selection follows the issue")
+}
+
+func TestIssueCustomFields(t *testing.T) {
+	m, store := newTestModel(t, testIssue("one", "First"))
+	m = update(t, m, fieldsLoadedMsg{Schema: FieldSchema{Fields: []CustomField{
+		{Name: "component", Type: enumField, Options: []string{"ui", "api"}},
+		{Name: "estimate", Type: numberField},
+	}}})
+
+	m, _ = press(t, m, "enter", "enter", "tab", "tab", "tab", "tab", "tab", "tab")
+	require.Equal(t, issuesEditFieldsPath, m.path)
+	m, _ = press(t, m, "a", "p", "i", "tab")
+	require.Equal(t, issuesEditFieldsPath, m.path)
+	m, _ = press(t, m, "l", "o", "t", "s", "tab")
+	require.Equal(t, issuesEditDescriptionPath, m.path)
+	m, _ = press(t, m, "tab")
+	m, cmd := press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Contains(t, m.flash, `"lots" isn't a number`)
+
+	m.issueForm.fieldInputs[1].SetValue("3")
+	m, cmd = press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, map[string]string{"component": "api", "estimate": "3"}, storedIssue(t, store, "one").Fields)
+	assert.Contains(t, m.issueShow.viewport.View(), "component: api")
+}
+
+func TestIssueCommentThreads(t *testing.T) {
+	created := time.Now().UTC().Add(-time.Hour)
+	issue := testIssue("one", "First")
+	issue.Comments = []Comment{
+		{Author: "bob@example.com", Content: "hi", CreatedAt: created, UpdatedAt: created},
+		{Author: "alice@example.com", Content: "hello", CreatedAt: created.Add(time.Minute), UpdatedAt: created.Add(time.Minute)},
+	}
+	bob, alice := commentKey(issue.Comments[0]), commentKey(issue.Comments[1])
+	m, store := newTestModel(t, issue)
+	m, _ = press(t, m, "enter")
+	require.Equal(t, issuesShowPath, m.path)
+
+	submit := func(m Model) Model {
+		m, _ = press(t, m, "tab")
+		m, cmd := press(t, m, "enter")
+		m, cmd = deliver(t, m, cmd)
+		m, _ = deliver(t, m, cmd)
+		return m
+	}
+
+	// only your own comments can be edited
+	m, _ = press(t, m, "]", "E")
+	assert.Equal(t, bob, m.issueShow.selectedComment)
+	assert.Equal(t, issuesShowPath, m.path)
+	assert.Contains(t, m.flash, "isn't yours")
+
+	m, _ = press(t, m, "]", "E")
+	require.Equal(t, issuesCommentContentPath, m.path)
+	m, _ = press(t, m, "!")
+	m = submit(m)
+	comment := storedIssue(t, store, "one").Comments[1]
+	assert.Equal(t, "hello!", comment.Content)
+	assert.True(t, comment.Edited)
+	assert.Equal(t, alice, m.issueShow.selectedComment, "the cursor stays put")
+	assert.Contains(t, m.issueShow.viewport.View(), "(edited)")
+
+	// replies thread under their parent
+	m, _ = press(t, m, "[", "r")
+	require.Equal(t, issuesCommentContentPath, m.path)
+	assert.Contains(t, m.commentFormView(), "Replying to bob@example.com")
+	m, _ = press(t, m, "y", "o")
+	m = submit(m)
+	comments := storedIssue(t, store, "one").Comments
+	require.Len(t, comments, 3)
+	assert.Equal(t, bob, comments[2].ReplyTo)
+	assert.Equal(t, []threadedComment{{index: 0}, {index: 2, reply: true}, {index: 1}}, threadComments(comments))
+
+	// reactions are recorded per user
+	m, _ = press(t, m, "]", "+")
+	require.Equal(t, issuesReactPath, m.path)
+	m, cmd := press(t, m, "right", "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, map[string][]string{"👎": {"alice@example.com"}}, storedIssue(t, store, "one").Comments[0].Reactions)
+	assert.Equal(t, issuesShowPath, m.path)
+
+	// deleting leaves a tombstone
+	m, _ = press(t, m, "]", "]", "backspace")
+	require.Equal(t, issuesCommentDeletePath, m.path)
+	m, cmd = press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	stored := storedIssue(t, store, "one")
+	comment = stored.Comments[findComment(stored.Comments, alice)]
+	assert.True(t, comment.Deleted)
+	assert.Empty(t, comment.Content)
+	assert.True(t, stored.DeletedAt.IsZero(), "the issue itself isn't deleted")
+	assert.Contains(t, m.issueShow.viewport.View(), "comment deleted")
+}
+
+func TestIssueShowMarkdownToggle(t *testing.T) {
+	issue := testIssue("one", "First")
+	issue.Description = "## Steps\n\n- **run** it"
+	m, _ := newTestModel(t, issue)
+	m, _ = press(t, m, "enter")
+
+	assert.Contains(t, ansi.Strip(m.issueShow.viewport.View()), "• run it")
+	assert.NotContains(t, m.issueShow.viewport.View(), "## Steps")
+
+	m, _ = press(t, m, "`")
+	assert.Contains(t, m.issueShow.viewport.View(), "## Steps")
+	assert.Contains(t, m.issueShow.viewport.View(), "- **run** it")
+
+	m, _ = press(t, m, "`")
+	assert.NotContains(t, m.issueShow.viewport.View(), "## Steps")
+}
+
+func TestInbox(t *testing.T) {
+	created := time.Now().UTC().Add(-time.Hour)
+	mentioned := testIssue("one", "First")
+	mentioned.Comments = []Comment{
+		{Author: "bob@example.com", Content: "@alice can you look?", CreatedAt: created, UpdatedAt: created},
+		{Author: "bob@example.com", Content: "@carol too", CreatedAt: created.Add(time.Minute), UpdatedAt: created.Add(time.Minute)},
+	}
+	m, store := newTestModel(t, mentioned, testIssue("two", "Second"))
+	m.inboxPath = filepath.Join(t.TempDir(), "inbox.json")
+
+	assigned := storedIssue(t, store, "two")
+	assigned.Assignees = []string{"alice@example.com"}
+	require.NoError(t, store.SaveIssue(assigned))
+	assigned.Status = inProgress
+	require.NoError(t, store.SaveIssue(assigned))
+
+	m, cmd := press(t, m, "left")
+	require.Equal(t, inboxIndexPath, m.path)
+	m, _ = deliver(t, m, cmd)
+
+	var summaries []string
+	for _, n := range m.notifications() {
+		summaries = append(summaries, fmt.Sprintf("#%s %s", n.Shortcode, n.Summary))
+	}
+	assert.ElementsMatch(t, []string{
+		"#" + mentioned.Shortcode + " mentioned you in a comment",
+		"#" + assigned.Shortcode + " assigned you",
+		"#" + assigned.Shortcode + " moved it to in-progress",
+	}, summaries)
+	assert.Contains(t, m.renderTabs("Inbox"), "Inbox (3)")
+
+	m = selectNotification(t, m, notification{Kind: mentionNotification})
+	m, cmd = press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, issuesShowPath, m.path)
+	assert.Equal(t, "one", m.issueShow.issue.Id)
+	assert.Equal(t, commentKey(mentioned.Comments[0]), m.issueShow.selectedComment)
+	assert.Contains(t, m.renderTabs("Issues"), "Inbox (2)")
+
+	m, cmd = press(t, m, "esc", "esc", "left", "M")
+	m, _ = deliver(t, m, cmd)
+	assert.Zero(t, m.unreadCount())
+
+	m, _ = deliver(t, m, m.loadInbox())
+	assert.Zero(t, m.unreadCount(), "read state survives a reload")
+	assert.Len(t, m.notifications(), 3)
+}
+
+func selectNotification(t *testing.T, m Model, like notification) Model {
+	t.Helper()
+	for i, n := range m.notifications() {
+		if n.Kind == like.Kind {
+			m.inboxIndex.Select(i)
+			return m
+		}
+	}
+	t.Fatalf("no %s notification", like.Kind)
+	return m
+}
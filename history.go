@@ -0,0 +1,418 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Every issue ref points at a chain of commits, one per mutation. Each commit
+// holds a tree with a single issue.json file containing the full record at
+// that point in time.
+const issueRecordFilename = "issue.json"
+
+func issueRefName(id string) plumbing.ReferenceName {
+	return plumbing.ReferenceName(fmt.Sprintf("refs/ubik/issues/%s", id))
+}
+
+func gitSignature(cfg *config.Config) object.Signature {
+	return object.Signature{
+		Name:  cfg.User.Name,
+		Email: cfg.User.Email,
+		When:  time.Now(),
+	}
+}
+
+func storeBlob(repo *git.Repository, data []byte) (plumbing.Hash, error) {
+	obj := repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(data)))
+	writer, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	_, err = writer.Write(data)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+func readBlob(blob *object.Blob) ([]byte, error) {
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+func storeObject(repo *git.Repository, o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := repo.Storer.NewEncodedObject()
+	err := o.Encode(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+// commitIssue records issue as a new commit on top of its ref and moves the ref
+// forward. Refs written before issues had history point straight at a blob;
+// the chain for those starts with a commit holding the legacy record.
+func commitIssue(repo *git.Repository, issue Issue, author object.Signature) (plumbing.Hash, error) {
+	refName := issueRefName(issue.Id)
+
+	var parents []plumbing.Hash
+	var previous *Issue
+	oldRef, err := repo.Storer.Reference(refName)
+	if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return plumbing.ZeroHash, err
+	}
+	if oldRef != nil {
+		prev, err := readIssueObject(repo, oldRef.Hash())
+		if err == nil {
+			previous = &prev
+		}
+		_, err = repo.CommitObject(oldRef.Hash())
+		switch {
+		case err == nil:
+			parents = append(parents, oldRef.Hash())
+		case previous != nil:
+			legacy, err := commitLegacyIssue(repo, *previous, author)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			parents = append(parents, legacy)
+		}
+	}
+
+	message := "Create issue"
+	if previous != nil {
+		message = issueChangeSummary(diffIssues(*previous, issue))
+	}
+
+	hash, err := writeIssueCommit(repo, issue, author, message, parents...)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ref := plumbing.NewHashReference(refName, hash)
+	err = repo.Storer.CheckAndSetReference(ref, oldRef)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return hash, nil
+}
+
+// commitLegacyIssue writes a root commit for an issue read from a legacy
+// blob ref, credited to the issue's author as of its last update.
+func commitLegacyIssue(repo *git.Repository, issue Issue, fallback object.Signature) (plumbing.Hash, error) {
+	author := fallback
+	if issue.Author != "" {
+		author = object.Signature{Email: issue.Author, When: fallback.When}
+	}
+	if !issue.UpdatedAt.IsZero() {
+		author.When = issue.UpdatedAt
+	}
+	return writeIssueCommit(repo, issue, author, "Imported issue")
+}
+
+func writeIssueCommit(repo *git.Repository, issue Issue, author object.Signature, message string, parents ...plumbing.Hash) (plumbing.Hash, error) {
+	jsonData, err := encodeIssue(issue)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	blobHash, err := storeBlob(repo, jsonData)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	tree := &object.Tree{
+		Entries: []object.TreeEntry{
+			{Name: issueRecordFilename, Mode: filemode.Regular, Hash: blobHash},
+		},
+	}
+	if hashes := attachmentHashes(issue); len(hashes) > 0 {
+		attachmentsHash, err := writeAttachmentTree(repo, hashes)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		// "attachments" sorts before "issue.json"
+		tree.Entries = slices.Insert(tree.Entries, 0, object.TreeEntry{Name: attachmentsDir, Mode: filemode.Dir, Hash: attachmentsHash})
+	}
+	treeHash, err := storeObject(repo, tree)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit := &object.Commit{
+		Author:       author,
+		Committer:    author,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+
+	return storeObject(repo, commit)
+}
+
+// readIssueObject decodes the issue stored at hash, which is either a commit
+// from an issue's history or a bare blob from the old layout.
+func readIssueObject(repo *git.Repository, hash plumbing.Hash) (Issue, error) {
+	data, err := readIssueData(repo, hash)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	return decodeIssue(data)
+}
+
+func readIssueData(repo *git.Repository, hash plumbing.Hash) ([]byte, error) {
+	obj, err := repo.Object(plumbing.AnyObject, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var blob *object.Blob
+	switch o := obj.(type) {
+	case *object.Blob:
+		blob = o
+	case *object.Commit:
+		file, err := o.File(issueRecordFilename)
+		if err != nil {
+			return nil, err
+		}
+		blob = &file.Blob
+	default:
+		return nil, fmt.Errorf("unexpected %s object for issue", obj.Type())
+	}
+
+	return readBlob(blob)
+}
+
+type IssueRevision struct {
+	Hash      string
+	Author    string
+	Timestamp time.Time
+	Message   string
+	Changes   []string
+	Issue     Issue
+	// Previous is the issue as of the revision's first parent, which is what
+	// Changes compares against. It's nil for the revision that created it.
+	Previous *Issue
+	// Merge marks revisions that brought in changes made elsewhere, like the
+	// ones sync makes, rather than changes of their author's own.
+	Merge bool
+}
+
+// readIssueHistory returns every revision reachable from the issue's ref,
+// newest first.
+func readIssueHistory(repo *git.Repository, id string) ([]IssueRevision, error) {
+	ref, err := repo.Reference(issueRefName(id), true)
+	if err != nil {
+		return nil, err
+	}
+
+	return issueChainHistory(repo, ref.Hash())
+}
+
+// issueChainHistory returns the revisions in the chain of issue commits
+// ending at hash, newest first.
+func issueChainHistory(repo *git.Repository, hash plumbing.Hash) ([]IssueRevision, error) {
+	tip, err := repo.CommitObject(hash)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		// legacy blob ref without any history
+		issue, err := readIssueObject(repo, hash)
+		if err != nil {
+			return nil, err
+		}
+		return []IssueRevision{{
+			Hash:      hash.String(),
+			Author:    issue.Author,
+			Timestamp: issue.UpdatedAt,
+			Message:   "Imported issue",
+			Issue:     issue,
+		}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []IssueRevision
+	iter := object.NewCommitPreorderIter(tip, nil, nil)
+	err = iter.ForEach(func(c *object.Commit) error {
+		issue, err := readIssueObject(repo, c.Hash)
+		if err != nil {
+			return err
+		}
+
+		var changes []string
+		var previous *Issue
+		if c.NumParents() > 0 {
+			parent, err := readIssueObject(repo, c.ParentHashes[0])
+			if err == nil {
+				changes = diffIssues(parent, issue)
+				previous = &parent
+			}
+		} else {
+			changes = []string{"created"}
+		}
+
+		revisions = append(revisions, IssueRevision{
+			Hash:      c.Hash.String(),
+			Author:    c.Author.Email,
+			Timestamp: c.Author.When,
+			Message:   strings.TrimSuffix(c.Message, "\n"),
+			Changes:   changes,
+			Issue:     issue,
+			Previous:  previous,
+			Merge:     c.NumParents() > 1,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(revisions, func(a, b IssueRevision) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+
+	return revisions, nil
+}
+
+// diffIssues describes what changed between two revisions of an issue.
+func diffIssues(before, after Issue) []string {
+	var changes []string
+
+	if before.Title != after.Title {
+		changes = append(changes, fmt.Sprintf("title changed to %q", after.Title))
+	}
+	if before.Description != after.Description {
+		changes = append(changes, "description edited")
+	}
+	if before.Status != after.Status {
+		changes = append(changes, fmt.Sprintf("status changed from %s to %s", before.Status, after.Status))
+	}
+	for _, label := range after.Labels {
+		if label != "" && !slices.Contains(before.Labels, label) {
+			changes = append(changes, fmt.Sprintf("label %q added", label))
+		}
+	}
+	for _, label := range before.Labels {
+		if label != "" && !slices.Contains(after.Labels, label) {
+			changes = append(changes, fmt.Sprintf("label %q removed", label))
+		}
+	}
+	if before.MilestoneId != after.MilestoneId {
+		changes = append(changes, "milestone changed")
+	}
+	if before.Priority != after.Priority {
+		changes = append(changes, fmt.Sprintf("priority changed to %s", cmp.Or(string(after.Priority), "none")))
+	}
+	if !before.DueDate.Equal(after.DueDate) {
+		changes = append(changes, fmt.Sprintf("due date changed to %s", cmp.Or(formatDueDate(after.DueDate), "none")))
+	}
+	changes = append(changes, fieldChanges(before.Fields, after.Fields)...)
+	for _, assignee := range after.Assignees {
+		if !slices.Contains(before.Assignees, assignee) {
+			changes = append(changes, fmt.Sprintf("assigned to %s", assignee))
+		}
+	}
+	for _, assignee := range before.Assignees {
+		if !slices.Contains(after.Assignees, assignee) {
+			changes = append(changes, fmt.Sprintf("%s unassigned", assignee))
+		}
+	}
+	for _, relation := range after.Relations {
+		if !slices.Contains(before.Relations, relation) {
+			changes = append(changes, fmt.Sprintf("relationship %s %s added", relation.Kind, relation.IssueId))
+		}
+	}
+	for _, relation := range before.Relations {
+		if !slices.Contains(after.Relations, relation) {
+			changes = append(changes, fmt.Sprintf("relationship %s %s removed", relation.Kind, relation.IssueId))
+		}
+	}
+	for _, attachment := range after.Attachments {
+		if !slices.ContainsFunc(before.Attachments, func(a Attachment) bool { return a.Hash == attachment.Hash }) {
+			changes = append(changes, fmt.Sprintf("attachment %q added", attachment.Name))
+		}
+	}
+	changes = append(changes, commentChanges(before.Comments, after.Comments)...)
+	if before.DeletedAt.IsZero() && !after.DeletedAt.IsZero() {
+		changes = append(changes, "deleted")
+	}
+	if !before.DeletedAt.IsZero() && after.DeletedAt.IsZero() {
+		changes = append(changes, "restored")
+	}
+
+	return changes
+}
+
+func issueChangeSummary(changes []string) string {
+	if len(changes) == 0 {
+		return "Update issue"
+	}
+
+	return fmt.Sprintf("Update issue\n\n%s\n", strings.Join(changes, "\n"))
+}
+
+type issueHistoryReadyMsg struct {
+	IssueId   string
+	Revisions []IssueRevision
+}
+
+func getIssueHistory(issue Issue, store IssueStore) tea.Cmd {
+	return func() tea.Msg {
+		revisions, err := store.IssueHistory(issue.Id)
+		if err != nil {
+			debug("%#v", err.Error())
+			return err
+		}
+
+		return issueHistoryReadyMsg{IssueId: issue.Id, Revisions: revisions}
+	}
+}
+
+func (s issueShow) historyContent() string {
+	var b strings.Builder
+	identifier := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render(fmt.Sprintf("#%s", s.issue.Shortcode))
+	b.WriteString(fmt.Sprintf("%s %s\nHistory: %d revision(s)\n", identifier, s.issue.Title, len(s.history)))
+
+	faint := lipgloss.NewStyle().Foreground(styles.Theme.FaintText)
+	for _, revision := range s.history {
+		header := fmt.Sprintf("%s %s at %s", revision.Hash[:8], revision.Author, revision.Timestamp.Format(time.RFC822))
+		b.WriteString("\n")
+		b.WriteString(faint.Render(header))
+		b.WriteString("\n")
+		for _, change := range revision.Changes {
+			b.WriteString(fmt.Sprintf("  %s\n", change))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitIssueBuildsHistory(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+
+	author := object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()}
+	issue := Issue{Id: "abc", Title: "First", Status: todo}
+	_, err = commitIssue(repo, issue, author)
+	require.NoError(t, err)
+
+	issue.Title = "Second"
+	issue.Status = done
+	issue.Labels = []string{"bug"}
+	author.When = author.When.Add(time.Minute)
+	_, err = commitIssue(repo, issue, author)
+	require.NoError(t, err)
+
+	revisions, err := readIssueHistory(repo, "abc")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+
+	assert.Equal(t, "Second", revisions[0].Issue.Title)
+	assert.Equal(t, "alice@example.com", revisions[0].Author)
+	assert.Contains(t, revisions[0].Changes, `title changed to "Second"`)
+	assert.Contains(t, revisions[0].Changes, "status changed from todo to done")
+	assert.Contains(t, revisions[0].Changes, `label "bug" added`)
+	assert.Equal(t, []string{"created"}, revisions[1].Changes)
+}
+
+func TestCommitIssueStartsChainFromLegacyBlob(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+
+	hash, err := storeBlob(repo, []byte(`{"id":"abc","title":"Legacy"}`))
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(issueRefName("abc"), hash)))
+
+	issue, err := readIssueObject(repo, hash)
+	require.NoError(t, err)
+	assert.Equal(t, "Legacy", issue.Title)
+
+	issue.Title = "Upgraded"
+	_, err = commitIssue(repo, issue, object.Signature{Email: "bob@example.com", When: time.Now()})
+	require.NoError(t, err)
+
+	revisions, err := readIssueHistory(repo, "abc")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "Upgraded", revisions[0].Issue.Title)
+	assert.Equal(t, []string{`title changed to "Upgraded"`}, revisions[0].Changes)
+	assert.Equal(t, "Legacy", revisions[1].Issue.Title)
+	assert.Equal(t, "Imported issue", revisions[1].Message)
+	assert.Equal(t, []string{"created"}, revisions[1].Changes)
+}
@@ -0,0 +1,726 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportedFrom records where an imported issue came from. Importing the same
+// export again finds the issue by it and updates it rather than adding a
+// copy.
+type ImportedFrom struct {
+	Source string `json:"source"`
+	// Id is the other tracker's id for the issue
+	Id string `json:"id"`
+	// Key is what people called it there, e.g. #12 or PROJ-12
+	Key string `json:"key,omitempty"`
+	URL string `json:"url,omitempty"`
+	// UpdatedAt is when the issue last changed in the other tracker
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (f ImportedFrom) String() string {
+	names := map[string]string{"github": "GitHub", "gitlab": "GitLab", "jira": "Jira"}
+	s := strings.TrimSpace(cmp.Or(names[f.Source], f.Source) + " " + cmp.Or(f.Key, f.Id))
+	if f.URL != "" {
+		s += " " + f.URL
+	}
+	return s
+}
+
+func (f *ImportedFrom) matches(other *ImportedFrom) bool {
+	return f != nil && other != nil && f.Source == other.Source && f.Id == other.Id
+}
+
+// An issueImporter reads another tracker's export file. The issues it returns
+// have no id or shortcode yet and must have Imported set.
+type issueImporter interface {
+	// detects reports whether path looks like this importer's export.
+	detects(path string) bool
+	read(path string, users userMap, w Workflow) ([]Issue, error)
+}
+
+var importers = map[string]issueImporter{
+	"github": githubImporter{},
+	"gitlab": gitlabImporter{},
+	"jira":   jiraImporter{},
+}
+
+// findImporter picks the importer named from, or the one that recognises
+// path when from is empty.
+func findImporter(from, path string) (issueImporter, error) {
+	if from != "" {
+		importer, ok := importers[from]
+		if !ok {
+			return nil, fmt.Errorf("unknown import format %q", from)
+		}
+		return importer, nil
+	}
+
+	names := slices.Sorted(maps.Keys(importers))
+	for _, name := range names {
+		if importers[name].detects(path) {
+			return importers[name], nil
+		}
+	}
+	return nil, fmt.Errorf("can't tell what %s was exported from; pass --from %s", path, strings.Join(names, "|"))
+}
+
+// userMap maps people's names in another tracker to the emails ubik knows
+// them by.
+type userMap map[string]string
+
+// parseUserMapping parses a --user flag, "name=email".
+func (u userMap) parseUserMapping(value string) error {
+	name, email, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(email) == "" {
+		return fmt.Errorf("%q should be name=email", value)
+	}
+	u[strings.TrimSpace(name)] = strings.TrimSpace(email)
+	return nil
+}
+
+// resolve maps the first of names that's been given an email, or failing
+// that uses the first one that looks like an email, or the first name.
+func (u userMap) resolve(names ...string) string {
+	for _, name := range names {
+		if email, ok := u[name]; ok {
+			return email
+		}
+	}
+	for _, name := range names {
+		if strings.Contains(name, "@") {
+			return name
+		}
+	}
+	return cmp.Or(names...)
+}
+
+// importStatus maps another tracker's status onto the workflow: to the
+// status with the same name if there is one, otherwise to the done status
+// for closed issues and the first one for open issues.
+func importStatus(w Workflow, closed bool, names ...string) issueStatus {
+	for _, name := range names {
+		slug := issueStatus(strings.Join(strings.Fields(strings.ToLower(name)), "-"))
+		if slices.ContainsFunc(w.Statuses, func(s workflowStatus) bool { return s.Name == slug }) {
+			return slug
+		}
+	}
+	if closed {
+		return w.Done
+	}
+	return w.initial()
+}
+
+// importLabels turns label names into ubik labels, which can't have spaces.
+func importLabels(names []string) []string {
+	var labels []string
+	for _, name := range names {
+		label := strings.Join(strings.Fields(name), "-")
+		if label != "" && !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+type importReport struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	// Skipped counts issues that were imported before and deleted since
+	Skipped int
+	DryRun  bool
+}
+
+func (r importReport) String() string {
+	verb := "imported"
+	if r.DryRun {
+		verb = "would import"
+	}
+	s := fmt.Sprintf("%s %d issue(s): %d new, %d updated, %d unchanged", verb, r.Created+r.Updated+r.Unchanged, r.Created, r.Updated, r.Unchanged)
+	if r.Skipped > 0 {
+		s += fmt.Sprintf(", %d skipped because they were deleted", r.Skipped)
+	}
+	return s
+}
+
+// importIssues saves imported issues to the store. Issues imported before
+// are updated if they've changed in the other tracker since, keeping
+// anything added in ubik; deleted ones are left deleted.
+func importIssues(store IssueStore, imported []Issue, dryRun bool) (importReport, error) {
+	report := importReport{DryRun: dryRun}
+	existing, err := store.Issues()
+	if err != nil {
+		return report, err
+	}
+
+	for _, issue := range imported {
+		i := slices.IndexFunc(existing, func(e Issue) bool { return e.Imported.matches(issue.Imported) })
+		if i < 0 {
+			issue.Id = uuid.NewString()
+			issue.Shortcode = uniqueShortcode(issue.Id, existing)
+			existing = append(existing, issue)
+			report.Created++
+		} else {
+			current := existing[i]
+			switch {
+			case !current.DeletedAt.IsZero():
+				report.Skipped++
+				continue
+			case !issue.Imported.UpdatedAt.After(current.Imported.UpdatedAt):
+				report.Unchanged++
+				continue
+			}
+			issue = reimportIssue(current, issue)
+			existing[i] = issue
+			report.Updated++
+		}
+
+		if dryRun {
+			continue
+		}
+		err := store.SaveIssue(issue)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// reimportIssue takes what the other tracker owns from a newer import of
+// current: its title, description, status, labels, assignees and comments.
+// Comments written in ubik stay.
+func reimportIssue(current, imported Issue) Issue {
+	current.Title = imported.Title
+	current.Description = imported.Description
+	current.Status = imported.Status
+	current.Labels = imported.Labels
+	current.Assignees = imported.Assignees
+	if imported.Priority != noPriority {
+		current.Priority = imported.Priority
+	}
+	for _, comment := range imported.Comments {
+		i := findComment(current.Comments, commentKey(comment))
+		if i < 0 {
+			current.Comments = append(current.Comments, comment)
+		} else {
+			current.Comments[i].Content = comment.Content
+			current.Comments[i].UpdatedAt = comment.UpdatedAt
+		}
+	}
+	slices.SortStableFunc(current.Comments, func(a, b Comment) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	current.Imported = imported.Imported
+	current.UpdatedAt = imported.UpdatedAt
+	return current
+}
+
+// githubImporter reads a JSON array of issues as GitHub's REST API returns
+// them, or as `gh issue list --json` prints them, with comments inline.
+// Pull requests are left out.
+type githubImporter struct{}
+
+type githubUser struct {
+	Login string `json:"login"`
+	Email string `json:"email"`
+}
+
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+// UnmarshalJSON takes labels as objects or plain names.
+func (l *githubLabel) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		return json.Unmarshal(data, &l.Name)
+	}
+	type label githubLabel
+	return json.Unmarshal(data, (*label)(l))
+}
+
+type githubComment struct {
+	Body           string     `json:"body"`
+	User           githubUser `json:"user"`
+	Author         githubUser `json:"author"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CreatedAtCamel time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	UpdatedAtCamel time.Time  `json:"updatedAt"`
+}
+
+type githubIssue struct {
+	Number           int             `json:"number"`
+	Title            string          `json:"title"`
+	Body             string          `json:"body"`
+	State            string          `json:"state"`
+	StateReason      string          `json:"state_reason"`
+	StateReasonCamel string          `json:"stateReason"`
+	URL              string          `json:"url"`
+	HTMLURL          string          `json:"html_url"`
+	User             githubUser      `json:"user"`
+	Author           githubUser      `json:"author"`
+	Assignees        []githubUser    `json:"assignees"`
+	Labels           []githubLabel   `json:"labels"`
+	PullRequest      json.RawMessage `json:"pull_request"`
+	// Comments is a list with gh, but only a count from the REST API
+	Comments       json.RawMessage `json:"comments"`
+	CreatedAt      time.Time       `json:"created_at"`
+	CreatedAtCamel time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	UpdatedAtCamel time.Time       `json:"updatedAt"`
+}
+
+func (githubImporter) detects(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+func (githubImporter) read(path string, users userMap, w Workflow) ([]Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var exported []githubIssue
+	err = json.Unmarshal(data, &exported)
+	if err != nil {
+		return nil, fmt.Errorf("%s isn't a GitHub issue export: %w", path, err)
+	}
+
+	var issues []Issue
+	for _, gh := range exported {
+		if len(gh.PullRequest) > 0 && string(gh.PullRequest) != "null" {
+			continue
+		}
+
+		url := cmp.Or(gh.HTMLURL, gh.URL)
+		issue := Issue{
+			Author:      users.resolve(githubLogin(gh.User, gh.Author)...),
+			Title:       gh.Title,
+			Description: gh.Body,
+			Labels:      importLabels(convertSlice(gh.Labels, func(l githubLabel) string { return l.Name })),
+			CreatedAt:   firstTime(gh.CreatedAt, gh.CreatedAtCamel),
+			UpdatedAt:   firstTime(gh.UpdatedAt, gh.UpdatedAtCamel),
+			Imported: &ImportedFrom{
+				Source: "github",
+				Id:     cmp.Or(url, strconv.Itoa(gh.Number)),
+				Key:    fmt.Sprintf("#%d", gh.Number),
+				URL:    url,
+			},
+		}
+		issue.Imported.UpdatedAt = issue.UpdatedAt
+		for _, assignee := range gh.Assignees {
+			issue.Assignees = append(issue.Assignees, users.resolve(githubLogin(assignee)...))
+		}
+
+		closed := strings.EqualFold(gh.State, "closed")
+		switch strings.ToLower(cmp.Or(gh.StateReason, gh.StateReasonCamel)) {
+		case "not_planned":
+			issue.Status = importStatus(w, closed, string(wontDo))
+		default:
+			issue.Status = importStatus(w, closed)
+		}
+
+		var comments []githubComment
+		if json.Unmarshal(gh.Comments, &comments) == nil {
+			for _, c := range comments {
+				issue.Comments = append(issue.Comments, Comment{
+					Author:    users.resolve(githubLogin(c.User, c.Author)...),
+					Content:   c.Body,
+					CreatedAt: firstTime(c.CreatedAt, c.CreatedAtCamel),
+					UpdatedAt: firstTime(c.UpdatedAt, c.UpdatedAtCamel, c.CreatedAt, c.CreatedAtCamel),
+				})
+			}
+		}
+
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
+
+func githubLogin(users ...githubUser) []string {
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Login, user.Email)
+	}
+	return names
+}
+
+func firstTime(times ...time.Time) time.Time {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// gitlabImporter reads a GitLab project export: the .tar.gz GitLab makes,
+// the directory it unpacks to, or its tree/project/issues.ndjson. People are
+// looked up in project_members.ndjson next to the issues.
+type gitlabImporter struct{}
+
+type gitlabUser struct {
+	Username    string `json:"username"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	PublicEmail string `json:"public_email"`
+}
+
+type gitlabMember struct {
+	UserId int        `json:"user_id"`
+	User   gitlabUser `json:"user"`
+}
+
+type gitlabNote struct {
+	Note      string     `json:"note"`
+	AuthorId  int        `json:"author_id"`
+	Author    gitlabUser `json:"author"`
+	System    bool       `json:"system"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type gitlabIssue struct {
+	Id          int    `json:"id"`
+	Iid         int    `json:"iid"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+	AuthorId    int    `json:"author_id"`
+	LabelLinks  []struct {
+		Label struct {
+			Title string `json:"title"`
+		} `json:"label"`
+	} `json:"label_links"`
+	IssueAssignees []struct {
+		UserId int `json:"user_id"`
+	} `json:"issue_assignees"`
+	Notes     []gitlabNote `json:"notes"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+const (
+	gitlabIssuesFile  = "tree/project/issues.ndjson"
+	gitlabMembersFile = "tree/project/project_members.ndjson"
+)
+
+func (gitlabImporter) detects(path string) bool {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return true
+	}
+	return strings.HasSuffix(path, ".ndjson") || strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+func (gitlabImporter) read(path string, users userMap, w Workflow) ([]Issue, error) {
+	issuesData, membersData, err := readGitlabExport(path)
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[int]gitlabUser{}
+	err = decodeNDJSON(membersData, func(member gitlabMember) {
+		members[member.UserId] = member.User
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", gitlabMembersFile, err)
+	}
+	person := func(id int, fallback gitlabUser) string {
+		user, ok := members[id]
+		if !ok {
+			user = fallback
+		}
+		return users.resolve(user.Username, user.Name, user.Email, user.PublicEmail, strconv.Itoa(id))
+	}
+
+	var issues []Issue
+	err = decodeNDJSON(issuesData, func(gl gitlabIssue) {
+		issue := Issue{
+			Author:      person(gl.AuthorId, gitlabUser{}),
+			Title:       gl.Title,
+			Description: gl.Description,
+			Status:      importStatus(w, gl.State == "closed"),
+			CreatedAt:   gl.CreatedAt.UTC(),
+			UpdatedAt:   gl.UpdatedAt.UTC(),
+			Imported: &ImportedFrom{
+				Source:    "gitlab",
+				Id:        strconv.Itoa(gl.Id),
+				Key:       fmt.Sprintf("#%d", gl.Iid),
+				UpdatedAt: gl.UpdatedAt.UTC(),
+			},
+		}
+		var labels []string
+		for _, link := range gl.LabelLinks {
+			labels = append(labels, link.Label.Title)
+		}
+		issue.Labels = importLabels(labels)
+		for _, assignee := range gl.IssueAssignees {
+			issue.Assignees = append(issue.Assignees, person(assignee.UserId, gitlabUser{}))
+		}
+
+		// system notes are GitLab's own "changed the description" and the like
+		for _, note := range gl.Notes {
+			if note.System {
+				continue
+			}
+			issue.Comments = append(issue.Comments, Comment{
+				Author:    person(note.AuthorId, note.Author),
+				Content:   note.Note,
+				CreatedAt: note.CreatedAt.UTC(),
+				UpdatedAt: firstTime(note.UpdatedAt, note.CreatedAt),
+			})
+		}
+		slices.SortStableFunc(issue.Comments, func(a, b Comment) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+		issues = append(issues, issue)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", gitlabIssuesFile, err)
+	}
+
+	return issues, nil
+}
+
+// readGitlabExport reads the issues and members files out of an export,
+// whichever form it's in. Members are optional.
+func readGitlabExport(path string) (issues, members []byte, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case info.IsDir():
+		issues, err = os.ReadFile(filepath.Join(path, gitlabIssuesFile))
+		members, _ = os.ReadFile(filepath.Join(path, gitlabMembersFile))
+	case strings.HasSuffix(path, ".ndjson"):
+		issues, err = os.ReadFile(path)
+		members, _ = os.ReadFile(filepath.Join(filepath.Dir(path), filepath.Base(gitlabMembersFile)))
+	default:
+		issues, members, err = readGitlabArchive(path)
+	}
+	return issues, members, err
+}
+
+func readGitlabArchive(path string) (issues, members []byte, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s isn't a GitLab export: %w", path, err)
+	}
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		name := strings.TrimPrefix(header.Name, "./")
+		switch name {
+		case gitlabIssuesFile:
+			issues, err = io.ReadAll(archive)
+		case gitlabMembersFile:
+			members, err = io.ReadAll(archive)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if issues == nil {
+		return nil, nil, fmt.Errorf("%s has no %s", path, gitlabIssuesFile)
+	}
+	return issues, members, nil
+}
+
+// decodeNDJSON decodes each line of data as a T.
+func decodeNDJSON[T any](data []byte, each func(T)) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var value T
+		err := json.Unmarshal(scanner.Bytes(), &value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		each(value)
+	}
+	return scanner.Err()
+}
+
+// jiraImporter reads Jira's "Export CSV (all fields)". Jira repeats the
+// Labels and Comment columns once for each label and comment.
+type jiraImporter struct{}
+
+// jiraTimeLayouts are the date formats Jira exports with, depending on the
+// instance's settings.
+var jiraTimeLayouts = []string{
+	"02/Jan/06 3:04 PM",
+	"02/Jan/06 15:04",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
+
+// jiraUnresolved are the resolutions Jira uses for open issues.
+var jiraUnresolved = []string{"", "unresolved"}
+
+// jiraWontDo are the resolutions that mean nothing was done.
+var jiraWontDo = []string{"won't do", "won't fix", "duplicate", "cannot reproduce", "declined", "rejected"}
+
+func (jiraImporter) detects(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".csv")
+}
+
+func (jiraImporter) read(path string, users userMap, w Workflow) ([]Issue, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s isn't a Jira CSV export: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	columns := func(row []string, name string) []string {
+		var values []string
+		for i, column := range header {
+			if strings.EqualFold(strings.TrimSpace(column), name) && i < len(row) && strings.TrimSpace(row[i]) != "" {
+				values = append(values, strings.TrimSpace(row[i]))
+			}
+		}
+		return values
+	}
+	column := func(row []string, name string) string {
+		return cmp.Or(columns(row, name)...)
+	}
+	if !slices.ContainsFunc(header, func(c string) bool { return strings.EqualFold(strings.TrimSpace(c), "Issue key") }) {
+		return nil, fmt.Errorf("%s isn't a Jira CSV export: it has no Issue key column", path)
+	}
+
+	var issues []Issue
+	for n, row := range records[1:] {
+		key := column(row, "Issue key")
+		if key == "" {
+			continue
+		}
+		created, err := parseJiraTime(column(row, "Created"))
+		if err != nil {
+			return nil, fmt.Errorf("%s row %d: %w", path, n+2, err)
+		}
+		updated, err := parseJiraTime(cmp.Or(column(row, "Updated"), column(row, "Created")))
+		if err != nil {
+			return nil, fmt.Errorf("%s row %d: %w", path, n+2, err)
+		}
+
+		resolution := strings.ToLower(column(row, "Resolution"))
+		closed := !slices.Contains(jiraUnresolved, resolution) || strings.EqualFold(column(row, "Status Category"), "Done")
+		names := []string{column(row, "Status")}
+		if slices.Contains(jiraWontDo, resolution) {
+			names = append(names, string(wontDo))
+		}
+
+		issue := Issue{
+			Author:      users.resolve(column(row, "Reporter"), column(row, "Creator")),
+			Title:       column(row, "Summary"),
+			Description: column(row, "Description"),
+			Status:      importStatus(w, closed, names...),
+			Labels:      importLabels(columns(row, "Labels")),
+			Priority:    jiraPriority(column(row, "Priority")),
+			CreatedAt:   created,
+			UpdatedAt:   updated,
+			Imported: &ImportedFrom{
+				Source:    "jira",
+				Id:        cmp.Or(column(row, "Issue id"), key),
+				Key:       key,
+				UpdatedAt: updated,
+			},
+		}
+		if assignee := column(row, "Assignee"); assignee != "" {
+			issue.Assignees = []string{users.resolve(assignee)}
+		}
+
+		// comments are "date;author;body"
+		for _, value := range columns(row, "Comment") {
+			parts := strings.SplitN(value, ";", 3)
+			if len(parts) < 3 {
+				return nil, fmt.Errorf("%s row %d: can't read comment %q", path, n+2, value)
+			}
+			at, err := parseJiraTime(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("%s row %d: %w", path, n+2, err)
+			}
+			issue.Comments = append(issue.Comments, Comment{
+				Author:    users.resolve(parts[1]),
+				Content:   parts[2],
+				CreatedAt: at,
+				UpdatedAt: at,
+			})
+		}
+
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
+
+func parseJiraTime(value string) (time.Time, error) {
+	for _, layout := range jiraTimeLayouts {
+		t, err := time.Parse(layout, strings.TrimSpace(value))
+		if err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't read the date %q", value)
+}
+
+func jiraPriority(name string) issuePriority {
+	switch strings.ToLower(name) {
+	case "highest", "blocker", "critical":
+		return urgentPriority
+	case "high", "major":
+		return highPriority
+	case "medium":
+		return mediumPriority
+	case "low", "lowest", "minor", "trivial":
+		return lowPriority
+	default:
+		return noPriority
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const githubExport = `[
+  {
+    "number": 12,
+    "title": "Crash on start",
+    "body": "It crashes.",
+    "state": "closed",
+    "state_reason": "not_planned",
+    "html_url": "https://github.com/acme/app/issues/12",
+    "user": {"login": "octocat"},
+    "assignees": [{"login": "hubot"}],
+    "labels": [{"name": "bug"}, {"name": "good first issue"}],
+    "comments": 1,
+    "created_at": "2023-01-02T03:04:05Z",
+    "updated_at": "2023-01-03T00:00:00Z"
+  },
+  {
+    "number": 13,
+    "title": "Add dark mode",
+    "body": "",
+    "state": "OPEN",
+    "url": "https://github.com/acme/app/issues/13",
+    "author": {"login": "hubot"},
+    "labels": [{"name": "feature"}],
+    "comments": [{"author": {"login": "octocat"}, "body": "+1", "createdAt": "2023-02-01T00:00:00Z"}],
+    "createdAt": "2023-01-05T00:00:00Z",
+    "updatedAt": "2023-02-01T00:00:00Z"
+  },
+  {"number": 14, "title": "A pull request", "pull_request": {"url": "x"}}
+]`
+
+func TestGithubImporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "issues.json")
+	require.NoError(t, os.WriteFile(path, []byte(githubExport), 0o644))
+
+	importer, err := findImporter("", path)
+	require.NoError(t, err)
+	issues, err := importer.read(path, userMap{"octocat": "octo@example.com"}, defaultWorkflow())
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+
+	assert.Equal(t, "octo@example.com", issues[0].Author)
+	assert.Equal(t, []string{"hubot"}, issues[0].Assignees)
+	assert.Equal(t, []string{"bug", "good-first-issue"}, issues[0].Labels)
+	assert.Equal(t, wontDo, issues[0].Status)
+	assert.Empty(t, issues[0].Comments)
+	assert.Equal(t, ImportedFrom{
+		Source:    "github",
+		Id:        "https://github.com/acme/app/issues/12",
+		Key:       "#12",
+		URL:       "https://github.com/acme/app/issues/12",
+		UpdatedAt: time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC),
+	}, *issues[0].Imported)
+
+	assert.Equal(t, "hubot", issues[1].Author)
+	assert.Equal(t, todo, issues[1].Status)
+	require.Len(t, issues[1].Comments, 1)
+	assert.Equal(t, "octo@example.com", issues[1].Comments[0].Author)
+	assert.Equal(t, "+1", issues[1].Comments[0].Content)
+}
+
+const gitlabIssues = `{"id": 501, "iid": 1, "title": "Login broken", "description": "Can't log in", "state": "opened", "author_id": 7, "label_links": [{"label": {"title": "Bug"}}], "issue_assignees": [{"user_id": 8}], "notes": [{"note": "changed the description", "author_id": 7, "system": true, "created_at": "2023-03-01T10:00:00Z"}, {"note": "Same here", "author_id": 8, "created_at": "2023-03-02T10:00:00Z"}], "created_at": "2023-03-01T09:00:00Z", "updated_at": "2023-03-02T10:00:00Z"}
+{"id": 502, "iid": 2, "title": "Old thing", "state": "closed", "author_id": 9, "created_at": "2022-01-01T00:00:00Z", "updated_at": "2022-01-02T00:00:00Z"}
+`
+
+const gitlabMembers = `{"user_id": 7, "user": {"username": "root", "email": "root@example.com"}}
+{"user_id": 8, "user": {"username": "jdoe"}}
+`
+
+func TestGitlabImporter(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "tree", "project"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, gitlabIssuesFile), []byte(gitlabIssues), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, gitlabMembersFile), []byte(gitlabMembers), 0o644))
+
+	archive := filepath.Join(t.TempDir(), "export.tar.gz")
+	file, err := os.Create(archive)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{gitlabIssuesFile: gitlabIssues, gitlabMembersFile: gitlabMembers} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, file.Close())
+
+	for _, path := range []string{dir, archive, filepath.Join(dir, gitlabIssuesFile)} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			importer, err := findImporter("", path)
+			require.NoError(t, err)
+			issues, err := importer.read(path, userMap{"jdoe": "jane@example.com"}, defaultWorkflow())
+			require.NoError(t, err)
+			require.Len(t, issues, 2)
+
+			assert.Equal(t, "root@example.com", issues[0].Author)
+			assert.Equal(t, []string{"Bug"}, issues[0].Labels)
+			assert.Equal(t, []string{"jane@example.com"}, issues[0].Assignees)
+			assert.Equal(t, todo, issues[0].Status)
+			require.Len(t, issues[0].Comments, 1, "system notes are left out")
+			assert.Equal(t, "jane@example.com", issues[0].Comments[0].Author)
+			assert.Equal(t, "#1", issues[0].Imported.Key)
+			assert.Equal(t, "501", issues[0].Imported.Id)
+
+			assert.Equal(t, done, issues[1].Status)
+			assert.Equal(t, "9", issues[1].Author, "people who aren't members keep their id")
+		})
+	}
+}
+
+const jiraExport = `Summary,Issue key,Issue id,Status,Resolution,Priority,Reporter,Assignee,Created,Updated,Description,Labels,Labels,Comment,Comment
+Payment fails,PAY-1,10001,In Progress,,High,jsmith,,01/Feb/24 9:30 AM,03/Feb/24 2:15 PM,"Card is declined; retry, then fail",payments,urgent,"02/Feb/24 10:00 AM;adoe;Seeing it too; on mobile",
+Legacy export,PAY-2,10002,Closed,Won't Do,Lowest,adoe,jsmith,01/Jan/24 8:00 AM,02/Jan/24 8:00 AM,,,,,
+`
+
+func TestJiraImporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jira.csv")
+	require.NoError(t, os.WriteFile(path, []byte(jiraExport), 0o644))
+
+	importer, err := findImporter("", path)
+	require.NoError(t, err)
+	issues, err := importer.read(path, userMap{"jsmith": "john@example.com"}, defaultWorkflow())
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+
+	assert.Equal(t, "Payment fails", issues[0].Title)
+	assert.Equal(t, "john@example.com", issues[0].Author)
+	assert.Equal(t, inProgress, issues[0].Status)
+	assert.Equal(t, highPriority, issues[0].Priority)
+	assert.Equal(t, []string{"payments", "urgent"}, issues[0].Labels)
+	assert.Equal(t, time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC), issues[0].CreatedAt)
+	require.Len(t, issues[0].Comments, 1)
+	assert.Equal(t, "adoe", issues[0].Comments[0].Author)
+	assert.Equal(t, "Seeing it too; on mobile", issues[0].Comments[0].Content)
+	assert.Equal(t, ImportedFrom{Source: "jira", Id: "10001", Key: "PAY-1", UpdatedAt: time.Date(2024, 2, 3, 14, 15, 0, 0, time.UTC)}, *issues[0].Imported)
+
+	assert.Equal(t, wontDo, issues[1].Status)
+	assert.Equal(t, lowPriority, issues[1].Priority)
+	assert.Equal(t, []string{"john@example.com"}, issues[1].Assignees)
+
+	_, err = findImporter("", filepath.Join(t.TempDir(), "issues.xml"))
+	assert.ErrorContains(t, err, "--from github|gitlab|jira")
+}
+
+func TestImportIssues(t *testing.T) {
+	store := newMemoryStore()
+	updated := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	imported := func(title string, updatedAt time.Time, comments ...Comment) Issue {
+		return Issue{
+			Author:    "bob@example.com",
+			Title:     title,
+			Status:    todo,
+			Comments:  comments,
+			UpdatedAt: updatedAt,
+			Imported:  &ImportedFrom{Source: "jira", Id: "10001", Key: "PAY-1", UpdatedAt: updatedAt},
+		}
+	}
+	first := Comment{Author: "bob@example.com", Content: "first", CreatedAt: updated}
+
+	report, err := importIssues(store, []Issue{imported("Payment fails", updated, first)}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "would import 1 issue(s): 1 new, 0 updated, 0 unchanged", report.String())
+	issues, err := store.Issues()
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+
+	report, err = importIssues(store, []Issue{imported("Payment fails", updated, first)}, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	issues, err = store.Issues()
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	original := issues[0]
+	assert.NotEmpty(t, original.Shortcode)
+
+	report, err = importIssues(store, []Issue{imported("Payment fails", updated, first)}, false)
+	require.NoError(t, err)
+	assert.Equal(t, importReport{Unchanged: 1}, report, "importing the same export again changes nothing")
+
+	// someone comments in ubik, then the issue changes in Jira
+	local := Comment{Author: "alice@example.com", Content: "looking", CreatedAt: updated.Add(time.Hour)}
+	original.Comments = append(original.Comments, local)
+	require.NoError(t, store.SaveIssue(original))
+	later := updated.Add(24 * time.Hour)
+	second := Comment{Author: "bob@example.com", Content: "second", CreatedAt: later}
+	report, err = importIssues(store, []Issue{imported("Payment always fails", later, first, second)}, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Updated)
+
+	issue := storedIssue(t, store, original.Id)
+	assert.Equal(t, "Payment always fails", issue.Title)
+	assert.Equal(t, original.Shortcode, issue.Shortcode)
+	assert.Equal(t, []string{"first", "looking", "second"}, convertSlice(issue.Comments, func(c Comment) string { return c.Content }))
+	assert.Equal(t, later, issue.Imported.UpdatedAt)
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/muesli/reflow/truncate"
+)
+
+// The inbox lists what other people did that concerns you: mentions of you
+// in descriptions and comments, assigning you, and status changes on issues
+// you're involved in (you opened, are assigned to, commented on or were
+// mentioned in). It's worked out from the issues and their history each
+// time they're loaded. Which notifications you've read is kept per user in
+// the git directory, so it never syncs.
+
+// mentionPattern matches @alice, @alice.smith and @alice@example.com.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([\w.+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// parseMentions returns everyone mentioned in text, in order, once each.
+func parseMentions(text string) []string {
+	var mentions []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		mention := strings.TrimRight(match[1], ".")
+		if mention != "" && !slices.Contains(mentions, mention) {
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions
+}
+
+type inboxUser struct {
+	Email string
+	Name  string
+}
+
+// isMentioned reports whether text mentions user by email, by the part of
+// their email before the @, or by their name written without spaces.
+func (u inboxUser) isMentioned(text string) bool {
+	local, _, _ := strings.Cut(u.Email, "@")
+	handles := []string{u.Email, local, strings.ReplaceAll(u.Name, " ", "")}
+	for _, mention := range parseMentions(text) {
+		for _, handle := range handles {
+			if handle != "" && strings.EqualFold(mention, handle) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (u inboxUser) is(email string) bool {
+	return strings.EqualFold(u.Email, email)
+}
+
+func (u inboxUser) isInvolved(issue Issue) bool {
+	if u.is(issue.Author) || u.isMentioned(issue.Description) {
+		return true
+	}
+	if slices.ContainsFunc(issue.Assignees, u.is) {
+		return true
+	}
+	return slices.ContainsFunc(issue.Comments, func(c Comment) bool {
+		return u.is(c.Author) || u.isMentioned(c.Content)
+	})
+}
+
+type notificationKind string
+
+const (
+	mentionNotification notificationKind = "mention"
+	assignNotification  notificationKind = "assign"
+	statusNotification  notificationKind = "status"
+)
+
+type notification struct {
+	// Key identifies the notification for its read state
+	Key        string
+	Kind       notificationKind
+	IssueId    string
+	Shortcode  string
+	Title      string
+	Actor      string
+	Summary    string
+	CommentKey string
+	At         time.Time
+	Read       bool
+}
+
+func (n notification) FilterValue() string {
+	return fmt.Sprintf("%s\n%s\n%s", n.Title, n.Summary, n.Shortcode)
+}
+
+func (n notification) Height() int                             { return 2 }
+func (n notification) Spacing() int                            { return 1 }
+func (n notification) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+
+func (n notification) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	n, ok := listItem.(notification)
+	if !ok {
+		return
+	}
+
+	defaultItemStyles := list.NewDefaultItemStyles()
+
+	titleFn := defaultItemStyles.NormalTitle.Padding(0).Render
+	if index == m.Index() {
+		titleFn = func(s ...string) string {
+			return defaultItemStyles.SelectedTitle.
+				Border(lipgloss.NormalBorder(), false, false, false, false).
+				Padding(0).
+				Render(strings.Join(s, " "))
+		}
+	}
+
+	marker := " "
+	if !n.Read {
+		marker = lipgloss.NewStyle().Foreground(styles.Theme.RedText).Render("●")
+	}
+	title := fmt.Sprintf("%s %s", marker, titleFn(truncate.StringWithTail(fmt.Sprintf("#%s %s", n.Shortcode, n.Title), 50, "...")))
+	description := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render(fmt.Sprintf(
+		"  %s %s on %s",
+		n.Actor,
+		n.Summary,
+		n.At.Local().Format(time.DateTime),
+	))
+
+	fmt.Fprint(w, lipgloss.JoinVertical(lipgloss.Left, title, description))
+}
+
+// issueNotifications works out user's notifications for one issue.
+func issueNotifications(issue Issue, history []IssueRevision, user inboxUser) []notification {
+	var notifications []notification
+	add := func(n notification) {
+		n.IssueId, n.Shortcode, n.Title = issue.Id, issue.Shortcode, issue.Title
+		notifications = append(notifications, n)
+	}
+
+	if !user.is(issue.Author) && user.isMentioned(issue.Description) {
+		add(notification{
+			Key:     fmt.Sprintf("%s mention", issue.Id),
+			Kind:    mentionNotification,
+			Actor:   issue.Author,
+			Summary: "mentioned you",
+			At:      issue.CreatedAt,
+		})
+	}
+	for _, comment := range issue.Comments {
+		if comment.Deleted || user.is(comment.Author) || !user.isMentioned(comment.Content) {
+			continue
+		}
+		add(notification{
+			Key:        fmt.Sprintf("%s mention %s", issue.Id, commentKey(comment)),
+			Kind:       mentionNotification,
+			Actor:      comment.Author,
+			Summary:    "mentioned you in a comment",
+			CommentKey: commentKey(comment),
+			At:         comment.CreatedAt,
+		})
+	}
+
+	if !user.isInvolved(issue) {
+		return notifications
+	}
+
+	// Each revision is compared with its own parent rather than whatever
+	// came before it in time, which after a sync can be someone's concurrent
+	// edit on another clone. Merges are skipped: whoever synced didn't make
+	// the changes they bring in, and the revisions that did are in history
+	// themselves.
+	for _, after := range history {
+		if after.Previous == nil || after.Merge || user.is(after.Author) {
+			continue
+		}
+		before := *after.Previous
+		if !slices.ContainsFunc(before.Assignees, user.is) && slices.ContainsFunc(after.Issue.Assignees, user.is) {
+			add(notification{
+				Key:     fmt.Sprintf("%s assign %s", issue.Id, after.Hash),
+				Kind:    assignNotification,
+				Actor:   after.Author,
+				Summary: "assigned you",
+				At:      after.Timestamp,
+			})
+		}
+		if before.Status != after.Issue.Status {
+			add(notification{
+				Key:     fmt.Sprintf("%s status %s", issue.Id, after.Hash),
+				Kind:    statusNotification,
+				Actor:   after.Author,
+				Summary: fmt.Sprintf("moved it to %s", after.Issue.Status),
+				At:      after.Timestamp,
+			})
+		}
+	}
+
+	return notifications
+}
+
+// inboxStatePath is where read notifications are kept: inside the git
+// directory, out of the worktree and out of sync.
+func inboxStatePath(repo *git.Repository) string {
+	if repo != nil {
+		if storage, ok := repo.Storer.(*filesystem.Storage); ok {
+			return filepath.Join(storage.Filesystem().Root(), "ubik", "inbox.json")
+		}
+	}
+	return ""
+}
+
+// inboxState maps each user's email to the keys of the notifications
+// they've read.
+type inboxState map[string][]string
+
+func readInboxState(path string) (inboxState, error) {
+	state := make(inboxState)
+	if path == "" {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+type inboxReadyMsg struct {
+	Notifications []notification
+}
+
+func getInbox(store IssueStore, statePath string, user inboxUser) tea.Cmd {
+	return func() tea.Msg {
+		issues, err := store.Issues()
+		if err != nil {
+			debug("%#v", err.Error())
+			return err
+		}
+		state, err := readInboxState(statePath)
+		if err != nil {
+			debug("%#v", err.Error())
+			return err
+		}
+
+		var notifications []notification
+		for _, issue := range issues {
+			if !issue.DeletedAt.IsZero() || user.Email == "" {
+				continue
+			}
+			var history []IssueRevision
+			if user.isInvolved(issue) {
+				history, err = store.IssueHistory(issue.Id)
+				if err != nil {
+					debug("%#v", err.Error())
+					return err
+				}
+			}
+			notifications = append(notifications, issueNotifications(issue, history, user)...)
+		}
+
+		read := state[strings.ToLower(user.Email)]
+		for i, n := range notifications {
+			notifications[i].Read = slices.Contains(read, n.Key)
+		}
+		slices.SortStableFunc(notifications, func(a, b notification) int {
+			return b.At.Compare(a.At)
+		})
+
+		return inboxReadyMsg{Notifications: notifications}
+	}
+}
+
+// saveInboxRead records which of user's notifications are read, leaving
+// everyone else's alone.
+func saveInboxRead(statePath, email string, read []string) tea.Cmd {
+	return func() tea.Msg {
+		if statePath == "" {
+			return nil
+		}
+		state, err := readInboxState(statePath)
+		if err != nil {
+			debug("%#v", err.Error())
+			return err
+		}
+		state[strings.ToLower(email)] = read
+
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(filepath.Dir(statePath), 0o755)
+		if err == nil {
+			err = os.WriteFile(statePath, data, 0o644)
+		}
+		if err != nil {
+			debug("%#v", err.Error())
+			return err
+		}
+		return nil
+	}
+}
+
+func (m Model) inboxUser() inboxUser {
+	return inboxUser{Email: m.gitConfig.User.Email, Name: m.gitConfig.User.Name}
+}
+
+func (m Model) loadInbox() tea.Cmd {
+	return getInbox(m.store, m.inboxPath, m.inboxUser())
+}
+
+func (m Model) notifications() []notification {
+	return convertSlice(m.inboxIndex.Items(), func(item list.Item) notification {
+		return item.(notification)
+	})
+}
+
+func (m Model) unreadCount() int {
+	var unread int
+	for _, n := range m.notifications() {
+		if !n.Read {
+			unread++
+		}
+	}
+	return unread
+}
+
+// markRead sets the read state of the notifications that match and saves
+// it.
+func (m *Model) markRead(read bool, matches func(notification) bool) tea.Cmd {
+	var keys []string
+	for i, n := range m.notifications() {
+		if matches(n) {
+			n.Read = read
+			m.inboxIndex.SetItem(i, n)
+		}
+		if n.Read {
+			keys = append(keys, n.Key)
+		}
+	}
+	return saveInboxRead(m.inboxPath, m.gitConfig.User.Email, keys)
+}
+
+// openNotification marks n read and shows its issue, with the comment that
+// mentioned you under the cursor.
+func (m Model) openNotification(n notification) (Model, tea.Cmd) {
+	cmd := m.markRead(true, func(other notification) bool { return other.Key == n.Key })
+
+	m.issueIndex.ResetFilter()
+	i := slices.IndexFunc(m.issueIndex.Items(), func(item list.Item) bool { return item.(Issue).Id == n.IssueId })
+	if i < 0 {
+		m.flash = fmt.Sprintf("#%s is gone", n.Shortcode)
+		m.UpdateLayout(m.layout.TerminalSize)
+		return m, cmd
+	}
+	m.issueIndex.Select(i)
+	m.path = issuesShowPath
+	m.UpdateLayout(m.layout.TerminalSize)
+	m.issueShow = m.newIssueShow(m.linkedIssue(m.issueIndex.SelectedItem().(Issue)))
+	if n.CommentKey != "" && findComment(m.issueShow.issue.Comments, n.CommentKey) >= 0 {
+		m.issueShow = m.selectComment(n.CommentKey)
+	}
+	return m, cmd
+}
+
+func inboxIndexHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	if m.inboxIndex.SettingFilter() {
+		m.inboxIndex, cmd = m.inboxIndex.Update(msg)
+		return m, cmd
+	}
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+			return m, nil
+		case key.Matches(msg, keys.InboxOpen):
+			selectedItem := m.inboxIndex.SelectedItem()
+			if selectedItem == nil {
+				return m, nil
+			}
+			return m.openNotification(selectedItem.(notification))
+		case key.Matches(msg, keys.InboxToggleRead):
+			selectedItem := m.inboxIndex.SelectedItem()
+			if selectedItem == nil {
+				return m, nil
+			}
+			selected := selectedItem.(notification)
+			return m, m.markRead(!selected.Read, func(n notification) bool { return n.Key == selected.Key })
+		case key.Matches(msg, keys.InboxReadAll):
+			return m, m.markRead(true, func(notification) bool { return true })
+		case key.Matches(msg, keys.NextPage):
+			m.path = issuesIndexPath
+			return m, nil
+		case key.Matches(msg, keys.PrevPage):
+			m.path = trashIndexPath
+			return m, nil
+		}
+	}
+
+	m.inboxIndex, cmd = m.inboxIndex.Update(msg)
+	return m, cmd
+}
+
+func (m Model) renderInboxView() string {
+	left := m.inboxIndex.View()
+	if len(m.inboxIndex.Items()) == 0 {
+		left = lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render("Nothing in your inbox.")
+	}
+	return m.renderMainLayout(m.renderTabs("Inbox"), left, "", m.footerView())
+}
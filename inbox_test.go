@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	assert.Equal(t,
+		[]string{"alice", "bob@example.com", "carol.smith"},
+		parseMentions("@alice and @bob@example.com, cc @carol.smith. Not me@example.com or @alice again"),
+	)
+	assert.Empty(t, parseMentions("mail someone@example.com"))
+}
+
+func TestIsMentioned(t *testing.T) {
+	user := inboxUser{Email: "alice.smith@example.com", Name: "Alice Smith"}
+
+	assert.True(t, user.isMentioned("ping @alice.smith@example.com"))
+	assert.True(t, user.isMentioned("ping @Alice.Smith"))
+	assert.True(t, user.isMentioned("ping @AliceSmith"))
+	assert.False(t, user.isMentioned("ping @alice"))
+	assert.False(t, user.isMentioned("alice.smith@example.com wrote this"))
+}
+
+func TestIssueNotificationsAcrossMerges(t *testing.T) {
+	alice := inboxUser{Email: "alice@example.com", Name: "Alice"}
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	created := Issue{Id: "abc", Title: "Shared", Status: todo, Author: "alice@example.com"}
+	revision := func(hash, author string, minutes int, issue Issue, previous *Issue, merge bool) IssueRevision {
+		return IssueRevision{
+			Hash:      hash,
+			Author:    author,
+			Timestamp: at.Add(time.Duration(minutes) * time.Minute),
+			Issue:     issue,
+			Previous:  previous,
+			Merge:     merge,
+		}
+	}
+	with := func(change func(*Issue)) Issue {
+		issue := created
+		change(&issue)
+		return issue
+	}
+
+	renamed := with(func(i *Issue) { i.Title = "Renamed" })
+	bobDone := with(func(i *Issue) { i.Status = done })
+	bobLabel := with(func(i *Issue) { i.Labels = []string{"bug"} })
+	bobAssign := with(func(i *Issue) { i.Assignees = []string{"alice@example.com"} })
+	aliceDone := with(func(i *Issue) { i.Status = done })
+	renamedDone := with(func(i *Issue) { i.Title = "Renamed"; i.Status = done })
+	labelDone := with(func(i *Issue) { i.Labels = []string{"bug"}; i.Status = done })
+	renamedAssign := with(func(i *Issue) { i.Title = "Renamed"; i.Assignees = []string{"alice@example.com"} })
+
+	tests := []struct {
+		name    string
+		history []IssueRevision
+		want    []string
+	}{
+		{
+			name: "a status change alice's sync merged in is bob's",
+			history: []IssueRevision{
+				revision("merge", "alice@example.com", 3, renamedDone, &renamed, true),
+				revision("rename", "alice@example.com", 2, renamed, &created, false),
+				revision("done", "bob@example.com", 1, bobDone, &created, false),
+				revision("create", "alice@example.com", 0, created, nil, false),
+			},
+			want: []string{"bob@example.com moved it to done"},
+		},
+		{
+			name: "concurrent edits aren't compared with each other",
+			history: []IssueRevision{
+				revision("merge", "alice@example.com", 3, labelDone, &aliceDone, true),
+				revision("label", "bob@example.com", 2, bobLabel, &created, false),
+				revision("done", "alice@example.com", 1, aliceDone, &created, false),
+				revision("create", "alice@example.com", 0, created, nil, false),
+			},
+		},
+		{
+			name: "bob's sync merging alice's own change",
+			history: []IssueRevision{
+				revision("merge", "bob@example.com", 3, labelDone, &bobLabel, true),
+				revision("label", "bob@example.com", 2, bobLabel, &created, false),
+				revision("done", "alice@example.com", 1, aliceDone, &created, false),
+				revision("create", "alice@example.com", 0, created, nil, false),
+			},
+		},
+		{
+			name: "an assignment made on another clone",
+			history: []IssueRevision{
+				revision("merge", "alice@example.com", 3, renamedAssign, &renamed, true),
+				revision("rename", "alice@example.com", 2, renamed, &created, false),
+				revision("assign", "bob@example.com", 1, bobAssign, &created, false),
+				revision("create", "alice@example.com", 0, created, nil, false),
+			},
+			want: []string{"bob@example.com assigned you"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, n := range issueNotifications(tt.history[0].Issue, tt.history, alice) {
+				got = append(got, n.Actor+" "+n.Summary)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
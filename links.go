@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Commit messages point at issues with "#<shortcode>" anywhere in the
+// message, or say they fix one with a trailer:
+//
+//	Fixes: #uU0nXq
+//	Closes: #uU0nXq, #Zk3m_a
+//
+// References shorter than a shortcode are ignored so that things like
+// "Merge pull request #12" don't link to whatever issue happens to start
+// with "12". Colours like "#ff0000" or "#ff000080" look just like shortcodes
+// made only of hex digits, so outside a fixes trailer a reference of six or
+// eight hex digits only links to an issue whose shortcode or id it matches
+// exactly, never to one it's merely a prefix of.
+var (
+	issueRefPattern     = regexp.MustCompile(fmt.Sprintf(`(?:^|[^\w&/])#([A-Za-z0-9_-]{%d,})`, minShortcodeLength))
+	fixesTrailerPattern = regexp.MustCompile(`(?im)^\s*(?:fix|fixes|fixed|close|closes|closed|resolve|resolves|resolved):(.*)$`)
+	hexColorPattern     = regexp.MustCompile(`^(?:[0-9A-Fa-f]{6}|[0-9A-Fa-f]{8})$`)
+)
+
+// IssueRef is an unresolved reference to an issue found in a commit message.
+type IssueRef struct {
+	Ref   string `json:"ref"`
+	Fixes bool   `json:"fixes"`
+}
+
+// parseIssueRefs finds the issues a commit message refers to, once each.
+func parseIssueRefs(message string) []IssueRef {
+	fixes := make(map[string]bool)
+	for _, trailer := range fixesTrailerPattern.FindAllStringSubmatch(message, -1) {
+		for _, match := range issueRefPattern.FindAllStringSubmatch(trailer[1], -1) {
+			fixes[match[1]] = true
+		}
+	}
+
+	var refs []IssueRef
+	for _, match := range issueRefPattern.FindAllStringSubmatch(message, -1) {
+		ref := match[1]
+		if slices.ContainsFunc(refs, func(r IssueRef) bool { return r.Ref == ref }) {
+			continue
+		}
+		refs = append(refs, IssueRef{Ref: ref, Fixes: fixes[ref]})
+	}
+	return refs
+}
+
+// resolveCommitRef finds the issue a commit message's reference points at.
+func resolveCommitRef(issues []Issue, ref IssueRef) (Issue, error) {
+	if ref.Fixes || !hexColorPattern.MatchString(ref.Ref) {
+		return resolveIssueRef(issues, ref.Ref)
+	}
+	for _, issue := range issues {
+		if issue.Shortcode == ref.Ref || issue.Id == ref.Ref {
+			return issue, nil
+		}
+	}
+	return Issue{}, fmt.Errorf("%w: #%s", errIssueNotFound, ref.Ref)
+}
+
+// IssueLink connects a commit with an issue its message refers to. Links are
+// worked out from the commit log whenever it or the issues are loaded and
+// are never stored.
+type IssueLink struct {
+	CommitHash    string
+	CommitSummary string
+	IssueId       string
+	Shortcode     string
+	Title         string
+	Status        issueStatus
+	Fixes         bool
+}
+
+func commitSummary(message string) string {
+	summary, _, _ := strings.Cut(message, "\n")
+	return summary
+}
+
+// linkCommits resolves the commits' issue references against issues and
+// fills in Issue.LinkedCommits and Commit.ReferencedIssues on both. Ambiguous
+// and unknown references are left out.
+func linkCommits(issues []Issue, commits []Commit) ([]Issue, []Commit) {
+	issues = slices.Clone(issues)
+	commits = slices.Clone(commits)
+
+	positions := make(map[string]int, len(issues))
+	for i := range issues {
+		issues[i].LinkedCommits = nil
+		positions[issues[i].Id] = i
+	}
+
+	for i := range commits {
+		commits[i].ReferencedIssues = nil
+		for _, ref := range commits[i].IssueRefs {
+			issue, err := resolveCommitRef(issues, ref)
+			if err != nil {
+				continue
+			}
+			link := IssueLink{
+				CommitHash:    commits[i].Hash,
+				CommitSummary: commitSummary(commits[i].Message),
+				IssueId:       issue.Id,
+				Shortcode:     issue.Shortcode,
+				Title:         issue.Title,
+				Status:        issue.Status,
+				Fixes:         ref.Fixes,
+			}
+			commits[i].ReferencedIssues = append(commits[i].ReferencedIssues, link)
+			position := positions[issue.Id]
+			issues[position].LinkedCommits = append(issues[position].LinkedCommits, link)
+		}
+	}
+
+	return issues, commits
+}
+
+func (m Model) loadedIssues() []Issue {
+	return convertSlice(m.issueIndex.Items(), func(item list.Item) Issue {
+		return item.(Issue)
+	})
+}
+
+// relinkCommits recomputes the links between the loaded issues and commits,
+// the relationships between issues and the milestones' progress, and re-sorts
+// the issues in the chosen order, keeping the selection.
+func (m *Model) relinkCommits() {
+	commits := convertSlice(m.commitIndex.Items(), func(item list.Item) Commit {
+		return item.(Commit)
+	})
+
+	issues, milestones := linkMilestones(linkRelations(m.loadedIssues()), m.loadedMilestones(), m.workflow)
+	issues, commits = linkCommits(issues, commits)
+
+	selected, _ := m.issueIndex.SelectedItem().(Issue)
+	issues = sortIssuesBy(issues, m.issueSort, m.workflow)
+	m.issueIndex.SetItems(convertSlice(issues, func(issue Issue) list.Item {
+		return list.Item(issue)
+	}))
+	if i := slices.IndexFunc(issues, func(issue Issue) bool { return issue.Id == selected.Id }); i >= 0 && selected.Id != "" {
+		m.issueIndex.Select(i)
+	}
+	m.commitIndex.SetItems(convertSlice(commits, func(commit Commit) list.Item {
+		return list.Item(commit)
+	}))
+	m.setMilestones(milestones)
+	m.refreshMilestoneShow()
+}
+
+// linkedIssue returns the loaded copy of an issue, carrying its links.
+func (m Model) linkedIssue(issue Issue) Issue {
+	for _, item := range m.issueIndex.Items() {
+		if loaded := item.(Issue); loaded.Id == issue.Id {
+			return loaded
+		}
+	}
+	return issue
+}
+
+func renderLinkedCommits(links []IssueLink) string {
+	var b strings.Builder
+	faint := lipgloss.NewStyle().Foreground(styles.Theme.FaintText)
+	b.WriteString(faint.Render("Linked commits") + "\n")
+	for _, link := range links {
+		line := fmt.Sprintf("%s %s", faint.Render(link.CommitHash[:8]), link.CommitSummary)
+		if link.Fixes {
+			line += faint.Render(" (fixes)")
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+func renderReferencedIssues(links []IssueLink, w Workflow) string {
+	var b strings.Builder
+	faint := lipgloss.NewStyle().Foreground(styles.Theme.FaintText)
+	b.WriteString(faint.Render("Referenced issues") + "\n")
+	for _, link := range links {
+		identifier := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render("#" + link.Shortcode)
+		line := fmt.Sprintf("%s %s %s", w.icon(link.Status), identifier, link.Title)
+		if link.Fixes {
+			line += faint.Render(" (fixes)")
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// Auto-closing is opt-in, per clone:
+//
+//	git config ubik.autoClose true
+//	git config ubik.mainBranch trunk   # defaults to main, then master
+func autoCloseEnabled(repo *git.Repository) bool {
+	cfg, err := repo.Config()
+	if err != nil {
+		return false
+	}
+	return cfg.Raw.Section("ubik").Option("autoClose") == "true"
+}
+
+func mainBranch(repo *git.Repository) (*plumbing.Reference, error) {
+	candidates := []string{"main", "master"}
+	if cfg, err := repo.Config(); err == nil {
+		if branch := cfg.Raw.Section("ubik").Option("mainBranch"); branch != "" {
+			candidates = []string{branch}
+		}
+	}
+
+	var err error
+	for _, branch := range candidates {
+		var ref *plumbing.Reference
+		ref, err = repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+		if err == nil {
+			return ref, nil
+		}
+	}
+	return nil, err
+}
+
+func autoCloseComment(hash, branch string) string {
+	return fmt.Sprintf("Closed automatically: fixed by commit %s on %s.", hash, branch)
+}
+
+// closeFixedIssues moves open issues to the workflow's done status when a
+// commit on the main branch says it fixes them, leaving an automatic comment
+// that names the commit. An issue that already carries that comment was
+// reopened by hand after being closed and is left alone.
+func closeFixedIssues(repo *git.Repository, store IssueStore, author string, w Workflow) ([]Issue, error) {
+	ref, err := mainBranch(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	issues, err := store.Issues()
+	if err != nil {
+		return nil, err
+	}
+	var live []Issue
+	for _, issue := range issues {
+		if issue.DeletedAt.IsZero() {
+			live = append(live, issue)
+		}
+	}
+
+	log, err := repo.Log(&git.LogOptions{From: ref.Hash()})
+	if err != nil {
+		return nil, err
+	}
+
+	fixedBy := make(map[string]string)
+	err = log.ForEach(func(c *object.Commit) error {
+		for _, ref := range parseIssueRefs(c.Message) {
+			if !ref.Fixes {
+				continue
+			}
+			issue, err := resolveCommitRef(live, ref)
+			if err != nil {
+				continue
+			}
+			// the log runs newest first; credit the oldest fix
+			fixedBy[issue.Id] = c.Hash.String()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var closed []Issue
+	for _, issue := range live {
+		hash, ok := fixedBy[issue.Id]
+		if !ok || w.isClosed(issue.Status) {
+			continue
+		}
+		content := autoCloseComment(hash, ref.Name().Short())
+		if slices.ContainsFunc(issue.Comments, func(c Comment) bool { return c.Content == content }) {
+			continue
+		}
+
+		issue.Status = w.Done
+		issue.Comments = append(issue.Comments, Comment{Author: author, Content: content})
+		switch msg := persistIssue(issue, store)().(type) {
+		case issuePersistedMsg:
+			closed = append(closed, msg.Issue)
+		case error:
+			return closed, msg
+		}
+	}
+
+	return closed, nil
+}
+
+// issuesAutoClosedMsg ends an autoCloseIssues run, even one that closed
+// nothing, so the next run can start.
+type issuesAutoClosedMsg []Issue
+
+func autoCloseIssues(repo *git.Repository, store IssueStore, author string, w Workflow) tea.Cmd {
+	return func() tea.Msg {
+		if repo == nil || !autoCloseEnabled(repo) {
+			return issuesAutoClosedMsg(nil)
+		}
+
+		closed, err := closeFixedIssues(repo, store, author, w)
+		if err != nil {
+			debug("%#v", err.Error())
+		}
+		return issuesAutoClosedMsg(closed)
+	}
+}
+
+// startAutoClose runs autoCloseIssues unless a run is still in flight.
+// Overlapping runs would each find an issue open and both comment on it.
+func (m *Model) startAutoClose() tea.Cmd {
+	if m.repo == nil || m.autoClosing {
+		return nil
+	}
+	m.autoClosing = true
+	return autoCloseIssues(m.repo, m.store, m.gitConfig.User.Email, m.workflow)
+}
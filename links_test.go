@@ -0,0 +1,167 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIssueRefs(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected []IssueRef
+	}{
+		{"Tidy up", nil},
+		{"Merge pull request #12 from fork", nil},
+		{"Start on #uU0nXq", []IssueRef{{Ref: "uU0nXq"}}},
+		{"See https://example.com/page#anchor123", nil},
+		{"Handle empty input\n\nFixes: #uU0nXq", []IssueRef{{Ref: "uU0nXq", Fixes: true}}},
+		{
+			"Rework login (#Zk3m_a)\n\ncloses: #uU0nXq, #Zk3m_a\nFixes #qqqqqq",
+			[]IssueRef{{Ref: "Zk3m_a", Fixes: true}, {Ref: "uU0nXq", Fixes: true}, {Ref: "qqqqqq"}},
+		},
+		{"Use #ff0000 for errors and #3B875E80 for success", []IssueRef{{Ref: "ff0000"}, {Ref: "3B875E80"}}},
+		{"Fixes: #abc123", []IssueRef{{Ref: "abc123", Fixes: true}}},
+		{"Start on #ff0000a3c", []IssueRef{{Ref: "ff0000a3c"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseIssueRefs(tt.message))
+		})
+	}
+}
+
+func TestLinkCommits(t *testing.T) {
+	issues := []Issue{
+		{Id: "one", Shortcode: "uU0nXq", Title: "Login fails", Status: todo},
+		{Id: "two", Shortcode: "Zk3m_a", Title: "Slow search", Status: inProgress},
+	}
+	commits := []Commit{
+		{Hash: "1111111111", Message: "Fix login\n\nFixes: #uU0nXq", IssueRefs: parseIssueRefs("Fixes: #uU0nXq")},
+		{Hash: "2222222222", Message: "Profile #Zk3m_a and #nothere", IssueRefs: parseIssueRefs("#Zk3m_a #nothere")},
+	}
+
+	issues, commits = linkCommits(issues, commits)
+
+	require.Len(t, issues[0].LinkedCommits, 1)
+	assert.Equal(t, "Fix login", issues[0].LinkedCommits[0].CommitSummary)
+	assert.True(t, issues[0].LinkedCommits[0].Fixes)
+	require.Len(t, issues[1].LinkedCommits, 1)
+	assert.False(t, issues[1].LinkedCommits[0].Fixes)
+
+	require.Len(t, commits[1].ReferencedIssues, 1, "unknown references are dropped")
+	assert.Equal(t, "Slow search", commits[1].ReferencedIssues[0].Title)
+
+	m, _ := newTestModel(t, issues[0])
+	m = update(t, m, CommitListReadyMsg(commits))
+	m, _ = press(t, m, "enter")
+	assert.Contains(t, m.issueShow.viewport.View(), "Linked commits")
+	assert.Contains(t, m.issueShow.viewport.View(), "11111111 Fix login")
+}
+
+func TestLinkCommitsHexShortcode(t *testing.T) {
+	issues := []Issue{
+		{Id: "one", Shortcode: "abc123", Title: "All hex"},
+		{Id: "two", Shortcode: "ff0000zz", Title: "Starts like a colour"},
+	}
+	commits := []Commit{
+		{Hash: "1111111111", Message: "Start on #abc123", IssueRefs: parseIssueRefs("Start on #abc123")},
+		{Hash: "2222222222", Message: "Use #ff0000 for errors", IssueRefs: parseIssueRefs("Use #ff0000 for errors")},
+	}
+
+	issues, commits = linkCommits(issues, commits)
+
+	require.Len(t, commits[0].ReferencedIssues, 1)
+	assert.Equal(t, "All hex", commits[0].ReferencedIssues[0].Title)
+	assert.Empty(t, commits[1].ReferencedIssues, "a colour doesn't link to an issue it's a prefix of")
+	assert.Empty(t, issues[1].LinkedCommits)
+}
+
+func commitOnBranch(t *testing.T, repo *git.Repository, branch, message string) plumbing.Hash {
+	t.Helper()
+
+	tree, err := storeObject(repo, &object.Tree{})
+	require.NoError(t, err)
+	commit := &object.Commit{
+		Author:    object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()},
+		Committer: object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()},
+		Message:   message,
+		TreeHash:  tree,
+	}
+	name := plumbing.NewBranchReferenceName(branch)
+	if ref, err := repo.Reference(name, true); err == nil {
+		commit.ParentHashes = []plumbing.Hash{ref.Hash()}
+	}
+	hash, err := storeObject(repo, commit)
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(name, hash)))
+	return hash
+}
+
+func TestCloseFixedIssues(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+
+	store := newMemoryStore()
+	require.NoError(t, store.SaveIssue(Issue{Id: "one", Shortcode: "uU0nXq", Status: todo}))
+	require.NoError(t, store.SaveIssue(Issue{Id: "two", Shortcode: "Zk3m_a", Status: todo}))
+	require.NoError(t, store.SaveIssue(Issue{Id: "three", Shortcode: "aaaaaa", Status: todo}))
+	require.NoError(t, store.SaveIssue(Issue{Id: "four", Shortcode: "abc123", Status: todo}))
+
+	fix := commitOnBranch(t, repo, "main", "Fix login\n\nFixes: #uU0nXq")
+	commitOnBranch(t, repo, "main", "Mention #Zk3m_a")
+	commitOnBranch(t, repo, "feature", "Fixes: #aaaaaa")
+	commitOnBranch(t, repo, "main", "Fix search\n\nFixes: #abc123")
+
+	closed, err := closeFixedIssues(repo, store, "alice@example.com", defaultWorkflow())
+	require.NoError(t, err)
+	require.Len(t, closed, 2)
+
+	one := storedIssue(t, store, "one")
+	assert.Equal(t, done, one.Status)
+	require.Len(t, one.Comments, 1)
+	assert.Contains(t, one.Comments[0].Content, fix.String())
+	assert.Equal(t, todo, storedIssue(t, store, "two").Status, "a mention doesn't close")
+	assert.Equal(t, todo, storedIssue(t, store, "three").Status, "fixes off the main branch don't close")
+	assert.Equal(t, done, storedIssue(t, store, "four").Status, "all-hex shortcodes close too")
+
+	one.Status = todo
+	require.NoError(t, store.SaveIssue(one))
+	closed, err = closeFixedIssues(repo, store, "alice@example.com", defaultWorkflow())
+	require.NoError(t, err)
+	assert.Empty(t, closed, "an issue reopened after closing stays open")
+}
+
+func TestAutoCloseRunsOneAtATime(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+	cfg, err := repo.Config()
+	require.NoError(t, err)
+	cfg.Raw.Section("ubik").SetOption("autoClose", "true")
+	require.NoError(t, repo.SetConfig(cfg))
+	commitOnBranch(t, repo, "main", "Fix login\n\nFixes: #uU0nXq")
+
+	m, store := newTestModel(t, Issue{Id: "one", Shortcode: "uU0nXq", Status: todo})
+	m.repo = repo
+
+	first := m.startAutoClose()
+	require.NotNil(t, first)
+	assert.Nil(t, m.startAutoClose(), "a second run waits for the first to report back")
+
+	m = update(t, m, first())
+	assert.Len(t, storedIssue(t, store, "one").Comments, 1)
+	assert.Contains(t, m.flash, "closed #uU0nXq")
+
+	again := m.startAutoClose()
+	require.NotNil(t, again, "runs start again once the first is done")
+	m = update(t, m, again())
+	assert.False(t, m.autoClosing, "a run that closes nothing still finishes")
+	assert.Len(t, storedIssue(t, store, "one").Comments, 1)
+}
@@ -2,9 +2,9 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -25,7 +25,6 @@ import (
 	"github.com/charmbracelet/log"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
-	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 
 	// "github.com/go-git/go-git/v5/storage"
@@ -63,45 +62,22 @@ type actionPersistedMsg struct {
 	IsNewAction bool
 }
 
-func persistAction(action Action, repo *git.Repository) tea.Cmd {
+func persistAction(action Action, store ActionStore) tea.Cmd {
 	return func() tea.Msg {
-		jsonData, err := json.Marshal(action)
-		if err != nil {
-			debug("%#v", err.Error())
-			return err
-		}
-
-		obj := repo.Storer.NewEncodedObject()
-		obj.SetType(plumbing.BlobObject)
-		obj.SetSize(int64(len(jsonData)))
-		writer, err := obj.Writer()
-		if err != nil {
-			debug("%#v", err.Error())
-			return err
-		}
-		_, err = writer.Write(jsonData)
-		if err != nil {
-			debug("%#v", err.Error())
-			return err
-		}
-		err = writer.Close()
-		if err != nil {
-			debug("%#v", err.Error())
-			return err
+		if signer, ok := store.(recordSigner); ok {
+			signed, err := signer.signAction(action)
+			if err != nil {
+				debug("%#v", err.Error())
+				return err
+			}
+			action = signed
 		}
 
-		hash, err := repo.Storer.SetEncodedObject(obj)
+		err := store.SaveAction(action)
 		if err != nil {
 			debug("%#v", err.Error())
 			return err
 		}
-		ref := plumbing.NewReferenceFromStrings(fmt.Sprintf("refs/ubik/actions/%s", action.Id), hash.String())
-		err = repo.Storer.SetReference(ref)
-
-		if err != nil {
-			debug("%#v", err.Error())
-			panic(err)
-		}
 		return actionPersistedMsg{Action: action}
 	}
 }
@@ -112,7 +88,7 @@ type issuePersistedMsg struct {
 	ScrollToBottom bool
 }
 
-func persistIssue(issue Issue, repo *git.Repository) tea.Cmd {
+func persistIssue(issue Issue, store IssueStore) tea.Cmd {
 	return func() tea.Msg {
 		var newIssue bool
 
@@ -123,10 +99,14 @@ func persistIssue(issue Issue, repo *git.Repository) tea.Cmd {
 		}
 
 		if newIssue {
+			existing, err := store.Issues()
+			if err != nil {
+				debug("%#v", err.Error())
+				return err
+			}
 			id := uuid.NewString()
-			shortcode := StringToShortcode(id)
 			issue.Id = id
-			issue.Shortcode = shortcode
+			issue.Shortcode = uniqueShortcode(id, existing)
 			issue.CreatedAt = time.Now().UTC()
 		}
 		issue.UpdatedAt = time.Now().UTC()
@@ -147,37 +127,16 @@ func persistIssue(issue Issue, repo *git.Repository) tea.Cmd {
 			scrollToBottom = true
 		}
 
-		jsonData, err := json.Marshal(issue)
-		if err != nil {
-			return err
-		}
-
-		obj := repo.Storer.NewEncodedObject()
-		obj.SetType(plumbing.BlobObject)
-		obj.SetSize(int64(len(jsonData)))
-		writer, err := obj.Writer()
-		if err != nil {
-			debug("%#v", err.Error())
-			return err
-		}
-		_, err = writer.Write(jsonData)
-		if err != nil {
-			debug("%#v", err.Error())
-			return err
-		}
-		err = writer.Close()
-		if err != nil {
-			debug("%#v", err.Error())
-			return err
-		}
-		hash, err := repo.Storer.SetEncodedObject(obj)
-		if err != nil {
-			debug("%#v", err.Error())
-			return err
+		if signer, ok := store.(recordSigner); ok {
+			signed, err := signer.signIssue(issue)
+			if err != nil {
+				debug("%#v", err.Error())
+				return err
+			}
+			issue = signed
 		}
-		ref := plumbing.NewReferenceFromStrings(fmt.Sprintf("refs/ubik/issues/%s", issue.Id), hash.String())
-		err = repo.Storer.SetReference(ref)
 
+		err := store.SaveIssue(issue)
 		if err != nil {
 			debug("%#v", err.Error())
 			return err
@@ -207,6 +166,29 @@ const (
 	issuesNewConfirmationPath
 	actionsIndexPath
 	actionsShowPath
+	trashIndexPath
+	trashPurgeConfirmationPath
+	issuesAttachPath
+	issuesEditRelationsPath
+	issuesNewRelationsPath
+	issuesAssignPath
+	milestonesIndexPath
+	milestonesShowPath
+	milestonesFormPath
+	issuesEditMilestonePath
+	issuesNewMilestonePath
+	issuesEditPriorityPath
+	issuesNewPriorityPath
+	issuesEditDuePath
+	issuesNewDuePath
+	issuesEditFieldsPath
+	issuesNewFieldsPath
+	issuesCommentDeletePath
+	issuesReactPath
+	inboxIndexPath
+	issuesTemplatePickerPath
+	gcConfirmationPath
+	issuesExtractPath
 )
 
 func matchRoute(currentRoute, route int) bool {
@@ -253,6 +235,8 @@ var styles = DefaultStyles()
 
 type issueStatus string
 
+// The statuses of the default workflow; a repo can define its own, see
+// workflow.go.
 const (
 	todo       issueStatus = "todo"
 	inProgress issueStatus = "in-progress"
@@ -260,30 +244,6 @@ const (
 	wontDo     issueStatus = "wont-do"
 )
 
-func (s issueStatus) Icon() string {
-	icons := map[issueStatus]string{
-		todo:       "[·]",
-		inProgress: "[⋯]",
-		wontDo:     "[×]",
-		done:       "[✓]",
-	}
-	return lipgloss.NewStyle().Foreground(s.color()).Render(icons[s])
-}
-
-func (s issueStatus) PrettyString() string {
-	return lipgloss.NewStyle().Foreground(s.color()).Render(string(s))
-}
-
-func (s issueStatus) color() lipgloss.AdaptiveColor {
-	colors := map[issueStatus]lipgloss.AdaptiveColor{
-		todo:       styles.Theme.SecondaryText,
-		inProgress: styles.Theme.YellowText,
-		wontDo:     styles.Theme.RedText,
-		done:       styles.Theme.GreenText,
-	}
-	return colors[s]
-}
-
 type keyMap struct {
 	Path                      int
 	Up                        key.Binding
@@ -298,11 +258,30 @@ type keyMap struct {
 	IssueNewForm              key.Binding
 	IssueEditForm             key.Binding
 	IssueShowFocus            key.Binding
-	IssueStatusDone           key.Binding
-	IssueStatusWontDo         key.Binding
-	IssueStatusInProgress     key.Binding
+	IssueStatusKeys           []key.Binding
 	IssueCommentFormFocus     key.Binding
 	IssueDelete               key.Binding
+	IssueHistory              key.Binding
+	IssueResolveOurs          key.Binding
+	IssueResolveTheirs        key.Binding
+	IssueAttach               key.Binding
+	IssueAssignMe             key.Binding
+	IssueAssign               key.Binding
+	IssueToggleMarkdown       key.Binding
+	CommentNext               key.Binding
+	CommentPrev               key.Binding
+	CommentEdit               key.Binding
+	CommentDelete             key.Binding
+	CommentReply              key.Binding
+	CommentReact              key.Binding
+	MilestoneNew              key.Binding
+	MilestoneShowFocus        key.Binding
+	MilestoneEdit             key.Binding
+	MilestoneToggleClosed     key.Binding
+	PickerPrev                key.Binding
+	PickerNext                key.Binding
+	IssueSort                 key.Binding
+	IssueExtractAttachments   key.Binding
 	IssueConfirmDelete        key.Binding
 	CommitShowFocus           key.Binding
 	CommitExpandActionDetails key.Binding
@@ -311,6 +290,15 @@ type keyMap struct {
 	NextPage                  key.Binding
 	PrevPage                  key.Binding
 	RunAction                 key.Binding
+	Sync                      key.Binding
+	GarbageCollect            key.Binding
+	GarbageCollectConfirm     key.Binding
+	TrashRestore              key.Binding
+	TrashPurge                key.Binding
+	TrashConfirmPurge         key.Binding
+	InboxOpen                 key.Binding
+	InboxToggleRead           key.Binding
+	InboxReadAll              key.Binding
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view. It's part
@@ -330,18 +318,35 @@ func (k keyMap) FullHelp() [][]key.Binding {
 			{k.Help, k.Quit},
 			{k.Up, k.Down},
 			{k.IssueNewForm, k.IssueShowFocus},
-			{k.IssueStatusDone, k.IssueStatusWontDo},
-			{k.IssueStatusInProgress, k.IssueCommentFormFocus},
-			{k.IssueDelete},
 		}
+		bindings = append(bindings, statusKeyRows(k.IssueStatusKeys)...)
+		bindings = append(bindings, [][]key.Binding{
+			{k.IssueCommentFormFocus, k.IssueDelete},
+			{k.Sync, k.GarbageCollect},
+			{k.IssueAssignMe, k.IssueAssign},
+			{k.IssueSort},
+		}...)
 	case matchRoute(k.Path, issuesShowPath):
 		bindings = [][]key.Binding{
 			{k.Help, k.Quit},
 			{k.Up, k.Down},
 			{k.IssueEditForm, k.Back},
 			{k.IssueNewForm, k.IssueShowFocus},
-			{k.IssueStatusDone, k.IssueStatusWontDo},
-			{k.IssueStatusInProgress, k.IssueCommentFormFocus},
+		}
+		bindings = append(bindings, statusKeyRows(k.IssueStatusKeys)...)
+		bindings = append(bindings, [][]key.Binding{
+			{k.IssueCommentFormFocus, k.IssueHistory},
+			{k.IssueResolveOurs, k.IssueResolveTheirs},
+			{k.IssueAttach, k.IssueExtractAttachments},
+			{k.IssueAssignMe, k.IssueAssign},
+			{k.CommentPrev, k.CommentNext},
+			{k.CommentReply, k.CommentReact},
+			{k.CommentEdit, k.CommentDelete},
+			{k.IssueToggleMarkdown},
+		}...)
+	case matchRoute(k.Path, issuesAttachPath), matchRoute(k.Path, issuesAssignPath), matchRoute(k.Path, issuesExtractPath):
+		bindings = [][]key.Binding{
+			{k.Submit, k.Back},
 		}
 	case matchRoute(k.Path, issuesEditConfirmationPath):
 		bindings = [][]key.Binding{
@@ -349,11 +354,46 @@ func (k keyMap) FullHelp() [][]key.Binding {
 			{k.Up, k.Down},
 			{k.NextInput, k.Back},
 		}
+	case matchRoute(k.Path, issuesEditMilestonePath), matchRoute(k.Path, issuesNewMilestonePath),
+		matchRoute(k.Path, issuesEditPriorityPath), matchRoute(k.Path, issuesNewPriorityPath):
+		bindings = [][]key.Binding{
+			{k.PickerPrev, k.PickerNext},
+			{k.NextInput, k.Back},
+		}
+	case matchRoute(k.Path, issuesReactPath):
+		bindings = [][]key.Binding{
+			{k.PickerPrev, k.PickerNext},
+			{k.Submit, k.Back},
+		}
+	case matchRoute(k.Path, issuesTemplatePickerPath):
+		bindings = [][]key.Binding{
+			{k.Up, k.Down},
+			{k.Submit, k.Back},
+		}
+	case matchRoute(k.Path, milestonesIndexPath):
+		bindings = [][]key.Binding{
+			{k.Help, k.Quit},
+			{k.Up, k.Down},
+			{k.MilestoneNew, k.MilestoneShowFocus},
+		}
+	case matchRoute(k.Path, milestonesShowPath):
+		bindings = [][]key.Binding{
+			{k.Help, k.Quit},
+			{k.Up, k.Down},
+			{k.MilestoneEdit, k.MilestoneToggleClosed},
+			{k.Back},
+		}
+	case matchRoute(k.Path, milestonesFormPath):
+		bindings = [][]key.Binding{
+			{k.NextInput, k.Submit},
+			{k.Back},
+		}
 	case matchRoute(k.Path, actionsIndexPath):
 		bindings = [][]key.Binding{
 			{k.Help, k.Quit},
 			{k.Up, k.Down},
 			{k.RunAction, k.CommitShowFocus},
+			{k.Sync, k.GarbageCollect},
 		}
 	case matchRoute(k.Path, actionsShowPath):
 		bindings = [][]key.Binding{
@@ -362,35 +402,82 @@ func (k keyMap) FullHelp() [][]key.Binding {
 			{k.RunAction, k.CommitExpandActionDetails},
 			{k.Back},
 		}
+	case matchRoute(k.Path, trashIndexPath):
+		bindings = [][]key.Binding{
+			{k.Help, k.Quit},
+			{k.Up, k.Down},
+			{k.TrashRestore, k.TrashPurge},
+		}
+	case matchRoute(k.Path, gcConfirmationPath):
+		bindings = [][]key.Binding{
+			{k.GarbageCollectConfirm, k.Back},
+		}
+	case matchRoute(k.Path, inboxIndexPath):
+		bindings = [][]key.Binding{
+			{k.Help, k.Quit},
+			{k.Up, k.Down},
+			{k.InboxOpen, k.InboxToggleRead},
+			{k.InboxReadAll},
+		}
 	}
 
 	return bindings
 }
 
 type Issue struct {
-	Id          string      `json:"id"`
-	Shortcode   string      `json:"shortcode"`
-	Author      string      `json:"author"`
-	Title       string      `json:"title"`
-	Description string      `json:"description"`
-	Status      issueStatus `json:"status"`
-	Labels      []string    `jaon:"labels"`
-	Comments    []Comment   `json:"comments"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
-	DeletedAt   time.Time   `json:"deleted_at"`
+	Id          string        `json:"id"`
+	Shortcode   string        `json:"shortcode"`
+	Author      string        `json:"author"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Status      issueStatus   `json:"status"`
+	Labels      []string      `json:"labels"`
+	Assignees   []string      `json:"assignees,omitempty"`
+	MilestoneId string        `json:"milestone_id,omitempty"`
+	Priority    issuePriority `json:"priority,omitempty"`
+	DueDate     time.Time     `json:"due_date"`
+	// Fields holds the repo's custom fields; see fields.go
+	Fields      map[string]string `json:"fields,omitempty"`
+	Relations   []Relation        `json:"relations,omitempty"`
+	Comments    []Comment         `json:"comments"`
+	Attachments []Attachment      `json:"attachments,omitempty"`
+	Conflicts   []IssueConflict   `json:"conflicts,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	DeletedAt   time.Time         `json:"deleted_at"`
+	DeletedBy   string            `json:"deleted_by,omitempty"`
+	// SchemaVersion is stamped on write; see schema.go
+	SchemaVersion int        `json:"schema_version"`
+	Signature     *Signature `json:"signature,omitempty"`
+	// Verified is worked out on load; see sign.go
+	Verified bool `json:"-"`
+	// LinkedCommits is worked out from the commit log; see links.go
+	LinkedCommits []IssueLink `json:"-"`
+	// RelatedIssues is worked out from both sides' Relations; see relations.go
+	RelatedIssues []RelatedIssue `json:"-"`
+	// MilestoneTitle is looked up on load; see milestone.go
+	MilestoneTitle string `json:"-"`
+	// Imported is set on issues brought over from another tracker; see import.go
+	Imported *ImportedFrom `json:"imported,omitempty"`
 }
 
 func (i Issue) FilterValue() string {
 	labels := strings.Join(i.Labels, " ")
-	return fmt.Sprintf("%s\n%s\n%s", i.Title, labels, i.Status)
+	assignees := strings.Join(i.Assignees, " ")
+	return fmt.Sprintf("%s\n%s\n%s\n%s %s\n%s\n%s\n%s\n%s\n%s", i.Title, labels, i.Status, i.Shortcode, i.Id, assignees, i.MilestoneTitle, i.Priority, formatDueDate(i.DueDate), encodeFields(i.Fields))
+}
+
+// issueDelegate renders issues in the issue list, with their statuses as
+// the repo's workflow has them.
+type issueDelegate struct {
+	workflow Workflow
 }
 
-func (i Issue) Height() int                             { return 2 }
-func (i Issue) Spacing() int                            { return 1 }
-func (i Issue) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+func (d issueDelegate) Height() int                             { return 2 }
+func (d issueDelegate) Spacing() int                            { return 1 }
+func (d issueDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
 
-func (i Issue) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+func (d issueDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
 	i, ok := listItem.(Issue)
 
 	if !ok {
@@ -408,9 +495,18 @@ func (i Issue) Render(w io.Writer, m list.Model, index int, listItem list.Item)
 				Render(strings.Join(s, " "))
 		}
 	}
-	title := fmt.Sprintf("%s %s", i.Status.Icon(), titleFn(truncate.StringWithTail(i.Title, 50, "...")))
+	title := fmt.Sprintf("%s %s", d.workflow.icon(i.Status), titleFn(truncate.StringWithTail(i.Title, 50, "...")))
+	if i.Priority != noPriority {
+		title = fmt.Sprintf("%s %s", title, i.Priority.PrettyString())
+	}
 	labels := lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render(fmt.Sprintf(strings.Join(i.Labels, ",")))
 	title = fmt.Sprintf("%s %s", title, labels)
+	if len(i.Conflicts) > 0 {
+		title = fmt.Sprintf("%s %s", title, lipgloss.NewStyle().Foreground(styles.Theme.RedText).Render("(conflict)"))
+	}
+	if !d.workflow.isClosed(i.Status) && len(i.openBlockers(d.workflow)) > 0 {
+		title = fmt.Sprintf("%s %s", title, lipgloss.NewStyle().Foreground(styles.Theme.YellowText).Render("(blocked)"))
+	}
 
 	description := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render(fmt.Sprintf(
 		"#%s opened by %s on %s",
@@ -418,16 +514,31 @@ func (i Issue) Render(w io.Writer, m list.Model, index int, listItem list.Item)
 		i.Author,
 		i.CreatedAt.Format(time.DateOnly),
 	))
+	if len(i.Assignees) > 0 {
+		description = fmt.Sprintf("%s %s", description, lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render(renderAssignees(i.Assignees)))
+	}
+	if !i.DueDate.IsZero() {
+		description = fmt.Sprintf("%s %s", description, renderIssueDueDate(i, d.workflow))
+	}
 	item := lipgloss.JoinVertical(lipgloss.Left, title, description)
 
 	fmt.Fprintf(w, item)
 }
 
 type Comment struct {
-	Author    string    `json:"author"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Author      string       `json:"author"`
+	Content     string       `json:"content"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// ReplyTo is the commentKey of the comment this answers; see comments.go
+	ReplyTo string `json:"reply_to,omitempty"`
+	// Reactions maps each emoji to the people who reacted with it
+	Reactions map[string][]string `json:"reactions,omitempty"`
+	Edited    bool                `json:"edited,omitempty"`
+	Deleted   bool                `json:"deleted,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	Signature *Signature          `json:"signature,omitempty"`
+	Verified  bool                `json:"-"`
 }
 
 /* MAIN MODEL */
@@ -450,9 +561,10 @@ type Layout struct {
 func (m Model) IsRightSidebarOpen() bool {
 	switch m.path {
 	case issuesCommentContentPath, issuesCommentConfirmationPath,
-		issuesEditTitlePath, issuesEditDescriptionPath, issuesEditLabelsPath, issuesEditConfirmationPath,
-		issuesNewTitlePath, issuesNewDescriptionPath, issuesNewLabelsPath, issuesNewConfirmationPath, issuesShowPath,
-		actionsShowPath:
+		issuesEditTitlePath, issuesEditDescriptionPath, issuesEditLabelsPath, issuesEditRelationsPath, issuesEditConfirmationPath,
+		issuesNewTitlePath, issuesNewDescriptionPath, issuesNewLabelsPath, issuesNewRelationsPath, issuesNewConfirmationPath, issuesShowPath,
+		issuesEditMilestonePath, issuesNewMilestonePath, issuesEditPriorityPath, issuesNewPriorityPath, issuesEditDuePath, issuesNewDuePath,
+		issuesEditFieldsPath, issuesNewFieldsPath, issuesCommentDeletePath, issuesReactPath, actionsShowPath, milestonesShowPath:
 		return true
 	default:
 		return false
@@ -471,7 +583,7 @@ func (m *Model) UpdateLayout(terminalSize Size) {
 	available := layout.AvailableSize
 
 	layout.HeaderSize = Size{Width: available.Width, Height: lipgloss.Height(m.renderTabs("Issues"))}
-	layout.FooterSize = Size{Width: available.Width, Height: lipgloss.Height(m.help.View(m.HelpKeys()))}
+	layout.FooterSize = Size{Width: available.Width, Height: lipgloss.Height(m.footerView())}
 	contentHeight := available.Height - layout.HeaderSize.Height - layout.FooterSize.Height
 	if m.IsRightSidebarOpen() {
 		layout.LeftSize = Size{Width: 70, Height: contentHeight}
@@ -494,32 +606,57 @@ func (m *Model) UpdateLayout(terminalSize Size) {
 	// update component sizes based on layout
 	m.issueIndex.SetSize(m.layout.LeftSize.Width, m.layout.LeftSize.Height)
 	m.commitIndex.SetSize(m.layout.LeftSize.Width, m.layout.LeftSize.Height)
+	m.trashIndex.SetSize(m.layout.LeftSize.Width, m.layout.LeftSize.Height)
+	m.milestoneIndex.SetSize(m.layout.LeftSize.Width, m.layout.LeftSize.Height)
+	m.inboxIndex.SetSize(m.layout.LeftSize.Width, m.layout.LeftSize.Height)
+	m.milestoneShow.Width = m.layout.RightSize.Width
+	m.milestoneShow.Height = m.layout.RightSize.Height
 	m.commentForm.contentInput.SetWidth(m.layout.CommentFormSize.Width)
 	m.issueForm.titleInput.Width = clamp(layout.RightSize.Width, 50, 80)
 	m.issueForm.labelsInput.Width = clamp(layout.RightSize.Width, 50, 80)
+	m.issueForm.relationsInput.Width = clamp(layout.RightSize.Width, 50, 80)
 	m.issueForm.descriptionInput.SetWidth(clamp(layout.RightSize.Width, 50, 80))
 	m.issueForm.descriptionInput.SetHeight(layout.RightSize.Height / 3)
 }
 
 type Model struct {
-	loaded       bool
-	path         int
-	underlayPath int // determines what view to display under the overlay
-	issueIndex   list.Model
-	issueShow    issueShow
-	issueForm    issueForm
-	commentForm  commentForm
-	commitIndex  list.Model
-	commitShow   commitShow
-	err          error
-	help         help.Model
-	styles       Styles
-	tabs         []string
-	msgDump      io.Writer
-	layout       Layout
-	router       *Router
-	gitConfig    *config.Config
-	repo         *git.Repository
+	loaded         bool
+	path           int
+	underlayPath   int // determines what view to display under the overlay
+	issueIndex     list.Model
+	issueShow      issueShow
+	issueForm      issueForm
+	commentForm    commentForm
+	attachInput    textinput.Model
+	extractInput   textinput.Model
+	assignInput    textinput.Model
+	reactionPicker int
+	templates      []issueTemplate
+	templatePicker int
+	commitIndex    list.Model
+	commitShow     commitShow
+	trashIndex     list.Model
+	milestoneIndex list.Model
+	inboxIndex     list.Model
+	inboxPath      string
+	milestoneShow  viewport.Model
+	milestoneForm  milestoneForm
+	err            error
+	help           help.Model
+	styles         Styles
+	tabs           []string
+	msgDump        io.Writer
+	layout         Layout
+	router         *Router
+	gitConfig      *config.Config
+	repo           *git.Repository
+	store          Store
+	flash          string // one-line status shown above the help
+	issueSort      issueSortMode
+	gcReport       GCReport // the dry run shown before collecting garbage
+	autoClosing    bool     // an autoCloseIssues run hasn't reported back yet
+	workflow       Workflow
+	fieldSchema    FieldSchema
 }
 
 func (m Model) submitIssueForm() tea.Cmd {
@@ -529,12 +666,42 @@ func (m Model) submitIssueForm() tea.Cmd {
 	title := form.titleInput.Value()
 	labels := strings.Split(form.labelsInput.Value(), " ")
 
+	var selfId string
+	var existingRelations []Relation
+	if m.issueForm.editing {
+		selfId = m.issueIndex.SelectedItem().(Issue).Id
+		existingRelations = m.issueIndex.SelectedItem().(Issue).Relations
+	}
+	relations, err := parseRelations(form.relationsInput.Value(), m.loadedIssues(), selfId, existingRelations)
+	if err != nil {
+		return func() tea.Msg { return issueFormInvalidMsg{Err: err} }
+	}
+	due, err := parseDueDate(form.dueInput.Value())
+	if err != nil {
+		return func() tea.Msg { return issueFormInvalidMsg{Err: err} }
+	}
+	var existingFields map[string]string
+	if m.issueForm.editing {
+		existingFields = m.issueIndex.SelectedItem().(Issue).Fields
+	}
+	fields, err := form.fieldValues(m.fieldSchema, existingFields)
+	if err != nil {
+		return func() tea.Msg { return issueFormInvalidMsg{Err: err} }
+	}
+
 	if m.issueForm.editing {
 		currentIssue := m.issueIndex.SelectedItem().(Issue)
+		before := currentIssue.Relations
 		currentIssue.Title = title
 		currentIssue.Description = description
 		currentIssue.Labels = labels
-		cmd = persistIssue(currentIssue, m.repo)
+		currentIssue.Relations = relations
+		currentIssue.MilestoneId = form.milestonePicker.value().Id
+		currentIssue.Priority = form.priority
+		currentIssue.DueDate = due
+		currentIssue.Fields = fields
+		currentIssue = markDuplicate(before, currentIssue, m.workflow)
+		cmd = persistIssue(currentIssue, m.store)
 	} else {
 		description := form.descriptionInput.Value()
 
@@ -543,21 +710,38 @@ func (m Model) submitIssueForm() tea.Cmd {
 			Title:       title,
 			Description: description,
 			Labels:      labels,
-			Status:      todo,
+			Relations:   relations,
+			MilestoneId: form.milestonePicker.value().Id,
+			Priority:    form.priority,
+			DueDate:     due,
+			Fields:      fields,
+			Status:      cmp.Or(form.status, m.workflow.initial()),
 			Author:      m.gitConfig.User.Email,
 		}
-		cmd = persistIssue(newIssue, m.repo)
+		cmd = persistIssue(markDuplicate(nil, newIssue, m.workflow), m.store)
 	}
 
 	return cmd
 }
 
+type issueFormInvalidMsg struct {
+	Err error
+}
+
 type issueForm struct {
 	titleInput       textinput.Model
 	labelsInput      textinput.Model
+	relationsInput   textinput.Model
+	milestonePicker  milestonePicker
+	priority         issuePriority
+	dueInput         textinput.Model
+	fieldInputs      []textinput.Model
+	focusedField     int
 	descriptionInput textarea.Model
 	identifier       string
 	editing          bool
+	template         string
+	status           issueStatus
 }
 
 var (
@@ -577,7 +761,7 @@ var (
 func InitialModel() Model {
 	layout := Layout{}
 
-	issueList := list.New([]list.Item{}, Issue{}, 0, 0)
+	issueList := list.New([]list.Item{}, issueDelegate{defaultWorkflow()}, 0, 0)
 	issueList.SetShowHelp(false)
 	issueList.SetShowTitle(false)
 	issueList.SetShowStatusBar(false)
@@ -595,6 +779,34 @@ func InitialModel() Model {
 	commitList.FilterInput.Prompt = "search: "
 	commitList.FilterInput.PromptStyle = lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText)
 	commitList.Title = "Commits"
+	trashList := list.New([]list.Item{}, trashDelegate{defaultWorkflow()}, 0, 0)
+	trashList.SetShowHelp(false)
+	trashList.SetShowTitle(false)
+	trashList.SetShowStatusBar(false)
+	trashList.Styles.TitleBar = lipgloss.NewStyle().Padding(0)
+	trashList.Styles.PaginationStyle = lipgloss.NewStyle().Padding(0)
+	trashList.FilterInput.Prompt = "search: "
+	trashList.FilterInput.PromptStyle = lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText)
+	trashList.Title = "Trash"
+
+	inboxList := list.New([]list.Item{}, notification{}, 0, 0)
+	inboxList.SetShowHelp(false)
+	inboxList.SetShowTitle(false)
+	inboxList.SetShowStatusBar(false)
+	inboxList.Styles.TitleBar = lipgloss.NewStyle().Padding(0)
+	inboxList.Styles.PaginationStyle = lipgloss.NewStyle().Padding(0)
+	inboxList.FilterInput.Prompt = "search: "
+	inboxList.FilterInput.PromptStyle = lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText)
+	inboxList.Title = "Inbox"
+	milestoneList := list.New([]list.Item{}, Milestone{}, 0, 0)
+	milestoneList.SetShowHelp(false)
+	milestoneList.SetShowTitle(false)
+	milestoneList.SetShowStatusBar(false)
+	milestoneList.Styles.TitleBar = lipgloss.NewStyle().Padding(0)
+	milestoneList.Styles.PaginationStyle = lipgloss.NewStyle().Padding(0)
+	milestoneList.FilterInput.Prompt = "search: "
+	milestoneList.FilterInput.PromptStyle = lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText)
+	milestoneList.Title = "Milestones"
 
 	helpModel := help.New()
 	helpModel.FullSeparator = "    "
@@ -615,20 +827,48 @@ func InitialModel() Model {
 	router.AddRoute(issuesNewConfirmationPath, issuesNewConfirmationHandler)
 	router.AddRoute(actionsIndexPath, actionsIndexHandler)
 	router.AddRoute(actionsShowPath, actionsShowHandler)
-
-	return Model{
-		path:        issuesIndexPath,
-		help:        helpModel,
-		styles:      DefaultStyles(),
-		tabs:        []string{"Issues", "Actions"},
-		layout:      layout,
-		issueIndex:  issueList,
-		commitIndex: commitList,
-		commentForm: newCommentForm(),
-		issueForm:   newIssueForm("", "", "", []string{}, false),
-		issueShow:   newIssueShow(Issue{}, layout),
-		router:      router,
-	}
+	router.AddRoute(trashIndexPath, trashIndexHandler)
+	router.AddRoute(trashPurgeConfirmationPath, trashPurgeHandler)
+	router.AddRoute(issuesAttachPath, issuesAttachHandler)
+	router.AddRoute(issuesEditRelationsPath, issuesEditRelationsHandler)
+	router.AddRoute(issuesNewRelationsPath, issuesNewRelationsHandler)
+	router.AddRoute(issuesAssignPath, issuesAssignHandler)
+	router.AddRoute(issuesExtractPath, issuesExtractHandler)
+	router.AddRoute(milestonesIndexPath, milestonesIndexHandler)
+	router.AddRoute(milestonesShowPath, milestonesShowHandler)
+	router.AddRoute(milestonesFormPath, milestonesFormHandler)
+	router.AddRoute(issuesEditMilestonePath, issuesMilestoneHandler)
+	router.AddRoute(issuesNewMilestonePath, issuesMilestoneHandler)
+	router.AddRoute(issuesEditPriorityPath, issuesPriorityHandler)
+	router.AddRoute(issuesNewPriorityPath, issuesPriorityHandler)
+	router.AddRoute(issuesEditDuePath, issuesDueHandler)
+	router.AddRoute(issuesNewDuePath, issuesDueHandler)
+	router.AddRoute(issuesEditFieldsPath, issuesFieldsHandler)
+	router.AddRoute(issuesNewFieldsPath, issuesFieldsHandler)
+	router.AddRoute(issuesCommentDeletePath, issuesCommentDeleteHandler)
+	router.AddRoute(issuesReactPath, issuesReactHandler)
+	router.AddRoute(inboxIndexPath, inboxIndexHandler)
+	router.AddRoute(issuesTemplatePickerPath, issuesTemplatePickerHandler)
+	router.AddRoute(gcConfirmationPath, gcConfirmationHandler)
+
+	m := Model{
+		path:           issuesIndexPath,
+		help:           helpModel,
+		styles:         DefaultStyles(),
+		tabs:           []string{"Issues", "Milestones", "Actions", "Trash", "Inbox"},
+		layout:         layout,
+		issueIndex:     issueList,
+		commitIndex:    commitList,
+		trashIndex:     trashList,
+		milestoneIndex: milestoneList,
+		inboxIndex:     inboxList,
+		commentForm:    newCommentForm(),
+		issueForm:      newIssueForm("", "", "", []string{}, "", false),
+		router:         router,
+		workflow:       defaultWorkflow(),
+	}
+	m.issueShow = m.newIssueShow(Issue{})
+	return m
 }
 
 func (m Model) Init() tea.Cmd {
@@ -642,21 +882,48 @@ func (m Model) isUserTyping() bool {
 		issuesCommentContentPath,
 		issuesEditTitlePath,
 		issuesEditLabelsPath,
+		issuesEditRelationsPath,
 		issuesEditDescriptionPath,
 		issuesEditConfirmationPath,
 		issuesNewTitlePath,
 		issuesNewLabelsPath,
+		issuesNewRelationsPath,
 		issuesNewDescriptionPath,
+		issuesAttachPath,
+		issuesAssignPath,
+		issuesExtractPath,
+		milestonesFormPath,
+		issuesEditDuePath,
+		issuesNewDuePath,
+		issuesEditFieldsPath,
+		issuesNewFieldsPath,
 	}
 
 	return slices.Contains(paths, m.path)
 }
 
-func CustomFilter(term string, targets []string) []list.Rank {
+// CustomFilter is the search of the issue list. The workflow says which
+// statuses "due:overdue" leaves out, and the field schema which terms search
+// custom fields.
+func CustomFilter(term string, targets []string, w Workflow, schema FieldSchema) []list.Rank {
 	terms := strings.Fields(term)
 	filters := map[string]func(string, []string) []list.Rank{
-		"label:":  LabelFilter,
-		"status:": StatusFilter,
+		"label:":     LabelFilter,
+		"status:":    StatusFilter,
+		"assignee:":  AssigneeFilter,
+		"milestone:": MilestoneFilter,
+		"priority:":  PriorityFilter,
+		"due:": func(term string, targets []string) []list.Rank {
+			return DueFilter(term, targets, w)
+		},
+		"#": RefFilter,
+	}
+
+	// plain terms only match the title, labels and status, not the ids
+	var textTargets []string
+	for _, t := range targets {
+		lines := strings.SplitN(t, "\n", 4)
+		textTargets = append(textTargets, strings.Join(lines[:min(len(lines), 3)], "\n"))
 	}
 
 	// Create a map to store all matching ranks
@@ -670,8 +937,11 @@ func CustomFilter(term string, targets []string) []list.Rank {
 				break
 			}
 		}
+		if schema.isFieldTerm(t) {
+			ranks = FieldFilter(t, targets, schema)
+		}
 		if len(ranks) == 0 {
-			ranks = list.DefaultFilter(t, targets)
+			ranks = list.DefaultFilter(t, textTargets)
 		}
 
 		// Intersect the new ranks with existing ones
@@ -737,7 +1007,7 @@ func StatusFilter(term string, targets []string) []list.Rank {
 func issuesIndexHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 	var cmd tea.Cmd
 	if m.issueIndex.SettingFilter() {
-		m.issueIndex.Filter = CustomFilter
+		m.issueIndex.Filter = m.issueFilter()
 		m.issueIndex, cmd = m.issueIndex.Update(msg)
 		return m, cmd
 	}
@@ -760,51 +1030,37 @@ func issuesIndexHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 				m.help.ShowAll = true
 				return m, nil
 			}
-		case key.Matches(msg, keys.IssueStatusDone):
-			currentIssue := m.issueIndex.SelectedItem().(Issue)
-			if currentIssue.Status == done {
-				currentIssue.Status = todo
-			} else {
-				currentIssue.Status = done
-			}
-			cmd = persistIssue(currentIssue, m.repo)
-			return m, cmd
-		case key.Matches(msg, keys.IssueStatusWontDo):
-			currentIssue := m.issueIndex.SelectedItem().(Issue)
-			if currentIssue.Status == wontDo {
-				currentIssue.Status = todo
-			} else {
-				currentIssue.Status = wontDo
+		case key.Matches(msg, keys.IssueStatusKeys...):
+			if m.issueIndex.SelectedItem() == nil {
+				return m, nil
 			}
-			cmd = persistIssue(currentIssue, m.repo)
-			return m, cmd
-		case key.Matches(msg, keys.IssueStatusInProgress):
-			currentIssue := m.issueIndex.SelectedItem().(Issue)
-			if currentIssue.Status == inProgress {
-				currentIssue.Status = todo
-			} else {
-				currentIssue.Status = inProgress
+			status, _ := m.workflow.statusForKey(msg)
+			currentIssue, ok := m.changeStatus(status)
+			if !ok {
+				return m, nil
 			}
-			cmd = persistIssue(currentIssue, m.repo)
+			cmd = persistIssue(currentIssue, m.store)
 			return m, cmd
 		case key.Matches(msg, keys.IssueCommentFormFocus):
 			m.commentForm = newCommentForm()
 			cmd = m.commentForm.Init()
 			m.path = issuesCommentContentPath
 			m.UpdateLayout(m.layout.TerminalSize)
-			m.issueShow = newIssueShow(m.issueIndex.SelectedItem().(Issue), m.layout)
+			m.issueShow = m.newIssueShow(m.issueIndex.SelectedItem().(Issue))
 			m.issueShow.viewport.GotoBottom()
 			return m, cmd
 		case key.Matches(msg, keys.IssueShowFocus):
 			m.commentForm = newCommentForm()
 			m.path = issuesShowPath
 			m.UpdateLayout(m.layout.TerminalSize)
-			m.issueShow = newIssueShow(m.issueIndex.SelectedItem().(Issue), m.layout)
+			m.issueShow = m.newIssueShow(m.issueIndex.SelectedItem().(Issue))
 		case key.Matches(msg, keys.IssueNewForm):
-			m.path = issuesNewTitlePath
-			m.issueForm = newIssueForm("", "", "", []string{}, false)
-			cmd = m.issueForm.titleInput.Focus()
-			m.UpdateLayout(m.layout.TerminalSize)
+			if len(m.templates) == 0 {
+				return m.openNewIssueForm(nil)
+			}
+			m.templatePicker = 0
+			m.underlayPath = m.path
+			m.path = issuesTemplatePickerPath
 			return m, cmd
 		case key.Matches(msg, keys.IssueDelete):
 			selectedItem := m.issueIndex.SelectedItem()
@@ -815,12 +1071,35 @@ func issuesIndexHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 			m.path = issuesDeleteConfirmationPath
 			m.UpdateLayout(m.layout.TerminalSize)
 			return m, cmd
+		case key.Matches(msg, keys.Sync):
+			m.flash = "syncing..."
+			return m, syncIssues(m.repo, m.gitConfig)
+		case key.Matches(msg, keys.GarbageCollect):
+			m.flash = "checking for garbage..."
+			return m, garbageCollect(m.repo, m.store, true)
+		case key.Matches(msg, keys.IssueAssignMe):
+			if m.issueIndex.SelectedItem() == nil {
+				return m, nil
+			}
+			currentIssue := toggleAssignee(m.issueIndex.SelectedItem().(Issue), m.gitConfig.User.Email)
+			return m, persistIssue(currentIssue, m.store)
+		case key.Matches(msg, keys.IssueAssign):
+			if m.issueIndex.SelectedItem() == nil {
+				return m, nil
+			}
+			return m.openAssignInput()
+		case key.Matches(msg, keys.IssueSort):
+			m.issueSort = m.issueSort.next()
+			m.relinkCommits()
+			m.flash = fmt.Sprintf("sorted by %s", m.issueSort)
+			m.UpdateLayout(m.layout.TerminalSize)
+			return m, nil
 		case key.Matches(msg, keys.NextPage):
-			m.path = actionsIndexPath
+			m.path = milestonesIndexPath
 			return m, nil
 		case key.Matches(msg, keys.PrevPage):
-			m.path = actionsIndexPath
-			return m, nil
+			m.path = inboxIndexPath
+			return m, m.loadInbox()
 		}
 	}
 
@@ -842,38 +1121,15 @@ func issuesShowHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 				m.help.ShowAll = true
 			}
 			return m, nil
-		case key.Matches(msg, keys.IssueStatusDone):
-			currentIssue := m.issueIndex.SelectedItem().(Issue)
-			if currentIssue.Status == todo {
-				currentIssue.Status = done
-			} else {
-				currentIssue.Status = todo
-			}
-			m.commentForm = newCommentForm()
-			m.issueShow = newIssueShow(currentIssue, m.layout)
-			cmd = persistIssue(currentIssue, m.repo)
-			return m, cmd
-		case key.Matches(msg, keys.IssueStatusWontDo):
-			currentIssue := m.issueIndex.SelectedItem().(Issue)
-			if currentIssue.Status == wontDo {
-				currentIssue.Status = todo
-			} else {
-				currentIssue.Status = wontDo
-			}
-			m.commentForm = newCommentForm()
-			m.issueShow = newIssueShow(currentIssue, m.layout)
-			cmd = persistIssue(currentIssue, m.repo)
-			return m, cmd
-		case key.Matches(msg, keys.IssueStatusInProgress):
-			currentIssue := m.issueIndex.SelectedItem().(Issue)
-			if currentIssue.Status == inProgress {
-				currentIssue.Status = todo
-			} else {
-				currentIssue.Status = inProgress
+		case key.Matches(msg, keys.IssueStatusKeys...):
+			status, _ := m.workflow.statusForKey(msg)
+			currentIssue, ok := m.changeStatus(status)
+			if !ok {
+				return m, nil
 			}
 			m.commentForm = newCommentForm()
-			m.issueShow = newIssueShow(currentIssue, m.layout)
-			cmd = persistIssue(currentIssue, m.repo)
+			m.issueShow = m.newIssueShow(currentIssue)
+			cmd = persistIssue(currentIssue, m.store)
 			return m, cmd
 		case key.Matches(msg, keys.IssueEditForm):
 			selectedIssue := m.issueIndex.SelectedItem().(Issue)
@@ -882,23 +1138,56 @@ func issuesShowHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 				selectedIssue.Title,
 				selectedIssue.Description,
 				selectedIssue.Labels,
+				formatRelations(selectedIssue.Relations, m.loadedIssues()),
 				true,
 			)
+			m.issueForm.milestonePicker = newMilestonePicker(m.loadedMilestones(), selectedIssue.MilestoneId)
+			m.issueForm.priority = selectedIssue.Priority
+			m.issueForm.dueInput.SetValue(formatDueDate(selectedIssue.DueDate))
+			m.issueForm.fieldInputs = newFieldInputs(m.fieldSchema, selectedIssue.Fields)
 			cmd = m.issueForm.titleInput.Focus()
 
 			m.path = issuesEditTitlePath
 			m.UpdateLayout(m.layout.TerminalSize)
 			return m, cmd
 		case key.Matches(msg, keys.Back):
+			if m.issueShow.selectedComment != "" {
+				offset := m.issueShow.viewport.YOffset
+				m.issueShow = m.newIssueShow(m.issueShow.issue)
+				m.issueShow.viewport.SetYOffset(offset)
+				return m, nil
+			}
 			m.path = issuesIndexPath
 			m.UpdateLayout(m.layout.TerminalSize)
 			return m, nil
+		case key.Matches(msg, keys.IssueToggleMarkdown):
+			offset := m.issueShow.viewport.YOffset
+			m.issueShow = m.buildIssueShow(m.issueShow.issue, m.issueShow.selectedComment, !m.issueShow.raw)
+			m.issueShow.viewport.SetYOffset(offset)
+			return m, nil
+		case key.Matches(msg, keys.CommentNext):
+			return m.moveCommentCursor(1), nil
+		case key.Matches(msg, keys.CommentPrev):
+			return m.moveCommentCursor(-1), nil
+		case key.Matches(msg, keys.CommentEdit):
+			return m.editComment()
+		case key.Matches(msg, keys.CommentReply):
+			return m.replyToComment()
+		case key.Matches(msg, keys.CommentReact):
+			return m.openReactionPicker()
+		case key.Matches(msg, keys.CommentDelete) && m.issueShow.selectedComment != "":
+			if _, _, ok := m.ownSelectedComment(); !ok {
+				return m, nil
+			}
+			m.underlayPath = m.path
+			m.path = issuesCommentDeletePath
+			return m, nil
 		case key.Matches(msg, keys.IssueCommentFormFocus):
 			m.commentForm = newCommentForm()
 			cmd = m.commentForm.Init()
 			m.path = issuesCommentContentPath
 			m.UpdateLayout(m.layout.TerminalSize)
-			m.issueShow = newIssueShow(m.issueIndex.SelectedItem().(Issue), m.layout)
+			m.issueShow = m.newIssueShow(m.issueIndex.SelectedItem().(Issue))
 			m.issueShow.viewport.GotoBottom()
 			return m, cmd
 		case key.Matches(msg, keys.IssueDelete):
@@ -910,7 +1199,46 @@ func issuesShowHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 			m.path = issuesDeleteConfirmationPath
 			// m.UpdateLayout(m.layout.TerminalSize)
 			return m, cmd
+		case key.Matches(msg, keys.IssueHistory):
+			if m.issueShow.showHistory {
+				m.issueShow = m.newIssueShow(m.issueShow.issue)
+				return m, nil
+			}
+			return m, getIssueHistory(m.issueShow.issue, m.store)
+		case key.Matches(msg, keys.IssueAttach):
+			m.attachInput = textinput.New()
+			m.attachInput.Prompt = "path: "
+			m.attachInput.Width = 50
+			m.underlayPath = m.path
+			m.path = issuesAttachPath
+			return m, m.attachInput.Focus()
+		case key.Matches(msg, keys.IssueAssignMe):
+			currentIssue := toggleAssignee(m.issueIndex.SelectedItem().(Issue), m.gitConfig.User.Email)
+			m.issueShow = m.newIssueShow(currentIssue)
+			return m, persistIssue(currentIssue, m.store)
+		case key.Matches(msg, keys.IssueAssign):
+			return m.openAssignInput()
+		case key.Matches(msg, keys.IssueExtractAttachments):
+			return m.extractSelectedAttachments()
+		case key.Matches(msg, keys.IssueResolveOurs), key.Matches(msg, keys.IssueResolveTheirs):
+			currentIssue := m.issueIndex.SelectedItem().(Issue)
+			if len(currentIssue.Conflicts) == 0 {
+				return m, nil
+			}
+			currentIssue = resolveConflict(currentIssue, key.Matches(msg, keys.IssueResolveTheirs))
+			m.issueShow = m.newIssueShow(currentIssue)
+			cmd = persistIssue(currentIssue, m.store)
+			return m, cmd
+		}
+	case issueHistoryReadyMsg:
+		if msg.IssueId != m.issueShow.issue.Id {
+			return m, nil
 		}
+		m.issueShow.history = msg.Revisions
+		m.issueShow.showHistory = true
+		m.issueShow.viewport.SetContent(m.issueShow.historyContent())
+		m.issueShow.viewport.GotoTop()
+		return m, nil
 	}
 
 	m.issueShow.viewport, cmd = m.issueShow.viewport.Update(msg)
@@ -931,7 +1259,8 @@ func issuesDeleteHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 			}
 			issue := selectedItem.(Issue)
 			issue.DeletedAt = time.Now().UTC()
-			cmd = persistIssue(issue, m.repo)
+			issue.DeletedBy = m.gitConfig.User.Email
+			cmd = persistIssue(issue, m.store)
 			m.path = issuesIndexPath
 			m.underlayPath = 0
 			m.UpdateLayout(m.layout.TerminalSize)
@@ -946,6 +1275,32 @@ func issuesDeleteHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 	return m, cmd
 }
 
+func issuesAttachHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Submit):
+			m.path = issuesShowPath
+			m.underlayPath = 0
+			if strings.TrimSpace(m.attachInput.Value()) == "" {
+				return m, nil
+			}
+			m.flash = "attaching..."
+			return m, attachToIssue(m.issueShow.issue, m.attachInput.Value(), m.store)
+		case key.Matches(msg, keys.Back):
+			m.path = issuesShowPath
+			m.underlayPath = 0
+			return m, nil
+		}
+	}
+
+	m.attachInput, cmd = m.attachInput.Update(msg)
+	return m, cmd
+}
+
 func issuesCommentContentHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 	var cmd tea.Cmd
 	keys := m.HelpKeys()
@@ -956,7 +1311,7 @@ func issuesCommentContentHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 		case key.Matches(msg, keys.Back):
 			currentIssue := m.issueIndex.SelectedItem().(Issue)
 			m.commentForm = newCommentForm()
-			m.issueShow = newIssueShow(currentIssue, m.layout)
+			m.issueShow = m.newIssueShow(currentIssue)
 			m.path = issuesShowPath
 		case key.Matches(msg, keys.NextInput):
 			m.commentForm.contentInput.Blur()
@@ -978,7 +1333,7 @@ func issuesCommentConfirmationHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 		case key.Matches(msg, keys.Back):
 			currentIssue := m.issueIndex.SelectedItem().(Issue)
 			m.commentForm = newCommentForm()
-			m.issueShow = newIssueShow(currentIssue, m.layout)
+			m.issueShow = m.newIssueShow(currentIssue)
 			m.path = issuesShowPath
 		case key.Matches(msg, keys.NextInput):
 			cmd = m.commentForm.contentInput.Focus()
@@ -1035,9 +1390,9 @@ func issuesEditLabelsHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 				return m, cmd
 			}
 		case key.Matches(msg, keys.NextInput):
-			m.path = issuesEditDescriptionPath
+			m.path = issuesEditRelationsPath
 			m.issueForm.labelsInput.Blur()
-			m.issueForm.descriptionInput.Focus()
+			cmd = m.issueForm.relationsInput.Focus()
 			return m, cmd
 		}
 	}
@@ -1046,6 +1401,27 @@ func issuesEditLabelsHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 	return m, cmd
 }
 
+func issuesEditRelationsHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Back):
+			m.path = issuesShowPath
+			return m, cmd
+		case key.Matches(msg, keys.NextInput):
+			m.path = issuesEditMilestonePath
+			m.issueForm.relationsInput.Blur()
+			return m, cmd
+		}
+	}
+
+	m.issueForm.relationsInput, cmd = m.issueForm.relationsInput.Update(msg)
+	return m, cmd
+}
+
 func issuesEditDescriptionHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 	var cmd tea.Cmd
 	keys := m.HelpKeys()
@@ -1142,9 +1518,9 @@ func issuesNewLabelsHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 				return m, cmd
 			}
 		case key.Matches(msg, keys.NextInput):
-			m.path = issuesNewDescriptionPath
+			m.path = issuesNewRelationsPath
 			m.issueForm.labelsInput.Blur()
-			m.issueForm.descriptionInput.Focus()
+			cmd = m.issueForm.relationsInput.Focus()
 			return m, cmd
 		}
 	}
@@ -1153,6 +1529,27 @@ func issuesNewLabelsHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 	return m, cmd
 }
 
+func issuesNewRelationsHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Back):
+			m.path = issuesIndexPath
+			return m, cmd
+		case key.Matches(msg, keys.NextInput):
+			m.path = issuesNewMilestonePath
+			m.issueForm.relationsInput.Blur()
+			return m, cmd
+		}
+	}
+
+	m.issueForm.relationsInput, cmd = m.issueForm.relationsInput.Update(msg)
+	return m, cmd
+}
+
 func issuesNewDescriptionHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 	var cmd tea.Cmd
 	keys := m.HelpKeys()
@@ -1202,7 +1599,7 @@ func actionsIndexHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case actionResult:
-		return m, persistAction(Action(msg), m.repo)
+		return m, persistAction(Action(msg), m.store)
 	case actionPersistedMsg:
 		action := msg.Action
 		var commit Commit
@@ -1233,7 +1630,7 @@ func actionsIndexHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 			commit := m.commitIndex.SelectedItem().(Commit)
 			var cmds []tea.Cmd
 			actions := NewActions(commit)
-			cmds = append(cmds, commit.DeleteExistingActions())
+			cmds = append(cmds, commit.DeleteExistingActions(m.store))
 			commit.LatestActions = actions
 
 			for i, action := range commit.LatestActions {
@@ -1245,13 +1642,19 @@ func actionsIndexHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 		case key.Matches(msg, keys.CommitShowFocus):
 			m.path = actionsShowPath
 			m.UpdateLayout(m.layout.TerminalSize)
-			m.commitShow = newCommitShow(m.commitIndex.SelectedItem().(Commit), m.layout, false)
+			m.commitShow = m.newCommitShow(m.commitIndex.SelectedItem().(Commit), false)
 			return m, cmd
+		case key.Matches(msg, keys.Sync):
+			m.flash = "syncing..."
+			return m, syncIssues(m.repo, m.gitConfig)
+		case key.Matches(msg, keys.GarbageCollect):
+			m.flash = "checking for garbage..."
+			return m, garbageCollect(m.repo, m.store, true)
 		case key.Matches(msg, keys.NextPage):
-			m.path = issuesIndexPath
+			m.path = trashIndexPath
 			return m, nil
 		case key.Matches(msg, keys.PrevPage):
-			m.path = issuesIndexPath
+			m.path = milestonesIndexPath
 			return m, nil
 		}
 	}
@@ -1273,7 +1676,7 @@ func actionsShowHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 			commit := m.commitIndex.SelectedItem().(Commit)
 			var cmds []tea.Cmd
 			actions := NewActions(commit)
-			cmds = append(cmds, commit.DeleteExistingActions())
+			cmds = append(cmds, commit.DeleteExistingActions(m.store))
 			commit.LatestActions = actions
 
 			for i, action := range commit.LatestActions {
@@ -1282,16 +1685,16 @@ func actionsShowHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 			}
 			m.commitIndex.SetItem(m.commitIndex.Index(), commit)
 			m.UpdateLayout(m.layout.TerminalSize)
-			m.commitShow = newCommitShow(commit, m.layout, false)
+			m.commitShow = m.newCommitShow(commit, false)
 			return m, tea.Batch(cmds...)
 		case key.Matches(msg, keys.CommitExpandActionDetails):
 			commit := m.commitIndex.SelectedItem().(Commit)
 			expand := !m.commitShow.expandActionDetails
 			m.UpdateLayout(m.layout.TerminalSize)
-			m.commitShow = newCommitShow(commit, m.layout, expand)
+			m.commitShow = m.newCommitShow(commit, expand)
 		}
 	case actionResult:
-		return m, persistAction(Action(msg), m.repo)
+		return m, persistAction(Action(msg), m.store)
 	case actionPersistedMsg:
 		action := msg.Action
 		var commit Commit
@@ -1314,7 +1717,7 @@ func actionsShowHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
 		commit.LatestActions = updatedActions
 		m.commitIndex.SetItem(commitIndex, commit)
 		m.UpdateLayout(m.layout.TerminalSize)
-		m.commitShow = newCommitShow(commit, m.layout, m.commitShow.expandActionDetails)
+		m.commitShow = m.newCommitShow(commit, m.commitShow.expandActionDetails)
 	}
 
 	m.commitShow.viewport, cmd = m.commitShow.viewport.Update(msg)
@@ -1353,33 +1756,165 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
-		return m, tea.Sequence(getCommits(m.repo))
+		autoClose := m.startAutoClose()
+		return m, tea.Sequence(getCommits(m.repo, m.store), autoClose)
 	case tea.BlurMsg:
 		return m, nil
 	case GitRepoReadyMsg:
 		m.repo = msg.repo
 		m.gitConfig = msg.cfg
-		return m, tea.Sequence(getIssues(m.repo), getCommits(m.repo))
+		m.store = openStore(msg.repo, msg.cfg)
+		m.inboxPath = inboxStatePath(msg.repo)
+		return m, tea.Sequence(loadWorkflow(m.repo), loadFieldSchema(m.repo), loadTemplates(m.repo), getIssues(m.store), getTrash(m.store), getMilestones(m.store), getCommits(m.repo, m.store), m.loadInbox())
+	case workflowLoadedMsg:
+		m.workflow = msg.Workflow
+		m.issueIndex.SetDelegate(issueDelegate{m.workflow})
+		m.trashIndex.SetDelegate(trashDelegate{m.workflow})
+		if msg.Err != nil {
+			m.flash = fmt.Sprintf("%s: %v; using the default workflow", workflowPath, msg.Err)
+			m.UpdateLayout(m.layout.TerminalSize)
+		}
+		m.relinkCommits()
+		if m.repo == nil {
+			return m, nil
+		}
+		// commits can only close issues once it's known which statuses
+		// count as done
+		autoClose := m.startAutoClose()
+		return m, autoClose
+	case fieldsLoadedMsg:
+		m.fieldSchema = msg.Schema
+		if msg.Err != nil {
+			m.flash = fmt.Sprintf("%s: %v", fieldsPath, msg.Err)
+			m.UpdateLayout(m.layout.TerminalSize)
+		}
+	case templatesLoadedMsg:
+		m.templates = msg.Templates
+		if msg.Err != nil {
+			m.flash = fmt.Sprintf("%s: %v", templatesPath, msg.Err)
+			m.UpdateLayout(m.layout.TerminalSize)
+		}
+	case syncFinishedMsg:
+		if msg.Err != nil {
+			m.flash = fmt.Sprintf("sync failed: %v", msg.Err)
+		} else {
+			m.flash = msg.Report.String()
+		}
+		m.UpdateLayout(m.layout.TerminalSize)
+		autoClose := m.startAutoClose()
+		return m, tea.Sequence(getIssues(m.store), getTrash(m.store), getMilestones(m.store), getCommits(m.repo, m.store), autoClose, m.loadInbox())
+	case gcFinishedMsg:
+		switch {
+		case msg.Err != nil:
+			m.flash = fmt.Sprintf("gc failed: %v", msg.Err)
+		case msg.Report == GCReport{DryRun: true}:
+			m.flash = "nothing to collect"
+		case msg.Report.DryRun:
+			// show what would go and wait for a confirmation
+			m.flash = ""
+			m.gcReport = msg.Report
+			m.underlayPath = m.path
+			m.path = gcConfirmationPath
+			m.UpdateLayout(m.layout.TerminalSize)
+			return m, nil
+		default:
+			m.flash = msg.Report.String()
+		}
+		m.UpdateLayout(m.layout.TerminalSize)
+		return m, tea.Sequence(getIssues(m.store), getTrash(m.store), getMilestones(m.store), getCommits(m.repo, m.store))
 	case IssuesReadyMsg:
 		var listItems []list.Item
 		for _, issue := range msg {
 			listItems = append(listItems, issue)
 		}
 		m.issueIndex.SetItems(listItems)
+		m.relinkCommits()
+	case TrashReadyMsg:
+		var listItems []list.Item
+		for _, issue := range msg {
+			listItems = append(listItems, deletedIssue{issue})
+		}
+		m.trashIndex.SetItems(listItems)
+	case inboxReadyMsg:
+		m.inboxIndex.SetItems(convertSlice(msg.Notifications, func(n notification) list.Item {
+			return list.Item(n)
+		}))
+	case issueRestoredMsg:
+		m.removeFromTrash(msg.Issue.Id)
+		issues := convertSlice(m.issueIndex.Items(), func(item list.Item) Issue {
+			return item.(Issue)
+		})
+		items := convertSlice(SortIssues(append(issues, msg.Issue), m.workflow), func(issue Issue) list.Item {
+			return list.Item(issue)
+		})
+		m.issueIndex.SetItems(items)
+		m.relinkCommits()
+		m.flash = fmt.Sprintf("restored #%s", msg.Issue.Shortcode)
+		m.UpdateLayout(m.layout.TerminalSize)
+		return m, nil
+	case issuePurgedMsg:
+		m.removeFromTrash(msg.Id)
+		return m, nil
+	case issuesAutoClosedMsg:
+		m.autoClosing = false
+		if len(msg) == 0 {
+			return m, nil
+		}
+		var shortcodes []string
+		for _, issue := range msg {
+			shortcodes = append(shortcodes, "#"+issue.Shortcode)
+		}
+		m.flash = fmt.Sprintf("closed %s, fixed on the main branch", strings.Join(shortcodes, ", "))
+		m.UpdateLayout(m.layout.TerminalSize)
+		return m, getIssues(m.store)
+	case issueFormInvalidMsg:
+		m.flash = msg.Err.Error()
+		m.UpdateLayout(m.layout.TerminalSize)
+		return m, nil
+	case loadFailedMsg:
+		m.flash = fmt.Sprintf("loading %s failed: %v", msg.What, msg.Err)
+		m.UpdateLayout(m.layout.TerminalSize)
+		return m, nil
+	case attachmentFailedMsg:
+		m.flash = fmt.Sprintf("attach failed: %v", msg.Err)
+		m.UpdateLayout(m.layout.TerminalSize)
+		return m, nil
+	case attachmentsExtractedMsg:
+		if msg.Err != nil {
+			m.flash = fmt.Sprintf("extract failed: %v", msg.Err)
+		} else {
+			m.flash = fmt.Sprintf("extracted %s", strings.Join(msg.Paths, ", "))
+		}
+		m.UpdateLayout(m.layout.TerminalSize)
+		return m, nil
+	case MilestonesReadyMsg:
+		m.setMilestones(msg)
+		m.relinkCommits()
+	case milestonePersistedMsg:
+		milestones := m.loadedMilestones()
+		if i := slices.IndexFunc(milestones, func(milestone Milestone) bool { return milestone.Id == msg.Milestone.Id }); i >= 0 {
+			milestones[i] = msg.Milestone
+		} else {
+			milestones = append(milestones, msg.Milestone)
+		}
+		m.setMilestones(milestones)
+		for i, item := range m.milestoneIndex.Items() {
+			if item.(Milestone).Id == msg.Milestone.Id {
+				m.milestoneIndex.Select(i)
+			}
+		}
+		m.relinkCommits()
+		return m, nil
 	case CommitListReadyMsg:
 		var listItems []list.Item
 		for _, commit := range msg {
 			listItems = append(listItems, commit)
 		}
 		m.commitIndex.SetItems(listItems)
+		m.relinkCommits()
 	case commentForm:
-		currentIssue := m.issueIndex.SelectedItem().(Issue)
-		currentIssue.Comments = append(currentIssue.Comments, Comment{
-			Author:  m.gitConfig.User.Email,
-			Content: msg.contentInput.Value(),
-		})
-
-		cmd = persistIssue(currentIssue, m.repo)
+		currentIssue := msg.apply(m.issueIndex.SelectedItem().(Issue), m.gitConfig.User.Email)
+		cmd = persistIssue(currentIssue, m.store)
 		return m, cmd
 	case issuePersistedMsg:
 		if !msg.Issue.DeletedAt.IsZero() {
@@ -1387,6 +1922,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.issueIndex.RemoveItem(currentIndex)
 			m.issueIndex.Select(clamp(currentIndex-1, 0, len(m.issueIndex.Items())))
 			m.issueIndex, cmd = m.issueIndex.Update(msg)
+			m.trashIndex.InsertItem(0, deletedIssue{msg.Issue})
 		} else {
 			issues := convertSlice(m.issueIndex.Items(), func(item list.Item) Issue {
 				return item.(Issue)
@@ -1401,7 +1937,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				issues = append(issues, msg.Issue)
 			}
 
-			sortedIssues := SortIssues(issues)
+			sortedIssues := SortIssues(issues, m.workflow)
 
 			var listIndexToFocus int
 			for i, issue := range sortedIssues {
@@ -1414,10 +1950,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			})
 			m.issueIndex.SetItems(items)
 			m.issueIndex.Select(listIndexToFocus)
+			m.relinkCommits()
 			m.commentForm = newCommentForm()
-			m.issueShow = newIssueShow(msg.Issue, m.layout)
+			selectedComment, raw := m.issueShow.selectedComment, m.issueShow.raw
+			if m.issueShow.issue.Id != msg.Issue.Id {
+				selectedComment, raw = "", false
+			}
+			m.issueShow = m.buildIssueShow(m.linkedIssue(msg.Issue), "", raw)
 			if msg.ScrollToBottom {
 				m.issueShow.viewport.GotoBottom()
+			} else if findComment(msg.Issue.Comments, selectedComment) >= 0 {
+				m.issueShow = m.selectComment(selectedComment)
 			}
 		}
 
@@ -1521,18 +2064,7 @@ func (m Model) HelpKeys() keyMap {
 			key.WithKeys("enter"),
 			key.WithHelp("enter", "edit issue"),
 		),
-		IssueStatusDone: key.NewBinding(
-			key.WithKeys(" "),
-			key.WithHelp("space", "toggle done"),
-		),
-		IssueStatusWontDo: key.NewBinding(
-			key.WithKeys("w"),
-			key.WithHelp("w", "toggle wont-do"),
-		),
-		IssueStatusInProgress: key.NewBinding(
-			key.WithKeys("p"),
-			key.WithHelp("p", "toggle in-progress"),
-		),
+		IssueStatusKeys: m.workflow.keyBindings(),
 		IssueCommentFormFocus: key.NewBinding(
 			key.WithKeys("c"),
 			key.WithHelp("c", "toggle issue comment form"),
@@ -1549,6 +2081,90 @@ func (m Model) HelpKeys() keyMap {
 			key.WithKeys("backspace"),
 			key.WithHelp("backspace", "delete issue"),
 		),
+		IssueHistory: key.NewBinding(
+			key.WithKeys("h"),
+			key.WithHelp("h", "toggle issue history"),
+		),
+		IssueResolveOurs: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "resolve conflict with ours"),
+		),
+		IssueAssignMe: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "assign/unassign me"),
+		),
+		IssueAssign: key.NewBinding(
+			key.WithKeys("A"),
+			key.WithHelp("A", "assign/unassign someone"),
+		),
+		IssueToggleMarkdown: key.NewBinding(
+			key.WithKeys("`"),
+			key.WithHelp("`", "toggle markdown source"),
+		),
+		CommentNext: key.NewBinding(
+			key.WithKeys("]"),
+			key.WithHelp("]", "next comment"),
+		),
+		CommentPrev: key.NewBinding(
+			key.WithKeys("["),
+			key.WithHelp("[", "previous comment"),
+		),
+		CommentEdit: key.NewBinding(
+			key.WithKeys("E"),
+			key.WithHelp("E", "edit my comment"),
+		),
+		CommentDelete: key.NewBinding(
+			key.WithKeys("backspace"),
+			key.WithHelp("backspace", "delete my comment"),
+		),
+		CommentReply: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "reply to comment"),
+		),
+		CommentReact: key.NewBinding(
+			key.WithKeys("+"),
+			key.WithHelp("+", "react to comment"),
+		),
+		IssueSort: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "change sort order"),
+		),
+		MilestoneNew: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "new milestone"),
+		),
+		MilestoneShowFocus: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "show milestone"),
+		),
+		MilestoneEdit: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "edit milestone"),
+		),
+		MilestoneToggleClosed: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "close/reopen milestone"),
+		),
+		PickerPrev: key.NewBinding(
+			key.WithKeys("left"),
+			key.WithHelp("←", "previous option"),
+		),
+		PickerNext: key.NewBinding(
+			key.WithKeys("right"),
+			key.WithHelp("→", "next option"),
+		),
+		IssueAttach: key.NewBinding(
+			key.WithKeys("f"),
+			key.WithHelp("f", "attach file"),
+		),
+		IssueExtractAttachments: key.NewBinding(
+			key.WithKeys("x"),
+			key.WithHelp("x", "extract attachment"),
+		),
+		IssueResolveTheirs: key.NewBinding(
+			key.WithKeys("t"),
+			key.WithHelp("t", "resolve conflict with theirs"),
+		),
 		IssueConfirmDelete: key.NewBinding(
 			key.WithKeys("enter"),
 			key.WithHelp("enter", "confirm issue deletion"),
@@ -1581,6 +2197,42 @@ func (m Model) HelpKeys() keyMap {
 			key.WithKeys("e"),
 			key.WithHelp("e", "expand action details"),
 		),
+		Sync: key.NewBinding(
+			key.WithKeys("S"),
+			key.WithHelp("S", "sync with remote"),
+		),
+		GarbageCollect: key.NewBinding(
+			key.WithKeys("G"),
+			key.WithHelp("G", "prune old runs and deleted issues"),
+		),
+		GarbageCollectConfirm: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "confirm gc"),
+		),
+		TrashRestore: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "restore issue"),
+		),
+		TrashPurge: key.NewBinding(
+			key.WithKeys("backspace"),
+			key.WithHelp("backspace", "purge issue"),
+		),
+		InboxOpen: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "open issue"),
+		),
+		InboxToggleRead: key.NewBinding(
+			key.WithKeys("m"),
+			key.WithHelp("m", "mark read/unread"),
+		),
+		InboxReadAll: key.NewBinding(
+			key.WithKeys("M"),
+			key.WithHelp("M", "mark all read"),
+		),
+		TrashConfirmPurge: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "confirm purge"),
+		),
 	}
 
 	keys.Path = m.path
@@ -1595,11 +2247,24 @@ func (m Model) renderTabs(activeTab string) string {
 		if t == activeTab {
 			style = activeTabStyle
 		}
-		renderedTabs = append(renderedTabs, style.Render(t))
+		label := t
+		if unread := m.unreadCount(); t == "Inbox" && unread > 0 {
+			label = fmt.Sprintf("%s %s", t, lipgloss.NewStyle().Foreground(styles.Theme.RedText).Render(fmt.Sprintf("(%d)", unread)))
+		}
+		renderedTabs = append(renderedTabs, style.Render(label))
 	}
 	return lipgloss.JoinHorizontal(lipgloss.Top, renderedTabs...)
 }
 
+func (m Model) footerView() string {
+	if m.flash == "" {
+		return m.help.View(m.HelpKeys())
+	}
+
+	flash := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render(m.flash)
+	return lipgloss.JoinVertical(lipgloss.Left, flash, m.help.View(m.HelpKeys()))
+}
+
 func (m Model) renderMainLayout(header, left, right, footer string) string {
 	if right == "" {
 		right = ""
@@ -1622,6 +2287,44 @@ func (m Model) renderMainLayout(header, left, right, footer string) string {
 		issue := m.issueIndex.SelectedItem().(Issue)
 		overlayContent := overlayBoxStyle.Render(fmt.Sprintf("Delete issue #%s?", issue.Shortcode))
 		return PlaceOverlay((m.layout.TerminalSize.Width/2 - 20), (m.layout.TerminalSize.Height/2 - 3), overlayContent, layout, false)
+	} else if m.path == issuesAttachPath {
+		overlayBoxStyle := lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder()).BorderForeground(m.styles.Theme.FaintBorder).Foreground(m.styles.Theme.PrimaryText).Width(60).Height(4).Padding(1)
+		overlayContent := overlayBoxStyle.Render(fmt.Sprintf("Attach a file to #%s\n\n%s", m.issueShow.issue.Shortcode, m.attachInput.View()))
+		return PlaceOverlay((m.layout.TerminalSize.Width/2 - 30), (m.layout.TerminalSize.Height/2 - 3), overlayContent, layout, false)
+	} else if m.path == issuesExtractPath {
+		overlayBoxStyle := lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder()).BorderForeground(m.styles.Theme.FaintBorder).Foreground(m.styles.Theme.PrimaryText).Width(60).Height(4).Padding(1)
+		overlayContent := overlayBoxStyle.Render(fmt.Sprintf("Extract an attachment from #%s\n\n%s", m.issueShow.issue.Shortcode, m.extractInput.View()))
+		return PlaceOverlay((m.layout.TerminalSize.Width/2 - 30), (m.layout.TerminalSize.Height/2 - 3), overlayContent, layout, false)
+	} else if m.path == issuesAssignPath {
+		overlayBoxStyle := lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder()).BorderForeground(m.styles.Theme.FaintBorder).Foreground(m.styles.Theme.PrimaryText).Width(60).Height(4).Padding(1)
+		issue := m.issueIndex.SelectedItem().(Issue)
+		overlayContent := overlayBoxStyle.Render(fmt.Sprintf("Assign or unassign #%s\n\n%s", issue.Shortcode, m.assignInput.View()))
+		return PlaceOverlay((m.layout.TerminalSize.Width/2 - 30), (m.layout.TerminalSize.Height/2 - 3), overlayContent, layout, false)
+	} else if m.path == issuesCommentDeletePath {
+		overlayBoxStyle := lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder()).BorderForeground(m.styles.Theme.FaintBorder).Foreground(m.styles.Theme.PrimaryText).Width(40).Height(4).Padding(1)
+		overlayContent := overlayBoxStyle.Render("Delete this comment?")
+		return PlaceOverlay((m.layout.TerminalSize.Width/2 - 20), (m.layout.TerminalSize.Height/2 - 3), overlayContent, layout, false)
+	} else if m.path == issuesReactPath {
+		overlayBoxStyle := lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder()).BorderForeground(m.styles.Theme.FaintBorder).Foreground(m.styles.Theme.PrimaryText).Width(50).Height(4).Padding(1)
+		overlayContent := overlayBoxStyle.Render(m.reactionPickerView())
+		return PlaceOverlay((m.layout.TerminalSize.Width/2 - 25), (m.layout.TerminalSize.Height/2 - 3), overlayContent, layout, false)
+	} else if m.path == issuesTemplatePickerPath {
+		overlayBoxStyle := lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder()).BorderForeground(m.styles.Theme.FaintBorder).Foreground(m.styles.Theme.PrimaryText).Width(60).Height(len(m.templates) + 4).Padding(1)
+		overlayContent := overlayBoxStyle.Render(m.templatePickerView())
+		return PlaceOverlay((m.layout.TerminalSize.Width/2 - 30), (m.layout.TerminalSize.Height/2 - 3), overlayContent, layout, false)
+	} else if m.path == milestonesFormPath {
+		overlayBoxStyle := lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder()).BorderForeground(m.styles.Theme.FaintBorder).Foreground(m.styles.Theme.PrimaryText).Width(70).Height(6).Padding(1)
+		overlayContent := overlayBoxStyle.Render(m.milestoneFormView())
+		return PlaceOverlay((m.layout.TerminalSize.Width/2 - 35), (m.layout.TerminalSize.Height/2 - 4), overlayContent, layout, false)
+	} else if m.path == trashPurgeConfirmationPath {
+		overlayBoxStyle := lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder()).BorderForeground(m.styles.Theme.FaintBorder).Foreground(m.styles.Theme.PrimaryText).Width(40).Height(4).Padding(1)
+		issue := m.trashIndex.SelectedItem().(deletedIssue)
+		overlayContent := overlayBoxStyle.Render(fmt.Sprintf("Purge issue #%s? This cannot be undone.", issue.Shortcode))
+		return PlaceOverlay((m.layout.TerminalSize.Width/2 - 20), (m.layout.TerminalSize.Height/2 - 3), overlayContent, layout, false)
+	} else if m.path == gcConfirmationPath {
+		overlayBoxStyle := lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder()).BorderForeground(m.styles.Theme.FaintBorder).Foreground(m.styles.Theme.PrimaryText).Width(60).Height(6).Padding(1)
+		overlayContent := overlayBoxStyle.Render(fmt.Sprintf("Collect garbage?\n\nThis %s. It cannot be undone.", m.gcReport))
+		return PlaceOverlay((m.layout.TerminalSize.Width/2 - 30), (m.layout.TerminalSize.Height/2 - 4), overlayContent, layout, false)
 	} else {
 		return layout
 	}
@@ -1635,8 +2338,8 @@ func (m Model) renderIssuesView() string {
 		right = m.issueShowView()
 	case issuesCommentContentPath, issuesCommentConfirmationPath:
 		right = lipgloss.JoinVertical(lipgloss.Left, m.issueShowView(), m.commentFormView())
-	case issuesEditTitlePath, issuesEditLabelsPath, issuesEditDescriptionPath, issuesEditConfirmationPath,
-		issuesNewTitlePath, issuesNewLabelsPath, issuesNewDescriptionPath, issuesNewConfirmationPath:
+	case issuesEditTitlePath, issuesEditLabelsPath, issuesEditRelationsPath, issuesEditMilestonePath, issuesEditPriorityPath, issuesEditDuePath, issuesEditFieldsPath, issuesEditDescriptionPath, issuesEditConfirmationPath,
+		issuesNewTitlePath, issuesNewLabelsPath, issuesNewRelationsPath, issuesNewMilestonePath, issuesNewPriorityPath, issuesNewDuePath, issuesNewFieldsPath, issuesNewDescriptionPath, issuesNewConfirmationPath:
 		right = m.issueFormView()
 	}
 
@@ -1647,7 +2350,7 @@ func (m Model) renderIssuesView() string {
 		right = lipgloss.JoinVertical(lipgloss.Left, m.issueShowView(), m.commentFormView())
 	}
 
-	return m.renderMainLayout(m.renderTabs("Issues"), left, right, m.help.View(m.HelpKeys()))
+	return m.renderMainLayout(m.renderTabs("Issues"), left, right, m.footerView())
 }
 
 func (m Model) renderActionsView() string {
@@ -1656,7 +2359,7 @@ func (m Model) renderActionsView() string {
 	if m.path == actionsShowPath {
 		right = m.commitShowView()
 	}
-	return m.renderMainLayout(m.renderTabs("Actions"), left, right, m.help.View(m.HelpKeys()))
+	return m.renderMainLayout(m.renderTabs("Actions"), left, right, m.footerView())
 }
 
 func (m Model) View() string {
@@ -1667,25 +2370,40 @@ func (m Model) View() string {
 	var view string
 	switch m.path {
 	case issuesIndexPath, issuesShowPath, issuesDeleteConfirmationPath, issuesCommentContentPath, issuesCommentConfirmationPath,
-		issuesEditTitlePath, issuesEditLabelsPath, issuesEditDescriptionPath, issuesEditConfirmationPath,
-		issuesNewTitlePath, issuesNewLabelsPath, issuesNewDescriptionPath, issuesNewConfirmationPath:
+		issuesEditTitlePath, issuesEditLabelsPath, issuesEditRelationsPath, issuesEditMilestonePath, issuesEditPriorityPath, issuesEditDuePath, issuesEditFieldsPath, issuesEditDescriptionPath, issuesEditConfirmationPath,
+		issuesNewTitlePath, issuesNewLabelsPath, issuesNewRelationsPath, issuesNewMilestonePath, issuesNewPriorityPath, issuesNewDuePath, issuesNewFieldsPath, issuesNewDescriptionPath, issuesNewConfirmationPath, issuesAttachPath, issuesAssignPath,
+		issuesCommentDeletePath, issuesReactPath, issuesTemplatePickerPath, issuesExtractPath:
 		view = m.renderIssuesView()
+	case milestonesIndexPath, milestonesShowPath, milestonesFormPath:
+		view = m.renderMilestonesView()
 	case actionsIndexPath, actionsShowPath:
 		view = m.renderActionsView()
+	case gcConfirmationPath:
+		if m.underlayPath == actionsIndexPath {
+			view = m.renderActionsView()
+		} else {
+			view = m.renderIssuesView()
+		}
+	case trashIndexPath, trashPurgeConfirmationPath:
+		view = m.renderTrashView()
+	case inboxIndexPath:
+		view = m.renderInboxView()
 	}
 
 	return docStyle.Render(view)
 }
 
 type Commit struct {
-	Hash            string    `json:"id"`
-	AbbreviatedHash string    `json:"abbreviatedId"`
-	AuthorEmail     string    `json:"author_email"`
-	AuthorName      string    `json:"author_name"`
-	Message         string    `json:"message"`
-	Timestamp       time.Time `json:"timestamp"`
-	LatestActions   []Action  `json:"latestActions"`
-	Repo            *git.Repository
+	Hash            string     `json:"id"`
+	AbbreviatedHash string     `json:"abbreviatedId"`
+	AuthorEmail     string     `json:"author_email"`
+	AuthorName      string     `json:"author_name"`
+	Message         string     `json:"message"`
+	Timestamp       time.Time  `json:"timestamp"`
+	LatestActions   []Action   `json:"latestActions"`
+	IssueRefs       []IssueRef `json:"issueRefs,omitempty"`
+	// ReferencedIssues is worked out from IssueRefs; see links.go
+	ReferencedIssues []IssueLink `json:"-"`
 }
 
 func (c Commit) AggregateActionStatus() ActionStatus {
@@ -1716,11 +2434,11 @@ func (c Commit) AggregateActionStatus() ActionStatus {
 	return succeeded
 }
 
-func (c Commit) DeleteExistingActions() tea.Cmd {
+func (c Commit) DeleteExistingActions(store ActionStore) tea.Cmd {
 	var cmds []tea.Cmd
 
 	for _, action := range c.LatestActions {
-		cmds = append(cmds, action.Delete(c.Repo))
+		cmds = append(cmds, action.Delete(store))
 	}
 
 	return tea.Batch(cmds...)
@@ -1768,6 +2486,10 @@ type Action struct {
 	FinishedAt        time.Time    `json:"finishedAt"`
 	Optional          bool         `json:"optional"`
 	ExecutionPosition int          `json:"executionPosition"`
+	SchemaVersion     int          `json:"schema_version"`
+	Signature         *Signature   `json:"signature,omitempty"`
+	// Verified is worked out on load; see sign.go
+	Verified bool `json:"-"`
 }
 
 func NewActions(commit Commit) []Action {
@@ -1792,10 +2514,9 @@ func NewActions(commit Commit) []Action {
 	}
 }
 
-func (c Action) Delete(repo *git.Repository) tea.Cmd {
+func (c Action) Delete(store ActionStore) tea.Cmd {
 	return func() tea.Msg {
-		refName := plumbing.ReferenceName(fmt.Sprintf("refs/ubik/actions/%s", c.Id))
-		err := repo.Storer.RemoveReference(refName)
+		err := store.DeleteAction(c.Id)
 
 		if err != nil {
 			debug("%#v", err)
@@ -1875,144 +2596,90 @@ func getGitRepo() tea.Msg {
 
 type CommitListReadyMsg []Commit
 
-func getCommits(repo *git.Repository) tea.Cmd {
+func getCommits(repo *git.Repository, store ActionStore) tea.Cmd {
 	return func() tea.Msg {
-		var commits []Commit
-		actions := make(map[string][]Action)
-
-		refs, err := repo.References()
-
+		commits, err := loadCommits(repo, store)
 		if err != nil {
 			panic(err)
 		}
 
-		refPath := "refs/ubik/actions"
-
-		err = refs.ForEach(func(ref *plumbing.Reference) error {
-			if !strings.HasPrefix(ref.Name().String(), refPath) {
-				return nil
-			}
-			obj, err := repo.Object(plumbing.AnyObject, ref.Hash())
-			if err != nil {
-				return nil
-			}
-
-			if blob, ok := obj.(*object.Blob); ok {
-				// Read the contents of the blob
-				blobReader, _ := blob.Reader()
-				b, err := io.ReadAll(blobReader)
-				if err != nil {
-					fmt.Printf("Error reading blob content: %v\n", err)
-					return nil
-				}
+		return CommitListReadyMsg(commits)
+	}
+}
 
-				var action Action
-				err = json.Unmarshal(b, &action)
-				if err != nil {
-					panic(err)
-				}
+// loadCommits reads the commit log, newest first, along with each commit's
+// actions and issue references.
+func loadCommits(repo *git.Repository, store ActionStore) ([]Commit, error) {
+	var commits []Commit
+	actions := make(map[string][]Action)
 
-				actions[action.CommitId] = append(actions[action.CommitId], action)
-			}
-			return nil
-		})
+	storedActions, err := store.Actions()
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			panic(err)
-		}
+	for _, action := range storedActions {
+		actions[action.CommitId] = append(actions[action.CommitId], action)
+	}
 
-		logOptions := git.LogOptions{
-			Order: git.LogOrderCommitterTime,
-		}
+	logOptions := git.LogOptions{
+		Order: git.LogOrderCommitterTime,
+	}
 
-		gitCommits, err := repo.Log(&logOptions)
+	gitCommits, err := repo.Log(&logOptions)
 
-		if err != nil {
-			panic(err)
-		}
+	if err != nil {
+		return nil, err
+	}
 
-		err = gitCommits.ForEach(func(c *object.Commit) error {
-			id := c.Hash.String()
-			commitActions := actions[id]
-			slices.SortFunc(commitActions, func(a, b Action) int {
-				return a.ExecutionPosition - b.ExecutionPosition
-			})
-			commits = append(commits, Commit{
-				Hash:            id,
-				AbbreviatedHash: id[:8],
-				AuthorEmail:     c.Author.Email,
-				Timestamp:       c.Author.When,
-				Message:         strings.TrimSuffix(c.Message, "\n"),
-				LatestActions:   actions[id],
-				Repo:            repo,
-			})
-			return nil
+	err = gitCommits.ForEach(func(c *object.Commit) error {
+		id := c.Hash.String()
+		commitActions := actions[id]
+		slices.SortFunc(commitActions, func(a, b Action) int {
+			return a.ExecutionPosition - b.ExecutionPosition
 		})
+		commits = append(commits, Commit{
+			Hash:            id,
+			AbbreviatedHash: id[:8],
+			AuthorEmail:     c.Author.Email,
+			Timestamp:       c.Author.When,
+			Message:         strings.TrimSuffix(c.Message, "\n"),
+			LatestActions:   actions[id],
+			IssueRefs:       parseIssueRefs(c.Message),
+		})
+		return nil
+	})
 
-		if err != nil {
-			panic(err)
-		}
-
-		return CommitListReadyMsg(commits)
+	if err != nil {
+		return nil, err
 	}
+
+	return commits, nil
 }
 
 type IssuesReadyMsg []Issue
 
-func getIssues(repo *git.Repository) tea.Cmd {
+func getIssues(store IssueStore) tea.Cmd {
 	return func() tea.Msg {
 		var issues []Issue
 
-		refs, err := repo.References()
+		storedIssues, err := store.Issues()
 		if err != nil {
 			panic(err)
 		}
 
-		refPath := "refs/ubik/issues"
-
-		err = refs.ForEach(func(ref *plumbing.Reference) error {
-			if !strings.HasPrefix(ref.Name().String(), refPath) {
-				return nil
+		for _, issue := range storedIssues {
+			if issue.DeletedAt.IsZero() {
+				issues = append(issues, issue)
 			}
-
-			obj, err := repo.Object(plumbing.AnyObject, ref.Hash())
-			if err != nil {
-				return nil
-			}
-
-			if blob, ok := obj.(*object.Blob); ok {
-				// Read the contents of the blob
-				blobReader, _ := blob.Reader()
-				b, err := io.ReadAll(blobReader)
-				if err != nil {
-					fmt.Printf("Error reading blob content: %v\n", err)
-					return nil
-				}
-
-				var issue Issue
-				err = json.Unmarshal(b, &issue)
-				if err != nil {
-					panic(err)
-				}
-
-				if issue.DeletedAt.IsZero() {
-					issues = append(issues, issue)
-				}
-			}
-			return nil
-		})
-
-		if err != nil {
-			panic(err)
 		}
 
-		sortedIssues := SortIssues(issues)
-
-		return IssuesReadyMsg(sortedIssues)
+		// they're sorted once they're linked up, see relinkCommits
+		return IssuesReadyMsg(issues)
 	}
 }
 
-func SortIssues(issues []Issue) []Issue {
+func SortIssues(issues []Issue, w Workflow) []Issue {
 	var openIssues []Issue
 	var closedIssues []Issue
 	for _, issue := range issues {
@@ -2020,7 +2687,7 @@ func SortIssues(issues []Issue) []Issue {
 			continue
 		}
 
-		if issue.Status == done || issue.Status == wontDo {
+		if w.isClosed(issue.Status) {
 			closedIssues = append(closedIssues, issue)
 			continue
 		}
@@ -2045,10 +2712,10 @@ type commitShow struct {
 	expandActionDetails bool
 }
 
-func newCommitShow(commit Commit, layout Layout, expandActionDetails bool) commitShow {
+func (m Model) newCommitShow(commit Commit, expandActionDetails bool) commitShow {
 	var s strings.Builder
 
-	viewport := viewport.New(layout.RightSize.Width, layout.RightSize.Height)
+	viewport := viewport.New(m.layout.RightSize.Width, m.layout.RightSize.Height)
 	identifier := lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render(fmt.Sprintf("%s", commit.AbbreviatedHash))
 	var header string
 	if len(commit.LatestActions) > 0 {
@@ -2058,12 +2725,18 @@ func newCommitShow(commit Commit, layout Layout, expandActionDetails bool) commi
 	}
 	s.WriteString(lipgloss.NewStyle().Render(header))
 	s.WriteString("\n")
+	if len(commit.ReferencedIssues) > 0 {
+		s.WriteString("\n" + renderReferencedIssues(commit.ReferencedIssues, m.workflow))
+	}
 
 	for _, action := range commit.LatestActions {
 		s.WriteString(fmt.Sprintf("\n%s %s", action.Status.Icon(), action.Name))
 		if action.Optional {
 			s.WriteString(lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render(" (optional)"))
 		}
+		if action.Status != running {
+			s.WriteString(" " + verificationBadge(action.Verified, action.Signature))
+		}
 		if expandActionDetails {
 			s.WriteString(
 				lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render(
@@ -2090,15 +2763,22 @@ func (m Model) commitShowView() string {
 }
 
 type issueShow struct {
-	issue    Issue
-	viewport viewport.Model
+	issue       Issue
+	viewport    viewport.Model
+	history     []IssueRevision
+	showHistory bool
+	// selectedComment is the commentKey of the comment under the cursor
+	selectedComment string
+	commentOffsets  map[string]int
+	// raw shows the Markdown source instead of rendering it
+	raw bool
 }
 
 func (m *Model) InitIssueShow() {
 	var s strings.Builder
 	identifier := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render(fmt.Sprintf("#%s", m.issueShow.issue.Shortcode))
 	labels := lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render(fmt.Sprintf("%s", strings.Join(m.issueShow.issue.Labels, ",")))
-	header := fmt.Sprintf("%s %s %s\nStatus: %s\n\n", identifier, m.issueShow.issue.Title, labels, m.issueShow.issue.Status.PrettyString())
+	header := fmt.Sprintf("%s %s %s\nStatus: %s\n\n", identifier, m.issueShow.issue.Title, labels, m.workflow.prettyString(m.issueShow.issue.Status))
 	s.WriteString(lipgloss.NewStyle().Render(header))
 	s.WriteString(m.issueShow.issue.Description + "\n")
 
@@ -2112,27 +2792,76 @@ func (m *Model) InitIssueShow() {
 	m.issueShow.viewport.SetContent(s.String())
 }
 
-func newIssueShow(issue Issue, layout Layout) issueShow {
+func (m Model) newIssueShow(issue Issue) issueShow {
+	return m.buildIssueShow(issue, "", false)
+}
+
+func (m Model) buildIssueShow(issue Issue, selectedComment string, raw bool) issueShow {
 	var s strings.Builder
-	viewport := viewport.New(layout.RightSize.Width, layout.RightSize.Height-layout.CommentFormSize.Height)
+	viewport := viewport.New(m.layout.RightSize.Width, m.layout.RightSize.Height-m.layout.CommentFormSize.Height)
 	identifier := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render(fmt.Sprintf("#%s", issue.Shortcode))
 	labels := lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render(fmt.Sprintf("%s", strings.Join(issue.Labels, ",")))
-	header := fmt.Sprintf("%s %s %s\nStatus: %s\n\n", identifier, issue.Title, labels, issue.Status.PrettyString())
+	header := fmt.Sprintf("%s %s %s\nStatus: %s %s\n", identifier, issue.Title, labels, m.workflow.prettyString(issue.Status), verificationBadge(issue.Verified, issue.Signature))
+	if len(issue.Assignees) > 0 {
+		header += fmt.Sprintf("Assignees: %s\n", strings.Join(issue.Assignees, ", "))
+	}
+	if issue.MilestoneTitle != "" {
+		header += fmt.Sprintf("Milestone: %s\n", issue.MilestoneTitle)
+	}
+	if issue.Priority != noPriority {
+		header += fmt.Sprintf("Priority: %s\n", issue.Priority.PrettyString())
+	}
+	if !issue.DueDate.IsZero() {
+		header += fmt.Sprintf("Due: %s\n", renderIssueDueDate(issue, m.workflow))
+	}
+	header += renderFields(issue.Fields, m.fieldSchema)
+	if issue.Imported != nil {
+		header += fmt.Sprintf("Imported from %s\n", issue.Imported)
+	}
+	header += "\n"
 	s.WriteString(lipgloss.NewStyle().Render(header))
-	s.WriteString(issue.Description + "\n")
+	if len(issue.Conflicts) > 0 {
+		s.WriteString(renderConflicts(issue.Conflicts, viewport.Width))
+		s.WriteString("\n")
+	}
+	if raw {
+		s.WriteString(issue.Description + "\n")
+	} else if issue.Description != "" {
+		s.WriteString(renderMarkdown(issue.Description, viewport.Width) + "\n")
+	}
+	if len(issue.Attachments) > 0 {
+		s.WriteString("\n" + renderAttachments(issue.Attachments, 0))
+	}
+	if len(issue.RelatedIssues) > 0 {
+		s.WriteString("\n" + renderRelatedIssues(issue.RelatedIssues, m.workflow))
+	}
+	if len(issue.LinkedCommits) > 0 {
+		s.WriteString("\n" + renderLinkedCommits(issue.LinkedCommits))
+	}
 
-	commentFrameX, _ := commentContentStyle.GetFrameSize()
-	w := lipgloss.NewStyle().Width(viewport.Width - commentFrameX)
+	// attachments are numbered in comment order, whatever the threading
+	attachmentOffsets := make([]int, len(issue.Comments))
+	attachmentOffset := len(issue.Attachments)
+	for i, comment := range issue.Comments {
+		attachmentOffsets[i] = attachmentOffset
+		attachmentOffset += len(comment.Attachments)
+	}
 
-	for _, comment := range issue.Comments {
-		commentHeader := commentHeaderStyle.Inherit(w).Render(fmt.Sprintf("%s commented at %s", comment.Author, comment.CreatedAt.Format(time.RFC822)))
-		s.WriteString(commentContentStyle.Inherit(w).Render(fmt.Sprintf("%s\n%s\n", commentHeader, comment.Content)))
+	commentOffsets := make(map[string]int)
+	for _, threaded := range threadComments(issue.Comments) {
+		comment := issue.Comments[threaded.index]
+		key := commentKey(comment)
+		commentOffsets[key] = strings.Count(s.String(), "\n")
+		s.WriteString(renderComment(comment, viewport.Width, attachmentOffsets[threaded.index], threaded.reply, key == selectedComment, raw))
 	}
 	viewport.SetContent(s.String())
 
 	return issueShow{
-		issue:    issue,
-		viewport: viewport,
+		issue:           issue,
+		viewport:        viewport,
+		selectedComment: selectedComment,
+		commentOffsets:  commentOffsets,
+		raw:             raw,
 	}
 }
 
@@ -2140,11 +2869,13 @@ func (m Model) issueShowView() string {
 	return m.issueShow.viewport.View()
 }
 
-func newIssueForm(identifier, title, description string, labels []string, editing bool) issueForm {
+func newIssueForm(identifier, title, description string, labels []string, relations string, editing bool) issueForm {
 	form := issueForm{
 		identifier:       identifier,
 		titleInput:       textinput.New(),
 		labelsInput:      textinput.New(),
+		relationsInput:   textinput.New(),
+		dueInput:         textinput.New(),
 		descriptionInput: textarea.New(),
 		editing:          editing,
 	}
@@ -2155,6 +2886,15 @@ func newIssueForm(identifier, title, description string, labels []string, editin
 	form.labelsInput.CharLimit = 100
 	form.labelsInput.SetValue(strings.Join(labels, " "))
 
+	form.relationsInput.CharLimit = 300
+	form.relationsInput.Placeholder = "blocked-by:#abc123 parent:#def456"
+	form.relationsInput.SetValue(relations)
+
+	form.milestonePicker = newMilestonePicker(nil, "")
+
+	form.dueInput.CharLimit = 10
+	form.dueInput.Placeholder = "YYYY-MM-DD"
+
 	form.descriptionInput.CharLimit = 0 // unlimited
 	form.descriptionInput.MaxHeight = 0 // unlimited
 	form.descriptionInput.ShowLineNumbers = false
@@ -2179,7 +2919,11 @@ func (m Model) issueFormView() string {
 	if m.issueForm.editing {
 		s.WriteString(fmt.Sprintf("Editing issue %s\n\n", identifier))
 	} else {
-		s.WriteString("New issue\n\n")
+		s.WriteString("New issue")
+		if form.template != "" {
+			s.WriteString(labelStyle(fmt.Sprintf(" from the %s template", form.template)))
+		}
+		s.WriteString("\n\n")
 	}
 
 	s.WriteString(labelStyle("Title"))
@@ -2190,6 +2934,29 @@ func (m Model) issueFormView() string {
 	s.WriteString("\n")
 	s.WriteString(fieldStyle(form.labelsInput.View()))
 	s.WriteString("\n\n")
+	s.WriteString(labelStyle("Relationships"))
+	s.WriteString("\n")
+	s.WriteString(fieldStyle(form.relationsInput.View()))
+	s.WriteString("\n\n")
+	s.WriteString(labelStyle("Milestone"))
+	s.WriteString("\n")
+	s.WriteString(fieldStyle(form.milestonePicker.View(matchRoute(m.path, issuesEditMilestonePath) || matchRoute(m.path, issuesNewMilestonePath))))
+	s.WriteString("\n\n")
+	s.WriteString(labelStyle("Priority"))
+	s.WriteString("\n")
+	priority := form.priority.PrettyString()
+	if matchRoute(m.path, issuesEditPriorityPath) || matchRoute(m.path, issuesNewPriorityPath) {
+		priority = fmt.Sprintf("‹ %s ›", priority)
+	}
+	s.WriteString(fieldStyle(priority))
+	s.WriteString("\n\n")
+	s.WriteString(labelStyle("Due date"))
+	s.WriteString("\n")
+	s.WriteString(fieldStyle(form.dueInput.View()))
+	s.WriteString("\n\n")
+	if len(form.fieldInputs) > 0 {
+		s.WriteString(m.issueFieldsView(labelStyle, fieldStyle))
+	}
 	s.WriteString(labelStyle("Description"))
 	s.WriteString("\n")
 	s.WriteString(fieldStyle(form.descriptionInput.View()))
@@ -2212,6 +2979,9 @@ func (m *issueForm) SetDescription(description string) {
 type commentForm struct {
 	contentInput textarea.Model
 	confirming   bool
+	// editing and replyTo are commentKeys; see comments.go
+	editing string
+	replyTo string
 }
 
 func (m Model) newCommentForm() commentForm {
@@ -2255,6 +3025,10 @@ func (m commentForm) Update(msg tea.Msg) (commentForm, tea.Cmd) {
 
 func (m Model) commentFormView() string {
 	var s strings.Builder
+	if heading := m.commentForm.heading(); heading != "" {
+		s.WriteString(lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render(heading))
+		s.WriteString("\n")
+	}
 	s.WriteString(m.commentForm.contentInput.View())
 	s.WriteString("\n")
 	if m.commentForm.confirming {
@@ -2273,6 +3047,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	m := InitialModel()
 
 	var logFile *os.File
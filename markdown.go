@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wrap"
+)
+
+type markdownRendererKey struct {
+	width int
+	dark  bool
+}
+
+// markdownRenderers keeps a glamour renderer per wrap width and background,
+// since building one sets up a whole style and chroma's highlighting. A
+// renderer can't be used by two renders at once, so the lock is held while
+// rendering too.
+var markdownRenderers = struct {
+	sync.Mutex
+	byKey map[markdownRendererKey]*glamour.TermRenderer
+}{byKey: make(map[markdownRendererKey]*glamour.TermRenderer)}
+
+// markdownRenderer returns the cached renderer for key, building it on first
+// use. The caller holds markdownRenderers' lock.
+func markdownRenderer(key markdownRendererKey) (*glamour.TermRenderer, error) {
+	if renderer, ok := markdownRenderers.byKey[key]; ok {
+		return renderer, nil
+	}
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithStyles(markdownStyle(styles.Theme, key.dark)),
+		glamour.WithWordWrap(key.width),
+		glamour.WithColorProfile(lipgloss.ColorProfile()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	markdownRenderers.byKey[key] = renderer
+	return renderer, nil
+}
+
+// renderMarkdown renders the Markdown in issue descriptions and comments for
+// the terminal with glamour, wrapped to width. If it can't be rendered, it's
+// shown as written.
+func renderMarkdown(source string, width int) string {
+	width = max(width, 10)
+
+	markdownRenderers.Lock()
+	renderer, err := markdownRenderer(markdownRendererKey{width: width, dark: lipgloss.HasDarkBackground()})
+	if err != nil {
+		markdownRenderers.Unlock()
+		return source
+	}
+	rendered, err := renderer.Render(source)
+	markdownRenderers.Unlock()
+	if err != nil {
+		return source
+	}
+
+	// glamour pads every line out to the wrap width, and leaves words longer
+	// than it alone
+	lines := strings.Split(rendered, "\n")
+	for i, line := range lines {
+		lines[i] = wrap.String(strings.TrimRight(line, " "), width)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// markdownStyle is the glamour style in the colors of theme: plain text in
+// the terminal's own color, no margins since the issue view has its own, and
+// code highlighted like the rest of the UI uses yellow, green and red.
+func markdownStyle(theme Theme, dark bool) ansi.StyleConfig {
+	pick := func(color lipgloss.AdaptiveColor) string {
+		if dark {
+			return color.Dark
+		}
+		return color.Light
+	}
+	color := func(color lipgloss.AdaptiveColor) *string {
+		c := pick(color)
+		return &c
+	}
+	// chroma only understands hex colors
+	code := func(color lipgloss.AdaptiveColor) ansi.StylePrimitive {
+		c := ansiToHex(pick(color))
+		return ansi.StylePrimitive{Color: &c}
+	}
+	str := func(s string) *string { return &s }
+	yes := true
+	indent := uint(1)
+	var none uint
+
+	return ansi.StyleConfig{
+		BlockQuote: ansi.StyleBlock{
+			StylePrimitive: ansi.StylePrimitive{Color: color(theme.SecondaryText)},
+			Indent:         &indent,
+			IndentToken:    str("│ "),
+		},
+		List: ansi.StyleList{LevelIndent: 2},
+		Heading: ansi.StyleBlock{StylePrimitive: ansi.StylePrimitive{
+			BlockSuffix: "\n",
+			Color:       color(theme.SecondaryText),
+			Bold:        &yes,
+		}},
+		H1:            ansi.StyleBlock{StylePrimitive: ansi.StylePrimitive{Color: color(theme.PrimaryText), Underline: &yes}},
+		H2:            ansi.StyleBlock{StylePrimitive: ansi.StylePrimitive{Color: color(theme.PrimaryText)}},
+		Strikethrough: ansi.StylePrimitive{CrossedOut: &yes},
+		Emph:          ansi.StylePrimitive{Italic: &yes},
+		Strong:        ansi.StylePrimitive{Bold: &yes},
+		HorizontalRule: ansi.StylePrimitive{
+			Color:  color(theme.FaintText),
+			Format: "\n────────\n",
+		},
+		Item:        ansi.StylePrimitive{BlockPrefix: "• "},
+		Enumeration: ansi.StylePrimitive{BlockPrefix: ". "},
+		Task:        ansi.StyleTask{Ticked: "☑ ", Unticked: "☐ "},
+		Link:        ansi.StylePrimitive{Color: color(theme.FaintText), Underline: &yes},
+		LinkText:    ansi.StylePrimitive{Color: color(theme.PrimaryText)},
+		ImageText:   ansi.StylePrimitive{Color: color(theme.FaintText), Format: "Image: {{.text}} →"},
+		Code:        ansi.StyleBlock{StylePrimitive: ansi.StylePrimitive{Color: color(theme.YellowText)}},
+		CodeBlock: ansi.StyleCodeBlock{
+			StyleBlock: ansi.StyleBlock{Margin: &none},
+			Chroma: &ansi.Chroma{
+				Comment:         code(theme.FaintText),
+				CommentPreproc:  code(theme.FaintText),
+				Keyword:         code(theme.YellowText),
+				KeywordReserved: code(theme.YellowText),
+				KeywordType:     code(theme.YellowText),
+				LiteralString:   code(theme.GreenText),
+				LiteralNumber:   code(theme.RedText),
+				NameBuiltin:     code(theme.SecondaryText),
+				GenericDeleted:  code(theme.RedText),
+				GenericInserted: code(theme.GreenText),
+				Error:           code(theme.RedText),
+			},
+		},
+		Table: ansi.StyleTable{
+			CenterSeparator: str("┼"),
+			ColumnSeparator: str("│"),
+			RowSeparator:    str("─"),
+		},
+	}
+}
+
+var ansiBaseColors = []string{
+	"#000000", "#800000", "#008000", "#808000", "#000080", "#800080", "#008080", "#c0c0c0",
+	"#808080", "#ff0000", "#00ff00", "#ffff00", "#0000ff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// ansiToHex converts a terminal color, an ANSI 256-color number or a hex
+// color, to a hex color using the xterm palette.
+func ansiToHex(color string) string {
+	n, err := strconv.Atoi(color)
+	if err != nil || n < 0 || n > 255 {
+		return color
+	}
+	switch {
+	case n < 16:
+		return ansiBaseColors[n]
+	case n < 232:
+		levels := []int{0, 95, 135, 175, 215, 255}
+		n -= 16
+		return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[n/6%6], levels[n%6])
+	default:
+		gray := 8 + 10*(n-232)
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	source := strings.Join([]string{
+		"# Crash on startup",
+		"",
+		"It **panics** when `config.json` is _missing_, see [the docs](https://example.com/docs).",
+		"",
+		"- [x] reproduce",
+		"- [ ] fix",
+		"  - nested detail",
+		"",
+		"1. first",
+		"",
+		"> quoted *text*",
+		"",
+		"```go",
+		"func main() { // entry",
+		"}",
+		"```",
+		"",
+		"---",
+	}, "\n")
+
+	rendered := ansi.Strip(renderMarkdown(source, 40))
+	assert.Equal(t, strings.Join([]string{
+		"Crash on startup",
+		"",
+		"It panics when config.json is missing,",
+		"see the docs https://example.com/docs.",
+		"",
+		"☑ reproduce",
+		"☐ fix",
+		"  • nested detail",
+		"",
+		"",
+		"1. first",
+		"",
+		"│ quoted text",
+		"",
+		"func main() { // entry",
+		"}",
+		"",
+		"────────",
+	}, "\n"), rendered)
+}
+
+func TestRenderMarkdownWraps(t *testing.T) {
+	rendered := renderMarkdown("- "+strings.Repeat("word ", 10)+"\n\n"+strings.Repeat("x", 25), 20)
+	for _, line := range strings.Split(rendered, "\n") {
+		assert.LessOrEqual(t, lipgloss.Width(line), 20, line)
+		assert.Equal(t, strings.TrimRight(line, " "), line, "lines aren't padded")
+	}
+}
+
+func TestRenderMarkdownInline(t *testing.T) {
+	tests := map[string]string{
+		"snake_case_name":   "snake_case_name",
+		`\*not emphasis\*`:  "*not emphasis*",
+		"2 * 3 * 4":         "2 * 3 * 4",
+		"[](https://a.b)":   "https://a.b",
+		"~~gone~~ and *it*": "gone and it",
+	}
+	for input, expected := range tests {
+		assert.Equal(t, expected, ansi.Strip(renderMarkdown(input, 40)), input)
+	}
+}
+
+func TestMarkdownStyle(t *testing.T) {
+	theme := DefaultStyles().Theme
+	dark := markdownStyle(theme, true)
+	assert.Equal(t, theme.PrimaryText.Dark, *dark.H1.Color)
+	assert.Equal(t, theme.YellowText.Dark, *dark.Code.Color)
+	assert.Equal(t, ansiToHex(theme.FaintText.Dark), *dark.CodeBlock.Chroma.Comment.Color, "chroma gets hex colors")
+
+	light := markdownStyle(theme, false)
+	assert.Equal(t, theme.PrimaryText.Light, *light.H1.Color)
+}
+
+func TestRenderMarkdownReusesRenderer(t *testing.T) {
+	key := markdownRendererKey{width: 33, dark: lipgloss.HasDarkBackground()}
+	first := renderMarkdown("*one*", 33)
+	renderer := markdownRenderers.byKey[key]
+	require.NotNil(t, renderer)
+
+	assert.Equal(t, first, renderMarkdown("*one*", 33))
+	assert.Same(t, renderer, markdownRenderers.byKey[key], "the same width reuses the renderer")
+}
+
+func TestAnsiToHex(t *testing.T) {
+	assert.Equal(t, "#000000", ansiToHex("000"))
+	assert.Equal(t, "#ff00ff", ansiToHex("013"))
+	assert.Equal(t, "#5f87af", ansiToHex("67"))
+	assert.Equal(t, "#303030", ansiToHex("236"))
+	assert.Equal(t, "#3B875E", ansiToHex("#3B875E"))
+}
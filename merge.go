@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// IssueConflict records a field that was changed differently on both sides of
+// a merge. The merged issue keeps Ours until someone picks a side.
+type IssueConflict struct {
+	Field  string `json:"field"`
+	Ours   string `json:"ours"`
+	Theirs string `json:"theirs"`
+}
+
+const (
+	conflictFieldTitle       = "title"
+	conflictFieldDescription = "description"
+	conflictFieldStatus      = "status"
+)
+
+// mergeIssues does a field-level three-way merge of two diverged copies of an
+// issue against their common ancestor. Fields changed on only one side take
+// that side's value, labels merge as a set, comments are unioned, and fields
+// changed differently on both sides are recorded as conflicts.
+func mergeIssues(base, ours, theirs Issue) Issue {
+	merged := ours
+	merged.Conflicts = nil
+
+	var conflict *IssueConflict
+	merged.Title, conflict = mergeScalar(conflictFieldTitle, base.Title, ours.Title, theirs.Title)
+	merged.Conflicts = appendConflict(merged.Conflicts, conflict)
+
+	merged.Description, conflict = mergeScalar(conflictFieldDescription, base.Description, ours.Description, theirs.Description)
+	merged.Conflicts = appendConflict(merged.Conflicts, conflict)
+
+	var status string
+	status, conflict = mergeScalar(conflictFieldStatus, string(base.Status), string(ours.Status), string(theirs.Status))
+	merged.Status = issueStatus(status)
+	merged.Conflicts = appendConflict(merged.Conflicts, conflict)
+
+	merged.Labels = mergeLabels(base.Labels, ours.Labels, theirs.Labels)
+	// a clash over the milestone isn't worth a conflict; ours wins
+	merged.MilestoneId, _ = mergeScalar("milestone", base.MilestoneId, ours.MilestoneId, theirs.MilestoneId)
+	// likewise for priority; due dates take the later one
+	var priority string
+	priority, _ = mergeScalar("priority", string(base.Priority), string(ours.Priority), string(theirs.Priority))
+	merged.Priority = issuePriority(priority)
+	merged.DueDate = mergeTime(base.DueDate, ours.DueDate, theirs.DueDate)
+	merged.Fields = mergeFields(base.Fields, ours.Fields, theirs.Fields)
+	merged.Assignees = mergeLabels(base.Assignees, ours.Assignees, theirs.Assignees)
+	merged.Relations = mergeRelations(base.Relations, ours.Relations, theirs.Relations)
+	merged.Attachments = mergeAttachments(ours.Attachments, theirs.Attachments)
+	merged.Comments = mergeComments(base.Comments, ours.Comments, theirs.Comments)
+	merged.DeletedAt = mergeTime(base.DeletedAt, ours.DeletedAt, theirs.DeletedAt)
+	if !merged.DeletedAt.Equal(ours.DeletedAt) {
+		merged.DeletedBy = theirs.DeletedBy
+	}
+
+	if theirs.UpdatedAt.After(merged.UpdatedAt) {
+		merged.UpdatedAt = theirs.UpdatedAt
+	}
+	// whoever imported the newer export has the newer metadata
+	if theirs.Imported != nil && (ours.Imported == nil || theirs.Imported.UpdatedAt.After(ours.Imported.UpdatedAt)) {
+		merged.Imported = theirs.Imported
+	}
+
+	// conflicts nobody resolved yet survive the merge. One that was already
+	// there at the base and is gone from either side was resolved on that side.
+	for _, c := range slices.Concat(ours.Conflicts, theirs.Conflicts) {
+		if slices.ContainsFunc(merged.Conflicts, func(existing IssueConflict) bool { return existing.Field == c.Field }) {
+			continue
+		}
+		resolved := slices.Contains(base.Conflicts, c) && (!slices.Contains(ours.Conflicts, c) || !slices.Contains(theirs.Conflicts, c))
+		if !resolved {
+			merged.Conflicts = append(merged.Conflicts, c)
+		}
+	}
+
+	return merged
+}
+
+func appendConflict(conflicts []IssueConflict, conflict *IssueConflict) []IssueConflict {
+	if conflict == nil {
+		return conflicts
+	}
+	return append(conflicts, *conflict)
+}
+
+func mergeScalar(field, base, ours, theirs string) (string, *IssueConflict) {
+	switch {
+	case ours == theirs:
+		return ours, nil
+	case ours == base:
+		return theirs, nil
+	case theirs == base:
+		return ours, nil
+	default:
+		return ours, &IssueConflict{Field: field, Ours: ours, Theirs: theirs}
+	}
+}
+
+func mergeTime(base, ours, theirs time.Time) time.Time {
+	switch {
+	case ours.Equal(theirs), theirs.Equal(base):
+		return ours
+	case ours.Equal(base):
+		return theirs
+	case theirs.After(ours):
+		return theirs
+	default:
+		return ours
+	}
+}
+
+// mergeLabels keeps every label present on either side unless one side
+// removed it since the base.
+func mergeLabels(base, ours, theirs []string) []string {
+	var merged []string
+	for _, label := range slices.Concat(ours, theirs) {
+		if label == "" || slices.Contains(merged, label) {
+			continue
+		}
+		removed := slices.Contains(base, label) && (!slices.Contains(ours, label) || !slices.Contains(theirs, label))
+		if !removed {
+			merged = append(merged, label)
+		}
+	}
+	return merged
+}
+
+// commentKey identifies a comment by its author and creation time. It's what
+// replies point at, so the author comes first, followed by a space.
+func commentKey(c Comment) string {
+	return fmt.Sprintf("%s %s", c.Author, c.CreatedAt.UTC().Format(time.RFC3339Nano))
+}
+
+// mergeComments unions both sides' comments, keyed by author and creation
+// time. If both sides edited the same comment the later edit wins, except
+// that a deletion always sticks; reactions are merged separately.
+func mergeComments(base, ours, theirs []Comment) []Comment {
+	merged := slices.Clone(ours)
+	for _, comment := range theirs {
+		i := findComment(merged, commentKey(comment))
+		if i < 0 {
+			merged = append(merged, comment)
+			continue
+		}
+
+		var baseReactions map[string][]string
+		if j := findComment(base, commentKey(comment)); j >= 0 {
+			baseReactions = base[j].Reactions
+		}
+		reactions := mergeReactions(baseReactions, merged[i].Reactions, comment.Reactions)
+		if !merged[i].Deleted && (comment.Deleted || comment.UpdatedAt.After(merged[i].UpdatedAt)) {
+			merged[i] = comment
+		}
+		merged[i].Reactions = reactions
+	}
+
+	slices.SortStableFunc(merged, func(a, b Comment) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return merged
+}
+
+// resolveConflict settles the first outstanding conflict on issue by taking
+// either our or their value.
+func resolveConflict(issue Issue, takeTheirs bool) Issue {
+	if len(issue.Conflicts) == 0 {
+		return issue
+	}
+
+	conflict := issue.Conflicts[0]
+	value := conflict.Ours
+	if takeTheirs {
+		value = conflict.Theirs
+	}
+
+	switch conflict.Field {
+	case conflictFieldTitle:
+		issue.Title = value
+	case conflictFieldDescription:
+		issue.Description = value
+	case conflictFieldStatus:
+		issue.Status = issueStatus(value)
+	}
+
+	issue.Conflicts = slices.Clone(issue.Conflicts[1:])
+	return issue
+}
+
+func renderConflicts(conflicts []IssueConflict, width int) string {
+	var s strings.Builder
+	warning := lipgloss.NewStyle().Foreground(styles.Theme.RedText)
+	faint := lipgloss.NewStyle().Foreground(styles.Theme.FaintText)
+	value := lipgloss.NewStyle().Width(width)
+
+	s.WriteString(warning.Render(fmt.Sprintf("%d merge conflict(s) need resolving", len(conflicts))))
+	s.WriteString("\n")
+	for i, conflict := range conflicts {
+		s.WriteString("\n")
+		if i == 0 {
+			s.WriteString(fmt.Sprintf("%s %s\n", warning.Render("▸"), conflict.Field))
+		} else {
+			s.WriteString(fmt.Sprintf("  %s\n", conflict.Field))
+		}
+		s.WriteString(faint.Render("ours:"))
+		s.WriteString("\n")
+		s.WriteString(value.Render(conflict.Ours))
+		s.WriteString("\n")
+		s.WriteString(faint.Render("theirs:"))
+		s.WriteString("\n")
+		s.WriteString(value.Render(conflict.Theirs))
+		s.WriteString("\n")
+	}
+
+	return s.String()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeIssues(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := Issue{
+		Id:          "abc",
+		Title:       "Title",
+		Description: "Description",
+		Status:      todo,
+		Labels:      []string{"bug", "ui"},
+		Comments:    []Comment{{Author: "a@example.com", Content: "first", CreatedAt: created}},
+	}
+
+	t.Run("changes on different fields merge cleanly", func(t *testing.T) {
+		ours := base
+		ours.Title = "New title"
+		ours.Labels = []string{"bug", "ui", "urgent"}
+		theirs := base
+		theirs.Status = done
+		theirs.Labels = []string{"bug"}
+
+		merged := mergeIssues(base, ours, theirs)
+		assert.Equal(t, "New title", merged.Title)
+		assert.Equal(t, done, merged.Status)
+		assert.Equal(t, []string{"bug", "urgent"}, merged.Labels)
+		assert.Empty(t, merged.Conflicts)
+	})
+
+	t.Run("relationships merge like labels", func(t *testing.T) {
+		base := base
+		base.Relations = []Relation{{Kind: relationBlockedBy, IssueId: "one"}}
+		ours := base
+		ours.Relations = append(ours.Relations, Relation{Kind: relationParent, IssueId: "two"})
+		theirs := base
+		theirs.Relations = nil
+
+		merged := mergeIssues(base, ours, theirs)
+		assert.Equal(t, []Relation{{Kind: relationParent, IssueId: "two"}}, merged.Relations)
+	})
+
+	t.Run("same field changed on both sides conflicts", func(t *testing.T) {
+		ours := base
+		ours.Title = "Ours"
+		theirs := base
+		theirs.Title = "Theirs"
+
+		merged := mergeIssues(base, ours, theirs)
+		assert.Equal(t, "Ours", merged.Title)
+		assert.Equal(t, []IssueConflict{{Field: conflictFieldTitle, Ours: "Ours", Theirs: "Theirs"}}, merged.Conflicts)
+
+		resolved := resolveConflict(merged, true)
+		assert.Equal(t, "Theirs", resolved.Title)
+		assert.Empty(t, resolved.Conflicts)
+	})
+
+	t.Run("conflicts resolved on one side stay resolved", func(t *testing.T) {
+		conflict := IssueConflict{Field: conflictFieldTitle, Ours: "Ours", Theirs: "Theirs"}
+		base := base
+		base.Title = "Ours"
+		base.Conflicts = []IssueConflict{conflict}
+		ours := base
+		ours.Status = inProgress
+		theirs := resolveConflict(base, true)
+
+		merged := mergeIssues(base, ours, theirs)
+		assert.Equal(t, "Theirs", merged.Title)
+		assert.Equal(t, inProgress, merged.Status)
+		assert.Empty(t, merged.Conflicts)
+
+		merged = mergeIssues(base, theirs, ours)
+		assert.Equal(t, "Theirs", merged.Title)
+		assert.Empty(t, merged.Conflicts)
+
+		merged = mergeIssues(base, ours, base)
+		assert.Equal(t, []IssueConflict{conflict}, merged.Conflicts, "a conflict still open on both sides survives")
+	})
+
+	t.Run("comments are unioned", func(t *testing.T) {
+		ours := base
+		ours.Comments = append(ours.Comments, Comment{Author: "a@example.com", Content: "ours", CreatedAt: created.Add(2 * time.Hour)})
+		theirs := base
+		theirs.Comments = append(theirs.Comments, Comment{Author: "b@example.com", Content: "theirs", CreatedAt: created.Add(time.Hour)})
+
+		merged := mergeIssues(base, ours, theirs)
+		var contents []string
+		for _, c := range merged.Comments {
+			contents = append(contents, c.Content)
+		}
+		assert.Equal(t, []string{"first", "theirs", "ours"}, contents)
+	})
+
+	t.Run("deletion on one side carries who deleted it", func(t *testing.T) {
+		ours := base
+		theirs := base
+		theirs.DeletedAt = created.Add(time.Hour)
+		theirs.DeletedBy = "b@example.com"
+
+		merged := mergeIssues(base, ours, theirs)
+		assert.Equal(t, theirs.DeletedAt, merged.DeletedAt)
+		assert.Equal(t, "b@example.com", merged.DeletedBy)
+	})
+}
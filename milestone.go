@@ -0,0 +1,609 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/uuid"
+	"github.com/muesli/reflow/truncate"
+)
+
+// Milestone groups issues into a release. Each milestone is a JSON blob
+// under refs/ubik/milestones/<id>; on sync the most recently updated side
+// wins.
+type Milestone struct {
+	Id          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	DueDate     time.Time `json:"due_date"`
+	Closed      bool      `json:"closed"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// SchemaVersion is stamped on write; see schema.go
+	SchemaVersion int `json:"schema_version"`
+	// IssuesClosed and IssuesTotal are counted on load; see linkMilestones
+	IssuesClosed int `json:"-"`
+	IssuesTotal  int `json:"-"`
+}
+
+// MilestoneStore persists milestones.
+type MilestoneStore interface {
+	Milestones() ([]Milestone, error)
+	SaveMilestone(milestone Milestone) error
+}
+
+const milestoneRefPrefix = "refs/ubik/milestones/"
+
+func milestoneRefName(id string) plumbing.ReferenceName {
+	return plumbing.ReferenceName(milestoneRefPrefix + id)
+}
+
+func readMilestone(repo *git.Repository, hash plumbing.Hash) (Milestone, error) {
+	var milestone Milestone
+	blob, err := repo.BlobObject(hash)
+	if err != nil {
+		return milestone, err
+	}
+	data, err := readBlob(blob)
+	if err != nil {
+		return milestone, err
+	}
+	return decodeMilestone(data)
+}
+
+func (s *gitStore) Milestones() ([]Milestone, error) {
+	refs, err := s.refsWithPrefix(milestoneRefPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var milestones []Milestone
+	for _, ref := range refs {
+		milestone, err := readMilestone(s.repo, ref.Hash())
+		if err != nil {
+			debug("%#v", err.Error())
+			continue
+		}
+		milestones = append(milestones, milestone)
+	}
+
+	return sortMilestones(milestones), nil
+}
+
+func (s *gitStore) SaveMilestone(milestone Milestone) error {
+	data, err := encodeMilestone(milestone)
+	if err != nil {
+		return err
+	}
+
+	hash, err := storeBlob(s.repo, data)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Storer.SetReference(plumbing.NewHashReference(milestoneRefName(milestone.Id), hash))
+}
+
+func (s *memoryStore) Milestones() ([]Milestone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortMilestones(slices.Collect(maps.Values(s.milestones))), nil
+}
+
+func (s *memoryStore) SaveMilestone(milestone Milestone) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.milestones[milestone.Id] = milestone
+	return nil
+}
+
+// sortMilestones puts open milestones first, soonest due first, with
+// undated ones after dated ones.
+func sortMilestones(milestones []Milestone) []Milestone {
+	slices.SortFunc(milestones, func(a, b Milestone) int {
+		switch {
+		case a.Closed != b.Closed:
+			if a.Closed {
+				return 1
+			}
+			return -1
+		case a.DueDate.IsZero() != b.DueDate.IsZero():
+			if a.DueDate.IsZero() {
+				return 1
+			}
+			return -1
+		case !a.DueDate.Equal(b.DueDate):
+			return a.DueDate.Compare(b.DueDate)
+		default:
+			return strings.Compare(a.Title, b.Title)
+		}
+	})
+	return milestones
+}
+
+// syncMilestoneRef keeps whichever side of a milestone was updated last.
+func syncMilestoneRef(repo *git.Repository, ours, theirs *plumbing.Reference) (syncResult, error) {
+	ourMilestone, err := readMilestone(repo, ours.Hash())
+	if err != nil {
+		return syncUnchanged, err
+	}
+	theirMilestone, err := readMilestone(repo, theirs.Hash())
+	if err != nil {
+		return syncUnchanged, err
+	}
+
+	if !theirMilestone.UpdatedAt.After(ourMilestone.UpdatedAt) {
+		return syncUnchanged, nil
+	}
+
+	err = repo.Storer.CheckAndSetReference(plumbing.NewHashReference(ours.Name(), theirs.Hash()), ours)
+	return syncFastForwarded, err
+}
+
+func (m Milestone) isOverdue() bool {
+	return !m.Closed && isPastDue(m.DueDate)
+}
+
+func (m Milestone) percentDone() int {
+	if m.IssuesTotal == 0 {
+		return 0
+	}
+	return m.IssuesClosed * 100 / m.IssuesTotal
+}
+
+// linkMilestones fills in Issue.MilestoneTitle and counts each milestone's
+// issues, and how many of them w counts as closed.
+func linkMilestones(issues []Issue, milestones []Milestone, w Workflow) ([]Issue, []Milestone) {
+	issues = slices.Clone(issues)
+	milestones = slices.Clone(milestones)
+
+	positions := make(map[string]int, len(milestones))
+	for i := range milestones {
+		milestones[i].IssuesClosed = 0
+		milestones[i].IssuesTotal = 0
+		positions[milestones[i].Id] = i
+	}
+
+	for i := range issues {
+		issues[i].MilestoneTitle = ""
+		position, ok := positions[issues[i].MilestoneId]
+		if !ok {
+			continue
+		}
+		issues[i].MilestoneTitle = milestones[position].Title
+		milestones[position].IssuesTotal++
+		if w.isClosed(issues[i].Status) {
+			milestones[position].IssuesClosed++
+		}
+	}
+
+	return issues, milestones
+}
+
+// MilestoneFilter narrows the issue list to issues in a milestone whose
+// title contains the term, e.g. "milestone:v1.2".
+func MilestoneFilter(term string, targets []string) []list.Rank {
+	milestone := strings.ToLower(strings.TrimPrefix(term, "milestone:"))
+
+	var ranks []list.Rank
+	for i, t := range targets {
+		milestonePart := strings.ToLower(strings.Split(t, "\n")[5])
+		if milestonePart != "" && strings.Contains(milestonePart, milestone) {
+			ranks = append(ranks, list.Rank{Index: i})
+		}
+	}
+
+	return ranks
+}
+
+func renderProgress(milestone Milestone, width int) string {
+	filled := 0
+	if milestone.IssuesTotal > 0 {
+		filled = width * milestone.IssuesClosed / milestone.IssuesTotal
+	}
+	bar := lipgloss.NewStyle().Foreground(styles.Theme.GreenText).Render(strings.Repeat("█", filled)) +
+		lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render(strings.Repeat("░", width-filled))
+	return fmt.Sprintf("%s %d%% (%d/%d closed)", bar, milestone.percentDone(), milestone.IssuesClosed, milestone.IssuesTotal)
+}
+
+func renderDueDate(milestone Milestone) string {
+	if milestone.DueDate.IsZero() {
+		return "no due date"
+	}
+	due := fmt.Sprintf("due %s", formatDueDate(milestone.DueDate))
+	if milestone.isOverdue() {
+		return lipgloss.NewStyle().Foreground(styles.Theme.RedText).Render(due + " (overdue)")
+	}
+	return due
+}
+
+func (m Milestone) FilterValue() string {
+	return m.Title
+}
+
+func (m Milestone) Height() int                             { return 2 }
+func (m Milestone) Spacing() int                            { return 1 }
+func (m Milestone) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+
+func (m Milestone) Render(w io.Writer, l list.Model, index int, listItem list.Item) {
+	m, ok := listItem.(Milestone)
+
+	if !ok {
+		return
+	}
+
+	defaultItemStyles := list.NewDefaultItemStyles()
+
+	titleFn := defaultItemStyles.NormalTitle.Padding(0).Render
+	if index == l.Index() {
+		titleFn = func(s ...string) string {
+			return defaultItemStyles.SelectedTitle.
+				Border(lipgloss.NormalBorder(), false, false, false, false).
+				Padding(0).
+				Render(strings.Join(s, " "))
+		}
+	}
+	title := titleFn(truncate.StringWithTail(m.Title, 40, "..."))
+	if m.Closed {
+		title = fmt.Sprintf("%s %s", title, lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render("(closed)"))
+	}
+
+	description := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render(renderProgress(m, 10) + " · " + renderDueDate(m))
+	item := lipgloss.JoinVertical(lipgloss.Left, title, description)
+
+	fmt.Fprint(w, item)
+}
+
+type MilestonesReadyMsg []Milestone
+
+func getMilestones(store MilestoneStore) tea.Cmd {
+	return func() tea.Msg {
+		milestones, err := store.Milestones()
+		if err != nil {
+			debug("%#v", err.Error())
+			return loadFailedMsg{What: "milestones", Err: err}
+		}
+		return MilestonesReadyMsg(milestones)
+	}
+}
+
+type milestonePersistedMsg struct {
+	Milestone Milestone
+}
+
+func persistMilestone(milestone Milestone, store MilestoneStore) tea.Cmd {
+	return func() tea.Msg {
+		if milestone.Id == "" {
+			milestone.Id = uuid.NewString()
+			milestone.CreatedAt = time.Now().UTC()
+		}
+		milestone.UpdatedAt = time.Now().UTC()
+
+		err := store.SaveMilestone(milestone)
+		if err != nil {
+			debug("%#v", err.Error())
+			return err
+		}
+
+		return milestonePersistedMsg{Milestone: milestone}
+	}
+}
+
+func (m Model) loadedMilestones() []Milestone {
+	return convertSlice(m.milestoneIndex.Items(), func(item list.Item) Milestone {
+		return item.(Milestone)
+	})
+}
+
+func (m *Model) setMilestones(milestones []Milestone) {
+	m.milestoneIndex.SetItems(convertSlice(sortMilestones(milestones), func(milestone Milestone) list.Item {
+		return list.Item(milestone)
+	}))
+}
+
+var errInvalidDueDate = errors.New("due dates look like 2006-01-02")
+
+type milestoneForm struct {
+	id               string
+	titleInput       textinput.Model
+	dueInput         textinput.Model
+	descriptionInput textinput.Model
+	focused          int
+	closed           bool
+	createdAt        time.Time
+}
+
+func newMilestoneForm(milestone Milestone) milestoneForm {
+	form := milestoneForm{
+		id:               milestone.Id,
+		titleInput:       textinput.New(),
+		dueInput:         textinput.New(),
+		descriptionInput: textinput.New(),
+		closed:           milestone.Closed,
+		createdAt:        milestone.CreatedAt,
+	}
+	form.titleInput.Prompt = "title: "
+	form.titleInput.CharLimit = 80
+	form.titleInput.SetValue(milestone.Title)
+	form.dueInput.Prompt = "due: "
+	form.dueInput.Placeholder = "YYYY-MM-DD"
+	form.dueInput.CharLimit = 10
+	form.dueInput.SetValue(formatDueDate(milestone.DueDate))
+	form.descriptionInput.Prompt = "description: "
+	form.descriptionInput.SetValue(milestone.Description)
+	for _, input := range form.inputs() {
+		input.Width = 50
+	}
+	form.titleInput.Focus()
+	return form
+}
+
+func (f *milestoneForm) inputs() []*textinput.Model {
+	return []*textinput.Model{&f.titleInput, &f.dueInput, &f.descriptionInput}
+}
+
+func (f milestoneForm) milestone() (Milestone, error) {
+	milestone := Milestone{
+		Id:          f.id,
+		Title:       strings.TrimSpace(f.titleInput.Value()),
+		Description: strings.TrimSpace(f.descriptionInput.Value()),
+		Closed:      f.closed,
+		CreatedAt:   f.createdAt,
+	}
+	if milestone.Title == "" {
+		return milestone, errors.New("milestones need a title")
+	}
+	due, err := parseDueDate(f.dueInput.Value())
+	milestone.DueDate = due
+	return milestone, err
+}
+
+func milestonesIndexHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	if m.milestoneIndex.SettingFilter() {
+		m.milestoneIndex, cmd = m.milestoneIndex.Update(msg)
+		return m, cmd
+	}
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+			return m, nil
+		case key.Matches(msg, keys.MilestoneNew):
+			m.milestoneForm = newMilestoneForm(Milestone{})
+			m.underlayPath = m.path
+			m.path = milestonesFormPath
+			return m, textinput.Blink
+		case key.Matches(msg, keys.MilestoneShowFocus):
+			if m.milestoneIndex.SelectedItem() == nil {
+				return m, nil
+			}
+			m.path = milestonesShowPath
+			m.UpdateLayout(m.layout.TerminalSize)
+			m.milestoneShow = viewport.New(m.layout.RightSize.Width, m.layout.RightSize.Height)
+			m.refreshMilestoneShow()
+			return m, nil
+		case key.Matches(msg, keys.NextPage):
+			m.path = actionsIndexPath
+			return m, nil
+		case key.Matches(msg, keys.PrevPage):
+			m.path = issuesIndexPath
+			return m, nil
+		}
+	}
+
+	m.milestoneIndex, cmd = m.milestoneIndex.Update(msg)
+	return m, cmd
+}
+
+func milestonesShowHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+			return m, nil
+		case key.Matches(msg, keys.Back):
+			m.path = milestonesIndexPath
+			m.UpdateLayout(m.layout.TerminalSize)
+			return m, nil
+		case key.Matches(msg, keys.MilestoneEdit):
+			m.milestoneForm = newMilestoneForm(m.milestoneIndex.SelectedItem().(Milestone))
+			m.underlayPath = m.path
+			m.path = milestonesFormPath
+			return m, textinput.Blink
+		case key.Matches(msg, keys.MilestoneToggleClosed):
+			milestone := m.milestoneIndex.SelectedItem().(Milestone)
+			milestone.Closed = !milestone.Closed
+			return m, persistMilestone(milestone, m.store)
+		}
+	}
+
+	m.milestoneShow, cmd = m.milestoneShow.Update(msg)
+	return m, cmd
+}
+
+func milestonesFormHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+	inputs := m.milestoneForm.inputs()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Back):
+			m.path = m.underlayPath
+			m.underlayPath = 0
+			return m, nil
+		case key.Matches(msg, keys.NextInput):
+			inputs[m.milestoneForm.focused].Blur()
+			m.milestoneForm.focused = (m.milestoneForm.focused + 1) % len(inputs)
+			return m, inputs[m.milestoneForm.focused].Focus()
+		case key.Matches(msg, keys.Submit):
+			milestone, err := m.milestoneForm.milestone()
+			if err != nil {
+				m.flash = err.Error()
+				m.UpdateLayout(m.layout.TerminalSize)
+				return m, nil
+			}
+			m.path = m.underlayPath
+			m.underlayPath = 0
+			return m, persistMilestone(milestone, m.store)
+		}
+	}
+
+	*inputs[m.milestoneForm.focused], cmd = inputs[m.milestoneForm.focused].Update(msg)
+	return m, cmd
+}
+
+func (m Model) milestoneShowContent(milestone Milestone) string {
+	var s strings.Builder
+
+	status := "open"
+	if milestone.Closed {
+		status = "closed"
+	}
+	s.WriteString(fmt.Sprintf("%s\nStatus: %s · %s\n\n", milestone.Title, status, renderDueDate(milestone)))
+	s.WriteString(renderProgress(milestone, 30) + "\n\n")
+	if milestone.Description != "" {
+		s.WriteString(milestone.Description + "\n\n")
+	}
+
+	for _, issue := range m.loadedIssues() {
+		if issue.MilestoneId != milestone.Id {
+			continue
+		}
+		identifier := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render("#" + issue.Shortcode)
+		s.WriteString(fmt.Sprintf("%s %s %s\n", m.workflow.icon(issue.Status), identifier, issue.Title))
+	}
+
+	return s.String()
+}
+
+// refreshMilestoneShow redraws the selected milestone, keeping the scroll
+// position.
+func (m *Model) refreshMilestoneShow() {
+	milestone, ok := m.milestoneIndex.SelectedItem().(Milestone)
+	if !ok {
+		return
+	}
+	m.milestoneShow.SetContent(m.milestoneShowContent(milestone))
+}
+
+func (m Model) renderMilestonesView() string {
+	left := m.milestoneIndex.View()
+	if len(m.milestoneIndex.Items()) == 0 {
+		left = lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render("No milestones yet. Press n to add one.")
+	}
+
+	var right string
+	if m.path == milestonesShowPath || m.underlayPath == milestonesShowPath {
+		right = m.milestoneShow.View()
+	}
+
+	return m.renderMainLayout(m.renderTabs("Milestones"), left, right, m.footerView())
+}
+
+func (m Model) milestoneFormView() string {
+	form := m.milestoneForm
+	heading := "New milestone"
+	if form.id != "" {
+		heading = "Edit milestone"
+	}
+	return fmt.Sprintf("%s\n\n%s\n%s\n%s", heading, form.titleInput.View(), form.dueInput.View(), form.descriptionInput.View())
+}
+
+// milestonePicker chooses an issue's milestone in the issue form: none, or
+// one of the open milestones, plus the issue's current one even if it has
+// since been closed.
+type milestonePicker struct {
+	options  []Milestone
+	selected int
+}
+
+func newMilestonePicker(milestones []Milestone, currentId string) milestonePicker {
+	picker := milestonePicker{options: []Milestone{{}}}
+	for _, milestone := range milestones {
+		if !milestone.Closed || milestone.Id == currentId {
+			picker.options = append(picker.options, milestone)
+		}
+		if milestone.Id == currentId && currentId != "" {
+			picker.selected = len(picker.options) - 1
+		}
+	}
+	return picker
+}
+
+func (p milestonePicker) move(delta int) milestonePicker {
+	p.selected = (p.selected + delta + len(p.options)) % len(p.options)
+	return p
+}
+
+func (p milestonePicker) value() Milestone {
+	return p.options[p.selected]
+}
+
+func (p milestonePicker) View(focused bool) string {
+	title := p.value().Title
+	if title == "" {
+		title = "none"
+	}
+	if !focused {
+		return title
+	}
+	return fmt.Sprintf("‹ %s ›", title)
+}
+
+func issuesMilestoneHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Back):
+			if m.issueForm.editing {
+				m.path = issuesShowPath
+			} else {
+				m.path = issuesIndexPath
+			}
+			return m, cmd
+		case key.Matches(msg, keys.PickerPrev):
+			m.issueForm.milestonePicker = m.issueForm.milestonePicker.move(-1)
+			return m, cmd
+		case key.Matches(msg, keys.PickerNext):
+			m.issueForm.milestonePicker = m.issueForm.milestonePicker.move(1)
+			return m, cmd
+		case key.Matches(msg, keys.NextInput):
+			if m.issueForm.editing {
+				m.path = issuesEditPriorityPath
+			} else {
+				m.path = issuesNewPriorityPath
+			}
+			return m, cmd
+		}
+	}
+
+	return m, cmd
+}
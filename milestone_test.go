@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitStoreMilestones(t *testing.T) {
+	repo, err := git.PlainInit(t.TempDir(), false)
+	require.NoError(t, err)
+	store := newGitStore(repo, testGitConfig("alice@example.com"))
+
+	later := Milestone{Id: "later", Title: "v2", DueDate: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), SchemaVersion: milestoneSchemaVersion}
+	sooner := Milestone{Id: "sooner", Title: "v1", DueDate: time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC), SchemaVersion: milestoneSchemaVersion}
+	done := Milestone{Id: "done", Title: "v0", Closed: true, SchemaVersion: milestoneSchemaVersion}
+	for _, milestone := range []Milestone{later, sooner, done} {
+		require.NoError(t, store.SaveMilestone(milestone))
+	}
+
+	milestones, err := store.Milestones()
+	require.NoError(t, err)
+	assert.Equal(t, []Milestone{sooner, later, done}, milestones, "open milestones come first, soonest due first")
+}
+
+func TestLinkMilestones(t *testing.T) {
+	issues := []Issue{
+		{Id: "one", MilestoneId: "v1", Status: done},
+		{Id: "two", MilestoneId: "v1", Status: inProgress},
+		{Id: "three", MilestoneId: "v1", Status: wontDo},
+		{Id: "four", MilestoneId: "gone", Status: todo},
+		{Id: "five", Status: todo},
+	}
+	milestones := []Milestone{{Id: "v1", Title: "Version 1"}}
+
+	issues, milestones = linkMilestones(issues, milestones, defaultWorkflow())
+
+	assert.Equal(t, "Version 1", issues[0].MilestoneTitle)
+	assert.Empty(t, issues[3].MilestoneTitle, "unknown milestones are ignored")
+	assert.Equal(t, 2, milestones[0].IssuesClosed)
+	assert.Equal(t, 3, milestones[0].IssuesTotal)
+	assert.Equal(t, 66, milestones[0].percentDone())
+
+	targets := []string{issues[0].FilterValue(), issues[4].FilterValue()}
+	assert.Len(t, MilestoneFilter("milestone:version", targets), 1)
+	assert.Len(t, CustomFilter("milestone:version", targets, defaultWorkflow(), FieldSchema{}), 1)
+}
+
+func TestIsOverdue(t *testing.T) {
+	today := time.Now().Truncate(24 * time.Hour)
+
+	assert.False(t, Milestone{}.isOverdue(), "no due date")
+	assert.False(t, Milestone{DueDate: today.AddDate(0, 0, 1)}.isOverdue())
+	assert.True(t, Milestone{DueDate: today.AddDate(0, 0, -2)}.isOverdue())
+	assert.False(t, Milestone{DueDate: today.AddDate(0, 0, -2), Closed: true}.isOverdue())
+}
+
+func TestSyncMilestones(t *testing.T) {
+	remoteDir := t.TempDir()
+	_, err := git.PlainInit(remoteDir, true)
+	require.NoError(t, err)
+
+	alice := newSyncClone(t, remoteDir)
+	bob := newSyncClone(t, remoteDir)
+	aliceStore := newGitStore(alice, testGitConfig("alice@example.com"))
+	bobStore := newGitStore(bob, testGitConfig("bob@example.com"))
+	aliceSig := object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()}
+	bobSig := object.Signature{Name: "Bob", Email: "bob@example.com", When: time.Now()}
+
+	milestone := Milestone{Id: "v1", Title: "Version 1", UpdatedAt: time.Now()}
+	require.NoError(t, aliceStore.SaveMilestone(milestone))
+	_, err = syncRepo(alice, "origin", aliceSig, nil)
+	require.NoError(t, err)
+	report, err := syncRepo(bob, "origin", bobSig, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+
+	// Alice renames it first, Bob closes it later; Bob's edit wins.
+	renamed := milestone
+	renamed.Title = "Version one"
+	renamed.UpdatedAt = time.Now()
+	require.NoError(t, aliceStore.SaveMilestone(renamed))
+	closed := milestone
+	closed.Closed = true
+	closed.UpdatedAt = time.Now().Add(time.Minute)
+	require.NoError(t, bobStore.SaveMilestone(closed))
+
+	_, err = syncRepo(alice, "origin", aliceSig, nil)
+	require.NoError(t, err)
+	report, err = syncRepo(bob, "origin", bobSig, nil)
+	require.NoError(t, err)
+	assert.Zero(t, report.FastForwarded, "bob's edit is newer")
+	_, err = syncRepo(alice, "origin", aliceSig, nil)
+	require.NoError(t, err)
+
+	milestones, err := aliceStore.Milestones()
+	require.NoError(t, err)
+	require.Len(t, milestones, 1)
+	assert.True(t, milestones[0].Closed)
+	assert.Equal(t, "Version 1", milestones[0].Title)
+}
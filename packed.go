@@ -0,0 +1,634 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// In the packed layout every issue lives as <id>.json in a single tree, and
+// refs/ubik/index points at a chain of commits of that tree. Reading all
+// issues is one tree walk instead of one ref lookup per issue, and several
+// issues can be updated in one atomic commit.
+const packedIndexRef = plumbing.ReferenceName("refs/ubik/index")
+
+const (
+	layoutRefs   = "refs"
+	layoutPacked = "packed"
+)
+
+// storageLayout returns the layout selected with `git config ubik.layout`.
+func storageLayout(repo *git.Repository) string {
+	cfg, err := repo.Config()
+	if err != nil {
+		return layoutRefs
+	}
+	if cfg.Raw.Section("ubik").Option("layout") == layoutPacked {
+		return layoutPacked
+	}
+	return layoutRefs
+}
+
+func openStore(repo *git.Repository, cfg *config.Config) Store {
+	store := newGitStore(repo, cfg)
+	if storageLayout(repo) == layoutPacked {
+		return withSigning(&packedStore{gitStore: store}, cfg)
+	}
+	return withSigning(store, cfg)
+}
+
+// packedStore keeps issues in the packed index. Actions are still stored one
+// ref per run.
+type packedStore struct {
+	*gitStore
+}
+
+func packedFilename(id string) string {
+	return id + ".json"
+}
+
+func (s *packedStore) tip() (*plumbing.Reference, *object.Commit, error) {
+	ref, err := s.repo.Storer.Reference(packedIndexRef)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commit, err := s.repo.CommitObject(ref.Hash())
+	return ref, commit, err
+}
+
+func (s *packedStore) Issues() ([]Issue, error) {
+	_, commit, err := s.tip()
+	if err != nil || commit == nil {
+		return nil, err
+	}
+
+	return readPackedIssues(s.repo, commit)
+}
+
+func readPackedIssues(repo *git.Repository, commit *object.Commit) ([]Issue, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	for _, entry := range tree.Entries {
+		if !strings.HasSuffix(entry.Name, ".json") {
+			continue
+		}
+		issue, err := readIssueObject(repo, entry.Hash)
+		if err != nil {
+			debug("%#v", err.Error())
+			continue
+		}
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
+
+func (s *packedStore) SaveIssue(issue Issue) error {
+	return s.SaveIssues(issue)
+}
+
+// SaveIssues writes all of issues in a single commit.
+func (s *packedStore) SaveIssues(issues ...Issue) error {
+	return s.commitIssues(issues, gitSignature(s.cfg), fmt.Sprintf("Update %d issue(s)", len(issues)))
+}
+
+// commitIssues writes issues into the index in a commit with the given
+// subject. Any extra parents, such as the per-ref histories of issues being
+// packed, are kept reachable from the index.
+func (s *packedStore) commitIssues(issues []Issue, author object.Signature, subject string, extraParents ...plumbing.Hash) error {
+	ref, commit, err := s.tip()
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]plumbing.Hash)
+	var parents []plumbing.Hash
+	if commit != nil {
+		tree, err := commit.Tree()
+		if err != nil {
+			return err
+		}
+		for _, entry := range tree.Entries {
+			entries[entry.Name] = entry.Hash
+		}
+		parents = append(parents, commit.Hash)
+	}
+
+	var changes []string
+	for _, issue := range issues {
+		name := packedFilename(issue.Id)
+		if previousHash, ok := entries[name]; ok {
+			previous, err := readIssueObject(s.repo, previousHash)
+			if err == nil {
+				for _, change := range diffIssues(previous, issue) {
+					changes = append(changes, fmt.Sprintf("%s: %s", issue.Id, change))
+				}
+			}
+		} else {
+			changes = append(changes, fmt.Sprintf("%s: created", issue.Id))
+		}
+
+		jsonData, err := encodeIssue(issue)
+		if err != nil {
+			return err
+		}
+		hash, err := storeBlob(s.repo, jsonData)
+		if err != nil {
+			return err
+		}
+		entries[name] = hash
+	}
+
+	if added := attachmentHashes(issues...); len(added) > 0 {
+		var existing []plumbing.Hash
+		if commit != nil {
+			tree, err := commit.Tree()
+			if err != nil {
+				return err
+			}
+			existing = treeAttachmentHashes(tree)
+		}
+		entries[attachmentsDir], err = writeAttachmentTree(s.repo, append(existing, added...))
+		if err != nil {
+			return err
+		}
+	}
+
+	message := fmt.Sprintf("%s\n\n%s\n", subject, strings.Join(changes, "\n"))
+	hash, err := writePackedCommit(s.repo, entries, author, message, append(parents, extraParents...)...)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Storer.CheckAndSetReference(plumbing.NewHashReference(packedIndexRef, hash), ref)
+}
+
+func (s *packedStore) PurgeIssue(id string) error {
+	err := s.removeIssues(gitSignature(s.cfg), id)
+	if err != nil {
+		return err
+	}
+	return writePurgeTombstone(s.repo, id, gitSignature(s.cfg))
+}
+
+// removeIssues takes issues out of the index, along with the attachments
+// only they used.
+func (s *packedStore) removeIssues(author object.Signature, ids ...string) error {
+	ref, commit, err := s.tip()
+	if err != nil {
+		return err
+	}
+	if commit == nil {
+		return errIssueNotFound
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	names := convertSlice(ids, packedFilename)
+	entries := make(map[string]plumbing.Hash)
+	for _, entry := range tree.Entries {
+		if !slices.Contains(names, entry.Name) && entry.Name != attachmentsDir {
+			entries[entry.Name] = entry.Hash
+		}
+	}
+
+	issues, err := readPackedIssues(s.repo, commit)
+	if err != nil {
+		return err
+	}
+	issues = slices.DeleteFunc(issues, func(issue Issue) bool { return slices.Contains(ids, issue.Id) })
+	if hashes := attachmentHashes(issues...); len(hashes) > 0 {
+		entries[attachmentsDir], err = writeAttachmentTree(s.repo, hashes)
+		if err != nil {
+			return err
+		}
+	}
+
+	purged := convertSlice(ids, func(id string) string { return id + ": purged" })
+	message := fmt.Sprintf("Purge %d issue(s)\n\n%s\n", len(ids), strings.Join(purged, "\n"))
+	hash, err := writePackedCommit(s.repo, entries, author, message, commit.Hash)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Storer.CheckAndSetReference(plumbing.NewHashReference(packedIndexRef, hash), ref)
+}
+
+func writePackedCommit(repo *git.Repository, entries map[string]plumbing.Hash, author object.Signature, message string, parents ...plumbing.Hash) (plumbing.Hash, error) {
+	tree := &object.Tree{}
+	for name, hash := range entries {
+		mode := filemode.Regular
+		if name == attachmentsDir {
+			mode = filemode.Dir
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: mode, Hash: hash})
+	}
+	// git orders trees as if directory names ended in a slash
+	sortName := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	slices.SortFunc(tree.Entries, func(a, b object.TreeEntry) int {
+		return strings.Compare(sortName(a), sortName(b))
+	})
+
+	treeHash, err := storeObject(repo, tree)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit := &object.Commit{
+		Author:       author,
+		Committer:    author,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+
+	return storeObject(repo, commit)
+}
+
+func packedEntryHash(commit *object.Commit, name string) plumbing.Hash {
+	tree, err := commit.Tree()
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	entry, err := tree.FindEntry(name)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return entry.Hash
+}
+
+// IssueHistory walks the index and picks out the commits that touched the
+// issue's file, along with the per-ref history the issue had before it was
+// packed or merged in from another clone.
+func (s *packedStore) IssueHistory(id string) ([]IssueRevision, error) {
+	_, commit, err := s.tip()
+	if err != nil {
+		return nil, err
+	}
+	if commit == nil {
+		return nil, errIssueNotFound
+	}
+
+	name := packedFilename(id)
+	var revisions []IssueRevision
+	seen := make(map[string]bool)
+	iter := object.NewCommitPreorderIter(commit, nil, nil)
+	err = iter.ForEach(func(c *object.Commit) error {
+		hash := packedEntryHash(c, name)
+		if hash.IsZero() {
+			return nil
+		}
+
+		var previousHash plumbing.Hash
+		if c.NumParents() > 0 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				return err
+			}
+			previousHash = packedEntryHash(parent, name)
+		}
+		if hash == previousHash {
+			return nil
+		}
+
+		issue, err := readIssueObject(s.repo, hash)
+		if err != nil {
+			return err
+		}
+
+		changes := []string{"created"}
+		var previous *Issue
+		if !previousHash.IsZero() {
+			parent, err := readIssueObject(s.repo, previousHash)
+			if err != nil {
+				return err
+			}
+			changes = diffIssues(parent, issue)
+			previous = &parent
+		}
+		chain, chained := packedChain(s.repo, c, id)
+		if chained {
+			earlier, err := issueChainHistory(s.repo, chain)
+			if err != nil {
+				return err
+			}
+			if previousHash.IsZero() {
+				changes = diffIssues(earlier[0].Issue, issue)
+				previous = &earlier[0].Issue
+			}
+			// later chains of the same issue include the earlier ones
+			for _, revision := range earlier {
+				if !seen[revision.Hash] {
+					seen[revision.Hash] = true
+					revisions = append(revisions, revision)
+				}
+			}
+		}
+		// packing an issue as it was doesn't change it
+		if chained && len(changes) == 0 {
+			return nil
+		}
+
+		revisions = append(revisions, IssueRevision{
+			Hash:      c.Hash.String(),
+			Author:    c.Author.Email,
+			Timestamp: c.Author.When,
+			Message:   strings.TrimSuffix(c.Message, "\n"),
+			Changes:   changes,
+			Issue:     issue,
+			Previous:  previous,
+			Merge:     c.NumParents() > 1,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, errIssueNotFound
+	}
+
+	slices.SortStableFunc(revisions, func(a, b IssueRevision) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+
+	return revisions, nil
+}
+
+// packedChain finds the per-ref history of the issue with id among c's
+// parents, which is where packing or merging in another clone's refs leaves
+// it.
+func packedChain(repo *git.Repository, c *object.Commit, id string) (plumbing.Hash, bool) {
+	for _, parent := range c.ParentHashes {
+		parentCommit, err := repo.CommitObject(parent)
+		if err != nil {
+			continue
+		}
+		if _, err := parentCommit.File(issueRecordFilename); err != nil {
+			continue
+		}
+		issue, err := readIssueObject(repo, parent)
+		if err == nil && issue.Id == id {
+			return parent, true
+		}
+	}
+	return plumbing.ZeroHash, false
+}
+
+// packIssues moves every per-ref issue into the packed index in one commit,
+// removes the per-ref issue refs and switches the repository's layout. The
+// issues' histories become parents of that commit, so they stay reachable.
+func packIssues(repo *git.Repository, cfg *config.Config) (int, error) {
+	refStore := newGitStore(repo, cfg)
+	issues, err := refStore.Issues()
+	if err != nil {
+		return 0, err
+	}
+	refs, err := refStore.refsWithPrefix("refs/ubik/issues/")
+	if err != nil {
+		return 0, err
+	}
+
+	var chains []plumbing.Hash
+	for _, ref := range refs {
+		if _, err := repo.CommitObject(ref.Hash()); err == nil {
+			chains = append(chains, ref.Hash())
+		}
+	}
+	packed := &packedStore{gitStore: refStore}
+	if len(issues) > 0 {
+		err = packed.commitIssues(issues, gitSignature(cfg), fmt.Sprintf("Pack %d issue(s)", len(issues)), chains...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for _, ref := range refs {
+		err = repo.Storer.RemoveReference(ref.Name())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	repoConfig, err := repo.Config()
+	if err != nil {
+		return 0, err
+	}
+	repoConfig.Raw.Section("ubik").SetOption("layout", layoutPacked)
+	err = repo.SetConfig(repoConfig)
+
+	return len(issues), err
+}
+
+// mergePackedIndex three-way merges two diverged index commits file by file.
+// Issues purged on one side stay purged.
+func mergePackedIndex(repo *git.Repository, ours, theirs *object.Commit, author object.Signature, resign *resigner) (plumbing.Hash, error) {
+	purged, err := purgedIssueIds(repo)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	var baseIssues []Issue
+	bases, err := ours.MergeBase(theirs)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if len(bases) > 0 {
+		baseIssues, err = readPackedIssues(repo, bases[0])
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+	ourIssues, err := readPackedIssues(repo, ours)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	theirIssues, err := readPackedIssues(repo, theirs)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	byId := func(issues []Issue) map[string]Issue {
+		m := make(map[string]Issue)
+		for _, issue := range issues {
+			m[issue.Id] = issue
+		}
+		return m
+	}
+	base, mine, other := byId(baseIssues), byId(ourIssues), byId(theirIssues)
+
+	tree, err := ours.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	entries := make(map[string]plumbing.Hash)
+	for _, entry := range tree.Entries {
+		entries[entry.Name] = entry.Hash
+	}
+
+	gone := func(id string) bool {
+		_, known := base[id]
+		return known || slices.Contains(purged, id)
+	}
+	for id := range mine {
+		if _, ok := other[id]; !ok && gone(id) {
+			delete(entries, packedFilename(id))
+		}
+	}
+
+	var conflicted []string
+	for id, theirIssue := range other {
+		name := packedFilename(id)
+		ourIssue, ok := mine[id]
+		if !ok {
+			if !gone(id) {
+				entries[name] = packedEntryHash(theirs, name)
+			}
+			continue
+		}
+		if packedEntryHash(ours, name) == packedEntryHash(theirs, name) {
+			continue
+		}
+
+		merged, err := resign.issue(mergeIssues(base[id], ourIssue, theirIssue), ourIssue, theirIssue)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if len(merged.Conflicts) > 0 {
+			conflicted = append(conflicted, id)
+		}
+		jsonData, err := encodeIssue(merged)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries[name], err = storeBlob(repo, jsonData)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	theirTree, err := theirs.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if hashes := slices.Concat(treeAttachmentHashes(tree), treeAttachmentHashes(theirTree)); len(hashes) > 0 {
+		entries[attachmentsDir], err = writeAttachmentTree(repo, hashes)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	message := "Merge remote changes"
+	if len(conflicted) > 0 {
+		slices.Sort(conflicted)
+		message = fmt.Sprintf("%s\n\nConflicts: %s\n", message, strings.Join(conflicted, ", "))
+	}
+
+	return writePackedCommit(repo, entries, author, message, ours.Hash, theirs.Hash)
+}
+
+// foldIssueRefs merges issues that another clone still keeps one ref per
+// issue into the index, instead of recreating the refs in a packed repo.
+// Their histories become parents of the merge, so refs the index already
+// has are skipped on the next sync. It returns how many issues were new and
+// how many were merged.
+func foldIssueRefs(repo *git.Repository, theirs []*plumbing.Reference, author object.Signature, resign *resigner) (int, int, error) {
+	store := &packedStore{gitStore: newGitStore(repo, nil)}
+	_, tip, err := store.tip()
+	if err != nil {
+		return 0, 0, err
+	}
+	current := make(map[string]Issue)
+	if tip != nil {
+		issues, err := readPackedIssues(repo, tip)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, issue := range issues {
+			current[issue.Id] = issue
+		}
+	}
+
+	var folded []Issue
+	var chains []plumbing.Hash
+	var created, merged int
+	for _, ref := range theirs {
+		theirIssue, err := readIssueObject(repo, ref.Hash())
+		if err != nil {
+			return 0, 0, err
+		}
+		ourIssue, ok := current[theirIssue.Id]
+
+		theirCommit, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			// a legacy blob ref has no history to merge
+			if !ok {
+				folded = append(folded, theirIssue)
+				created++
+			}
+			continue
+		}
+		if tip != nil {
+			known, err := theirCommit.IsAncestor(tip)
+			if err != nil {
+				return 0, 0, err
+			}
+			if known {
+				continue
+			}
+		}
+
+		chains = append(chains, ref.Hash())
+		if !ok {
+			folded = append(folded, theirIssue)
+			created++
+			continue
+		}
+		var base Issue
+		if tip != nil {
+			bases, err := theirCommit.MergeBase(tip)
+			if err != nil {
+				return 0, 0, err
+			}
+			// only an earlier part of the same chain can be a base
+			if len(bases) > 0 {
+				if earlier, err := readIssueObject(repo, bases[0].Hash); err == nil && earlier.Id == theirIssue.Id {
+					base = earlier
+				}
+			}
+		}
+		issue, err := resign.issue(mergeIssues(base, ourIssue, theirIssue), ourIssue, theirIssue)
+		if err != nil {
+			return 0, 0, err
+		}
+		folded = append(folded, issue)
+		merged++
+	}
+
+	if len(folded) == 0 {
+		return 0, 0, nil
+	}
+	return created, merged, store.commitIssues(folded, author, "Merge remote changes", chains...)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGitConfig(email string) *config.Config {
+	cfg := config.NewConfig()
+	cfg.User.Email = email
+	return cfg
+}
+
+// usePackedLayout switches repo to the packed layout the way
+// `ubik migrate --layout packed` does.
+func usePackedLayout(t *testing.T, repo *git.Repository) {
+	t.Helper()
+	repoConfig, err := repo.Config()
+	require.NoError(t, err)
+	repoConfig.Raw.Section("ubik").SetOption("layout", layoutPacked)
+	require.NoError(t, repo.SetConfig(repoConfig))
+}
+
+func TestPackedStore(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+	store := &packedStore{gitStore: newGitStore(repo, testGitConfig("alice@example.com"))}
+
+	one := Issue{Id: "one", Title: "One", Status: todo}
+	two := Issue{Id: "two", Title: "Two", Status: todo}
+	require.NoError(t, store.SaveIssues(one, two))
+
+	ref, err := repo.Reference(packedIndexRef, true)
+	require.NoError(t, err)
+	commit, err := repo.CommitObject(ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, 0, commit.NumParents(), "both issues land in a single commit")
+
+	one.Status = done
+	require.NoError(t, store.SaveIssue(one))
+
+	issues, err := store.Issues()
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+	assert.Equal(t, done, issues[0].Status)
+	assert.Equal(t, "Two", issues[1].Title)
+
+	history, err := store.IssueHistory("one")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, []string{"status changed from todo to done"}, history[0].Changes)
+
+	history, err = store.IssueHistory("two")
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestPackIssues(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+	cfg := testGitConfig("alice@example.com")
+
+	refStore := newGitStore(repo, cfg)
+	require.NoError(t, refStore.SaveIssue(Issue{Id: "one", Title: "One", Status: todo}))
+	require.NoError(t, refStore.SaveIssue(Issue{Id: "one", Title: "One", Status: done}))
+	require.NoError(t, refStore.SaveIssue(Issue{Id: "two", Title: "Two"}))
+
+	count, err := packIssues(repo, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, layoutPacked, storageLayout(repo))
+
+	refs, err := refStore.refsWithPrefix("refs/ubik/issues/")
+	require.NoError(t, err)
+	assert.Empty(t, refs)
+
+	store := openStore(repo, cfg)
+	require.IsType(t, &packedStore{}, store.(*signingStore).Store)
+	issues, err := store.Issues()
+	require.NoError(t, err)
+	assert.Len(t, issues, 2)
+
+	history, err := store.IssueHistory("one")
+	require.NoError(t, err)
+	require.Len(t, history, 2, "history from before packing is kept")
+	assert.Equal(t, []string{"status changed from todo to done"}, history[0].Changes)
+	assert.Equal(t, todo, history[1].Issue.Status)
+}
+
+func TestSyncPackedIndex(t *testing.T) {
+	remoteDir := t.TempDir()
+	_, err := git.PlainInit(remoteDir, true)
+	require.NoError(t, err)
+
+	alice := newSyncClone(t, remoteDir)
+	bob := newSyncClone(t, remoteDir)
+	usePackedLayout(t, alice)
+	usePackedLayout(t, bob)
+	aliceStore := &packedStore{gitStore: newGitStore(alice, testGitConfig("alice@example.com"))}
+	bobStore := &packedStore{gitStore: newGitStore(bob, testGitConfig("bob@example.com"))}
+	sig := object.Signature{Email: "sync@example.com", When: time.Now()}
+
+	issue := Issue{Id: "one", Title: "One", Status: todo}
+	require.NoError(t, aliceStore.SaveIssue(issue))
+	_, err = syncRepo(alice, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+
+	aliceIssue := issue
+	aliceIssue.Title = "Renamed"
+	require.NoError(t, aliceStore.SaveIssues(aliceIssue, Issue{Id: "two", Title: "Two"}))
+	bobIssue := issue
+	bobIssue.Status = done
+	require.NoError(t, bobStore.SaveIssue(bobIssue))
+
+	_, err = syncRepo(alice, "origin", sig, nil)
+	require.NoError(t, err)
+	report, err := syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Merged)
+
+	issues, err := bobStore.Issues()
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+	assert.Equal(t, "Renamed", issues[0].Title)
+	assert.Equal(t, done, issues[0].Status)
+	assert.Empty(t, issues[0].Conflicts)
+}
+
+func TestSyncPackedIndexWithIssueRefs(t *testing.T) {
+	remoteDir := t.TempDir()
+	_, err := git.PlainInit(remoteDir, true)
+	require.NoError(t, err)
+
+	alice := newSyncClone(t, remoteDir)
+	bob := newSyncClone(t, remoteDir)
+	aliceCfg := testGitConfig("alice@example.com")
+	bobStore := newGitStore(bob, testGitConfig("bob@example.com"))
+	sig := object.Signature{Email: "sync@example.com", When: time.Now()}
+
+	one := Issue{Id: "one", Title: "One", Status: todo}
+	require.NoError(t, bobStore.SaveIssue(one))
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = syncRepo(alice, "origin", sig, nil)
+	require.NoError(t, err)
+
+	// alice packs her clone while bob keeps working on per-ref issues
+	_, err = packIssues(alice, aliceCfg)
+	require.NoError(t, err)
+	aliceStore := &packedStore{gitStore: newGitStore(alice, aliceCfg)}
+	aliceIssue := one
+	aliceIssue.Title = "Renamed"
+	require.NoError(t, aliceStore.SaveIssue(aliceIssue))
+	one.Status = done
+	require.NoError(t, bobStore.SaveIssue(one))
+	require.NoError(t, bobStore.SaveIssue(Issue{Id: "two", Title: "Two", Status: todo}))
+
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+	report, err := syncRepo(alice, "origin", sig, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Merged)
+
+	refs, err := aliceStore.refsWithPrefix("refs/ubik/issues/")
+	require.NoError(t, err)
+	assert.Empty(t, refs, "remote issue refs are folded into the index")
+	issues, err := aliceStore.Issues()
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+	assert.Equal(t, "Renamed", issues[0].Title)
+	assert.Equal(t, done, issues[0].Status)
+	assert.Empty(t, issues[0].Conflicts)
+	assert.Equal(t, "Two", issues[1].Title)
+
+	history, err := aliceStore.IssueHistory("one")
+	require.NoError(t, err)
+	assert.True(t, slices.ContainsFunc(history, func(r IssueRevision) bool { return r.Author == "bob@example.com" && r.Issue.Status == done }),
+		"bob's revisions are part of the history")
+
+	_, err = syncRepo(bob, "origin", sig, nil)
+	assert.ErrorContains(t, err, "migrate --layout packed", "a per-ref clone can't take in the index")
+	refs, err = bobStore.refsWithPrefix("refs/ubik/staging/")
+	require.NoError(t, err)
+	assert.Empty(t, refs)
+}
+
+func TestMergePackedIndexKeepsPurgedIssuesOut(t *testing.T) {
+	remoteDir := t.TempDir()
+	_, err := git.PlainInit(remoteDir, true)
+	require.NoError(t, err)
+
+	alice := newSyncClone(t, remoteDir)
+	bob := newSyncClone(t, remoteDir)
+	usePackedLayout(t, alice)
+	usePackedLayout(t, bob)
+	aliceStore := &packedStore{gitStore: newGitStore(alice, testGitConfig("alice@example.com"))}
+	bobStore := &packedStore{gitStore: newGitStore(bob, testGitConfig("bob@example.com"))}
+	sig := object.Signature{Email: "sync@example.com", When: time.Now()}
+
+	require.NoError(t, aliceStore.SaveIssues(Issue{Id: "one", Title: "One"}, Issue{Id: "two", Title: "Two"}))
+	_, err = syncRepo(alice, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+
+	// bob edits one while alice purges it
+	require.NoError(t, bobStore.SaveIssue(Issue{Id: "one", Title: "Edited"}))
+	require.NoError(t, aliceStore.PurgeIssue("one"))
+
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = syncRepo(alice, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+
+	for _, store := range []*packedStore{aliceStore, bobStore} {
+		issues, err := store.Issues()
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, "two", issues[0].Id)
+	}
+}
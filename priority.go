@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type issuePriority string
+
+const (
+	noPriority     issuePriority = ""
+	urgentPriority issuePriority = "urgent"
+	highPriority   issuePriority = "high"
+	mediumPriority issuePriority = "medium"
+	lowPriority    issuePriority = "low"
+)
+
+// priorities lists every priority, most pressing first.
+var priorities = []issuePriority{urgentPriority, highPriority, mediumPriority, lowPriority, noPriority}
+
+// rank orders priorities for sorting; lower is more pressing.
+func (p issuePriority) rank() int {
+	return slices.Index(priorities, p)
+}
+
+// cycle steps through the priorities in the form's picker, starting from
+// none.
+func (p issuePriority) cycle(delta int) issuePriority {
+	options := append([]issuePriority{noPriority}, priorities[:len(priorities)-1]...)
+	i := slices.Index(options, p)
+	return options[(i+delta+len(options))%len(options)]
+}
+
+func (p issuePriority) PrettyString() string {
+	if p == noPriority {
+		return "none"
+	}
+	return lipgloss.NewStyle().Foreground(p.color()).Render(string(p))
+}
+
+func (p issuePriority) color() lipgloss.AdaptiveColor {
+	colors := map[issuePriority]lipgloss.AdaptiveColor{
+		urgentPriority: styles.Theme.RedText,
+		highPriority:   styles.Theme.YellowText,
+		mediumPriority: styles.Theme.SecondaryText,
+		lowPriority:    styles.Theme.FaintText,
+	}
+	return colors[p]
+}
+
+// isPastDue reports whether a due date has gone by. Due dates are whole days,
+// so something due today isn't late until tomorrow.
+func isPastDue(due time.Time) bool {
+	return !due.IsZero() && due.AddDate(0, 0, 1).Before(time.Now())
+}
+
+// parseDueDate reads a YYYY-MM-DD date in local time; empty input clears the
+// due date.
+func parseDueDate(input string) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.ParseInLocation(time.DateOnly, input, time.Local)
+	if err != nil {
+		return time.Time{}, errInvalidDueDate
+	}
+	return date.UTC(), nil
+}
+
+func formatDueDate(due time.Time) string {
+	if due.IsZero() {
+		return ""
+	}
+	return due.Local().Format(time.DateOnly)
+}
+
+func (i Issue) isOverdue(w Workflow) bool {
+	return !w.isClosed(i.Status) && isPastDue(i.DueDate)
+}
+
+func renderIssueDueDate(issue Issue, w Workflow) string {
+	due := fmt.Sprintf("due %s", formatDueDate(issue.DueDate))
+	if issue.isOverdue(w) {
+		return lipgloss.NewStyle().Foreground(styles.Theme.RedText).Render(due + " (overdue)")
+	}
+	return lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render(due)
+}
+
+// PriorityFilter narrows the issue list to one priority, e.g.
+// "priority:urgent" or "priority:none".
+func PriorityFilter(term string, targets []string) []list.Rank {
+	priority := strings.ToLower(strings.TrimPrefix(term, "priority:"))
+	if priority == "none" {
+		priority = ""
+	}
+
+	var ranks []list.Rank
+	for i, t := range targets {
+		if strings.Split(t, "\n")[6] == priority {
+			ranks = append(ranks, list.Rank{Index: i})
+		}
+	}
+
+	return ranks
+}
+
+// DueFilter narrows the issue list by due date:
+//
+//	due:overdue     open issues whose due date has passed
+//	due:today       due today or earlier
+//	due:week        due within the next seven days or earlier
+//	due:none        no due date
+//	due:2024-06-30  due on or before that date
+func DueFilter(term string, targets []string, w Workflow) []list.Rank {
+	value := strings.ToLower(strings.TrimPrefix(term, "due:"))
+	today := time.Now().Format(time.DateOnly)
+
+	var cutoff string
+	switch value {
+	case "overdue", "none":
+	case "today":
+		cutoff = today
+	case "week":
+		cutoff = time.Now().AddDate(0, 0, 7).Format(time.DateOnly)
+	default:
+		if _, err := time.Parse(time.DateOnly, value); err != nil {
+			return nil
+		}
+		cutoff = value
+	}
+
+	var ranks []list.Rank
+	for i, t := range targets {
+		lines := strings.Split(t, "\n")
+		status, due := issueStatus(lines[2]), lines[7]
+
+		var match bool
+		switch value {
+		case "none":
+			match = due == ""
+		case "overdue":
+			// dates in this form sort as strings
+			match = due != "" && due < today && !w.isClosed(status)
+		default:
+			match = due != "" && due <= cutoff
+		}
+		if match {
+			ranks = append(ranks, list.Rank{Index: i})
+		}
+	}
+
+	return ranks
+}
+
+type issueSortMode int
+
+const (
+	sortByUpdated issueSortMode = iota
+	sortByPriority
+	sortByDueDate
+)
+
+func (s issueSortMode) next() issueSortMode {
+	return (s + 1) % 3
+}
+
+func (s issueSortMode) String() string {
+	return [...]string{"last updated", "priority", "due date"}[s]
+}
+
+// sortIssuesBy sorts like SortIssues, then reorders the open issues by
+// priority or due date. Closed issues stay at the bottom, most recently
+// updated first.
+func sortIssuesBy(issues []Issue, mode issueSortMode, w Workflow) []Issue {
+	sorted := SortIssues(issues, w)
+	open := slices.IndexFunc(sorted, func(issue Issue) bool { return w.isClosed(issue.Status) })
+	if open < 0 {
+		open = len(sorted)
+	}
+
+	switch mode {
+	case sortByPriority:
+		slices.SortStableFunc(sorted[:open], func(a, b Issue) int {
+			return a.Priority.rank() - b.Priority.rank()
+		})
+	case sortByDueDate:
+		slices.SortStableFunc(sorted[:open], func(a, b Issue) int {
+			switch {
+			case a.DueDate.IsZero() != b.DueDate.IsZero():
+				if a.DueDate.IsZero() {
+					return 1
+				}
+				return -1
+			default:
+				return a.DueDate.Compare(b.DueDate)
+			}
+		})
+	}
+
+	return sorted
+}
+
+func issuesPriorityHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Back):
+			if m.issueForm.editing {
+				m.path = issuesShowPath
+			} else {
+				m.path = issuesIndexPath
+			}
+			return m, cmd
+		case key.Matches(msg, keys.PickerPrev):
+			m.issueForm.priority = m.issueForm.priority.cycle(-1)
+			return m, cmd
+		case key.Matches(msg, keys.PickerNext):
+			m.issueForm.priority = m.issueForm.priority.cycle(1)
+			return m, cmd
+		case key.Matches(msg, keys.NextInput):
+			if m.issueForm.editing {
+				m.path = issuesEditDuePath
+			} else {
+				m.path = issuesNewDuePath
+			}
+			return m, m.issueForm.dueInput.Focus()
+		}
+	}
+
+	return m, cmd
+}
+
+func issuesDueHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Back):
+			if m.issueForm.editing {
+				m.path = issuesShowPath
+			} else {
+				m.path = issuesIndexPath
+			}
+			return m, cmd
+		case key.Matches(msg, keys.NextInput):
+			m.issueForm.dueInput.Blur()
+			return m.focusFields()
+		}
+	}
+
+	m.issueForm.dueInput, cmd = m.issueForm.dueInput.Update(msg)
+	return m, cmd
+}
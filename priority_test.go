@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortIssuesBy(t *testing.T) {
+	now := time.Now()
+	issues := []Issue{
+		{Id: "low", Status: todo, Priority: lowPriority, DueDate: now.AddDate(0, 0, 1), UpdatedAt: now},
+		{Id: "none", Status: todo, UpdatedAt: now.Add(-time.Hour)},
+		{Id: "urgent", Status: inProgress, Priority: urgentPriority, DueDate: now.AddDate(0, 0, 5), UpdatedAt: now.Add(-2 * time.Hour)},
+		{Id: "closed", Status: done, Priority: urgentPriority, DueDate: now.AddDate(0, 0, -1), UpdatedAt: now.Add(time.Hour)},
+	}
+
+	ids := func(issues []Issue) []string { return convertSlice(issues, func(i Issue) string { return i.Id }) }
+	assert.Equal(t, []string{"low", "none", "urgent", "closed"}, ids(sortIssuesBy(issues, sortByUpdated, defaultWorkflow())))
+	assert.Equal(t, []string{"urgent", "low", "none", "closed"}, ids(sortIssuesBy(issues, sortByPriority, defaultWorkflow())))
+	assert.Equal(t, []string{"low", "urgent", "none", "closed"}, ids(sortIssuesBy(issues, sortByDueDate, defaultWorkflow())))
+}
+
+func TestPriorityAndDueFilters(t *testing.T) {
+	today := time.Now().Truncate(24 * time.Hour)
+	issues := []Issue{
+		{Title: "late", Status: todo, Priority: highPriority, DueDate: today.AddDate(0, 0, -3)},
+		{Title: "late but done", Status: done, DueDate: today.AddDate(0, 0, -3)},
+		{Title: "soon", Status: todo, Priority: lowPriority, DueDate: today.AddDate(0, 0, 3)},
+		{Title: "someday", Status: todo},
+	}
+	targets := convertSlice(issues, Issue.FilterValue)
+
+	titles := func(term string) []string {
+		var titles []string
+		for _, rank := range CustomFilter(term, targets, defaultWorkflow(), FieldSchema{}) {
+			titles = append(titles, issues[rank.Index].Title)
+		}
+		return titles
+	}
+
+	assert.ElementsMatch(t, []string{"late"}, titles("priority:high"))
+	assert.ElementsMatch(t, []string{"late but done", "someday"}, titles("priority:none"))
+	assert.ElementsMatch(t, []string{"late"}, titles("due:overdue"))
+	assert.ElementsMatch(t, []string{"late", "late but done", "soon"}, titles("due:week"))
+	assert.ElementsMatch(t, []string{"someday"}, titles("due:none"))
+	assert.ElementsMatch(t, []string{"late", "late but done"}, titles("due:"+today.AddDate(0, 0, -1).Format(time.DateOnly)))
+
+	assert.True(t, issues[0].isOverdue(defaultWorkflow()))
+	assert.False(t, issues[1].isOverdue(defaultWorkflow()), "closed issues are never overdue")
+}
+
+func TestIssuePriorityCycle(t *testing.T) {
+	assert.Equal(t, urgentPriority, noPriority.cycle(1))
+	assert.Equal(t, lowPriority, noPriority.cycle(-1))
+	assert.Equal(t, noPriority, lowPriority.cycle(1))
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+type relationKind string
+
+const (
+	relationBlocks      relationKind = "blocks"
+	relationBlockedBy   relationKind = "blocked-by"
+	relationParent      relationKind = "parent"
+	relationChild       relationKind = "child"
+	relationDuplicateOf relationKind = "duplicate-of"
+	// relationDuplicatedBy is only ever worked out from the other side of a
+	// duplicate-of; it can't be entered in the form.
+	relationDuplicatedBy relationKind = "duplicated-by"
+)
+
+// relationKinds lists the kinds that can be entered, in display order.
+var relationKinds = []relationKind{relationBlockedBy, relationBlocks, relationParent, relationChild, relationDuplicateOf}
+
+func (k relationKind) inverse() relationKind {
+	inverses := map[relationKind]relationKind{
+		relationBlocks:       relationBlockedBy,
+		relationBlockedBy:    relationBlocks,
+		relationParent:       relationChild,
+		relationChild:        relationParent,
+		relationDuplicateOf:  relationDuplicatedBy,
+		relationDuplicatedBy: relationDuplicateOf,
+	}
+	return inverses[k]
+}
+
+func (k relationKind) PrettyString() string {
+	return strings.ReplaceAll(string(k), "-", " ")
+}
+
+// Relation points from the issue it's stored on to another issue. Only one
+// side of a relationship is stored; the other issue sees the inverse, which
+// is worked out on load.
+type Relation struct {
+	Kind    relationKind `json:"kind"`
+	IssueId string       `json:"issue_id"`
+}
+
+func (r Relation) key() string {
+	return fmt.Sprintf("%s %s", r.Kind, r.IssueId)
+}
+
+// RelatedIssue is a relationship resolved against the loaded issues, seen
+// from the issue carrying it.
+type RelatedIssue struct {
+	Kind      relationKind
+	IssueId   string
+	Shortcode string
+	Title     string
+	Status    issueStatus
+}
+
+func (r RelatedIssue) isOpen(w Workflow) bool {
+	return !w.isClosed(r.Status)
+}
+
+var errInvalidRelation = errors.New("invalid relationship")
+
+// parseRelations reads the form's "kind:#ref" list, e.g.
+// "blocked-by:#uU0nXq parent:#Zk3m_a". A ref that doesn't resolve but is the
+// id of one of the issue's existing relations is kept: formatRelations
+// writes raw ids for related issues that were deleted or purged since.
+func parseRelations(input string, issues []Issue, selfId string, existing []Relation) ([]Relation, error) {
+	var relations []Relation
+	for _, field := range strings.Fields(input) {
+		kind, ref, ok := strings.Cut(field, ":")
+		if !ok || !slices.Contains(relationKinds, relationKind(kind)) {
+			return nil, fmt.Errorf("%w %q: use one of %s followed by :#issue", errInvalidRelation, field, joinRelationKinds())
+		}
+		issue, err := resolveIssueRef(issues, ref)
+		if errors.Is(err, errIssueNotFound) {
+			id := strings.TrimPrefix(ref, "#")
+			if slices.ContainsFunc(existing, func(r Relation) bool { return r.IssueId == id }) {
+				issue = Issue{Id: id}
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if issue.Id == selfId {
+			return nil, fmt.Errorf("%w %q: an issue can't relate to itself", errInvalidRelation, field)
+		}
+		relation := Relation{Kind: relationKind(kind), IssueId: issue.Id}
+		if !slices.Contains(relations, relation) {
+			relations = append(relations, relation)
+		}
+	}
+	return relations, nil
+}
+
+func joinRelationKinds() string {
+	var kinds []string
+	for _, kind := range relationKinds {
+		kinds = append(kinds, string(kind))
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// formatRelations is the inverse of parseRelations, for prefilling the form.
+func formatRelations(relations []Relation, issues []Issue) string {
+	var fields []string
+	for _, relation := range relations {
+		ref := relation.IssueId
+		if i := slices.IndexFunc(issues, func(issue Issue) bool { return issue.Id == relation.IssueId }); i >= 0 {
+			ref = issues[i].Shortcode
+		}
+		fields = append(fields, fmt.Sprintf("%s:#%s", relation.Kind, ref))
+	}
+	return strings.Join(fields, " ")
+}
+
+// markDuplicate closes an issue with the workflow's duplicate status when a
+// duplicate-of relationship is added to it.
+func markDuplicate(before []Relation, issue Issue, w Workflow) Issue {
+	for _, relation := range issue.Relations {
+		if relation.Kind == relationDuplicateOf && !slices.Contains(before, relation) {
+			issue.Status = w.Duplicate
+		}
+	}
+	return issue
+}
+
+// linkRelations fills in Issue.RelatedIssues from both sides of every stored
+// relationship.
+func linkRelations(issues []Issue) []Issue {
+	issues = slices.Clone(issues)
+	positions := make(map[string]int, len(issues))
+	for i := range issues {
+		issues[i].RelatedIssues = nil
+		positions[issues[i].Id] = i
+	}
+
+	relate := func(from int, kind relationKind, to Issue) {
+		related := RelatedIssue{Kind: kind, IssueId: to.Id, Shortcode: to.Shortcode, Title: to.Title, Status: to.Status}
+		if !slices.Contains(issues[from].RelatedIssues, related) {
+			issues[from].RelatedIssues = append(issues[from].RelatedIssues, related)
+		}
+	}
+
+	for i, issue := range issues {
+		for _, relation := range issue.Relations {
+			j, ok := positions[relation.IssueId]
+			if !ok {
+				continue
+			}
+			relate(i, relation.Kind, issues[j])
+			relate(j, relation.Kind.inverse(), issue)
+		}
+	}
+
+	order := append(slices.Clone(relationKinds), relationDuplicatedBy)
+	for i := range issues {
+		slices.SortStableFunc(issues[i].RelatedIssues, func(a, b RelatedIssue) int {
+			return slices.Index(order, a.Kind) - slices.Index(order, b.Kind)
+		})
+	}
+
+	return issues
+}
+
+// openBlockers returns the issues still blocking issue.
+func (i Issue) openBlockers(w Workflow) []RelatedIssue {
+	var blockers []RelatedIssue
+	for _, related := range i.RelatedIssues {
+		if related.Kind == relationBlockedBy && related.isOpen(w) {
+			blockers = append(blockers, related)
+		}
+	}
+	return blockers
+}
+
+// warnOpenBlockers flashes a warning when issue is being moved to done while
+// something still blocks it. It doesn't stop the move.
+func (m *Model) warnOpenBlockers(issue Issue) {
+	blockers := issue.openBlockers(m.workflow)
+	if issue.Status != m.workflow.Done || len(blockers) == 0 {
+		return
+	}
+
+	var shortcodes []string
+	for _, blocker := range blockers {
+		shortcodes = append(shortcodes, "#"+blocker.Shortcode)
+	}
+	m.flash = fmt.Sprintf("warning: #%s is still blocked by %s", issue.Shortcode, strings.Join(shortcodes, ", "))
+	m.UpdateLayout(m.layout.TerminalSize)
+}
+
+func renderRelatedIssues(related []RelatedIssue, w Workflow) string {
+	var b strings.Builder
+	faint := lipgloss.NewStyle().Foreground(styles.Theme.FaintText)
+	b.WriteString(faint.Render("Relationships") + "\n")
+	for _, r := range related {
+		identifier := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render("#" + r.Shortcode)
+		b.WriteString(fmt.Sprintf("%s %s %s %s\n", faint.Render(r.Kind.PrettyString()), w.icon(r.Status), identifier, r.Title))
+	}
+	return b.String()
+}
+
+func mergeRelations(base, ours, theirs []Relation) []Relation {
+	keys := func(relations []Relation) []string {
+		return convertSlice(relations, Relation.key)
+	}
+
+	var merged []Relation
+	for _, key := range mergeLabels(keys(base), keys(ours), keys(theirs)) {
+		kind, id, _ := strings.Cut(key, " ")
+		merged = append(merged, Relation{Kind: relationKind(kind), IssueId: id})
+	}
+	return merged
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Every stored issue, action and milestone carries the schema version it was written
+// with. Migrations upgrade a record one version at a time: migration i takes
+// a record from version i to version i+1, so the current version is simply the
+// number of migrations.
+type migration func(record map[string]any) error
+
+var issueMigrations = []migration{
+	// v0 -> v1: the labels struct tag was misspelled, so labels were stored
+	// under the Go field name.
+	func(record map[string]any) error {
+		if labels, ok := record["Labels"]; ok {
+			if _, exists := record["labels"]; !exists {
+				record["labels"] = labels
+			}
+			delete(record, "Labels")
+		}
+		return nil
+	},
+}
+
+var actionMigrations = []migration{
+	// v0 -> v1: nothing changed except that the version is now stamped.
+	func(record map[string]any) error { return nil },
+}
+
+var milestoneMigrations = []migration{
+	// v0 -> v1: nothing changed except that the version is now stamped.
+	func(record map[string]any) error { return nil },
+}
+
+var (
+	issueSchemaVersion     = len(issueMigrations)
+	actionSchemaVersion    = len(actionMigrations)
+	milestoneSchemaVersion = len(milestoneMigrations)
+)
+
+var errNewerSchema = errors.New("record was written by a newer version of ubik")
+
+func recordVersion(record map[string]any) int {
+	version, ok := record["schema_version"].(float64)
+	if !ok {
+		return 0
+	}
+	return int(version)
+}
+
+// migrateRecord upgrades a JSON record to the latest version. It returns the
+// upgraded JSON and the version the record was stored with.
+func migrateRecord(data []byte, migrations []migration) ([]byte, int, error) {
+	var record map[string]any
+	err := json.Unmarshal(data, &record)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stored := recordVersion(record)
+	if stored > len(migrations) {
+		return nil, stored, fmt.Errorf("%w (schema version %d)", errNewerSchema, stored)
+	}
+	if stored == len(migrations) {
+		return data, stored, nil
+	}
+
+	for version := stored; version < len(migrations); version++ {
+		err = migrations[version](record)
+		if err != nil {
+			return nil, stored, fmt.Errorf("migrating from schema version %d: %w", version, err)
+		}
+	}
+	record["schema_version"] = len(migrations)
+
+	migrated, err := json.Marshal(record)
+	return migrated, stored, err
+}
+
+func decodeIssue(data []byte) (Issue, error) {
+	var issue Issue
+	migrated, _, err := migrateRecord(data, issueMigrations)
+	if err != nil {
+		return issue, err
+	}
+	err = json.Unmarshal(migrated, &issue)
+	return issue, err
+}
+
+func decodeAction(data []byte) (Action, error) {
+	var action Action
+	migrated, _, err := migrateRecord(data, actionMigrations)
+	if err != nil {
+		return action, err
+	}
+	err = json.Unmarshal(migrated, &action)
+	return action, err
+}
+
+func decodeMilestone(data []byte) (Milestone, error) {
+	var milestone Milestone
+	migrated, _, err := migrateRecord(data, milestoneMigrations)
+	if err != nil {
+		return milestone, err
+	}
+	err = json.Unmarshal(migrated, &milestone)
+	return milestone, err
+}
+
+func encodeIssue(issue Issue) ([]byte, error) {
+	issue.SchemaVersion = issueSchemaVersion
+	return json.Marshal(issue)
+}
+
+func encodeAction(action Action) ([]byte, error) {
+	action.SchemaVersion = actionSchemaVersion
+	return json.Marshal(action)
+}
+
+func encodeMilestone(milestone Milestone) ([]byte, error) {
+	milestone.SchemaVersion = milestoneSchemaVersion
+	return json.Marshal(milestone)
+}
+
+type MigrationReport struct {
+	Issues     int
+	Actions    int
+	Milestones int
+	// Unsigned counts records whose signature was dropped because migrating
+	// changed what it covered and there's no signing key to sign them again.
+	Unsigned int
+	DryRun   bool
+}
+
+func (r MigrationReport) String() string {
+	verb := "migrated"
+	if r.DryRun {
+		verb = "would migrate"
+	}
+	var unsigned string
+	if r.Unsigned > 0 {
+		unsigned = fmt.Sprintf(", %d left unsigned (no signing key)", r.Unsigned)
+	}
+	return fmt.Sprintf("%s %d issue(s), %d action(s) and %d milestone(s)%s", verb, r.Issues, r.Actions, r.Milestones, unsigned)
+}
+
+// migrateRepo rewrites every stored record that is older than the current
+// schema. Issues get a new commit in their history; actions and milestones
+// are rewritten in place. Records whose signed content changed are signed again by signer,
+// which may be nil when signing isn't configured.
+func migrateRepo(repo *git.Repository, author object.Signature, signer *signingStore, dryRun bool) (MigrationReport, error) {
+	report := MigrationReport{DryRun: dryRun}
+	resign := &resigner{signer: signer}
+
+	refs, err := repo.References()
+	if err != nil {
+		return report, err
+	}
+
+	var ubikRefs []*plumbing.Reference
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), "refs/ubik/") {
+			ubikRefs = append(ubikRefs, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for _, ref := range ubikRefs {
+		name := ref.Name().String()
+		switch {
+		case strings.HasPrefix(name, "refs/ubik/issues/"):
+			data, err := readIssueData(repo, ref.Hash())
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			_, stored, err := migrateRecord(data, issueMigrations)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			if stored == issueSchemaVersion {
+				continue
+			}
+			report.Issues++
+			if dryRun {
+				continue
+			}
+
+			issue, err := migrateIssue(data, resign)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			var parents []plumbing.Hash
+			if _, err := repo.CommitObject(ref.Hash()); err == nil {
+				parents = append(parents, ref.Hash())
+			}
+			message := fmt.Sprintf("Migrate issue to schema version %d", issueSchemaVersion)
+			hash, err := writeIssueCommit(repo, issue, author, message, parents...)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			err = repo.Storer.CheckAndSetReference(plumbing.NewHashReference(ref.Name(), hash), ref)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+		case ref.Name() == packedIndexRef:
+			migrated, err := migratePackedIndex(repo, ref, author, resign, dryRun)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			report.Issues += migrated
+		case strings.HasPrefix(name, "refs/ubik/actions/"):
+			blob, err := repo.BlobObject(ref.Hash())
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			data, err := readBlob(blob)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			_, stored, err := migrateRecord(data, actionMigrations)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			if stored == actionSchemaVersion {
+				continue
+			}
+			report.Actions++
+			if dryRun {
+				continue
+			}
+
+			action, err := decodeAction(data)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			var original Action
+			err = json.Unmarshal(data, &original)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			action, err = resign.action(action, original)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			encoded, err := encodeAction(action)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			hash, err := storeBlob(repo, encoded)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			err = repo.Storer.CheckAndSetReference(plumbing.NewHashReference(ref.Name(), hash), ref)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+		case strings.HasPrefix(name, milestoneRefPrefix):
+			blob, err := repo.BlobObject(ref.Hash())
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			data, err := readBlob(blob)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			_, stored, err := migrateRecord(data, milestoneMigrations)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			if stored == milestoneSchemaVersion {
+				continue
+			}
+			report.Milestones++
+			if dryRun {
+				continue
+			}
+
+			milestone, err := decodeMilestone(data)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			encoded, err := encodeMilestone(milestone)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			hash, err := storeBlob(repo, encoded)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			err = repo.Storer.CheckAndSetReference(plumbing.NewHashReference(ref.Name(), hash), ref)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	report.Unsigned = resign.Dropped
+	return report, nil
+}
+
+// migrateIssue upgrades a stored issue. Its signature was made over the
+// issue as it was stored, so it's checked against that rather than the
+// upgraded copy.
+func migrateIssue(data []byte, resign *resigner) (Issue, error) {
+	issue, err := decodeIssue(data)
+	if err != nil {
+		return issue, err
+	}
+	var original Issue
+	err = json.Unmarshal(data, &original)
+	if err != nil {
+		return issue, err
+	}
+	return resign.issue(issue, original)
+}
+
+// migratePackedIndex rewrites every outdated issue in the packed index in a
+// single commit.
+func migratePackedIndex(repo *git.Repository, ref *plumbing.Reference, author object.Signature, resign *resigner, dryRun bool) (int, error) {
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return 0, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return 0, err
+	}
+
+	entries := make(map[string]plumbing.Hash)
+	var migrated int
+	for _, entry := range tree.Entries {
+		entries[entry.Name] = entry.Hash
+		if !strings.HasSuffix(entry.Name, ".json") {
+			continue
+		}
+
+		data, err := readIssueData(repo, entry.Hash)
+		if err != nil {
+			return 0, err
+		}
+		_, stored, err := migrateRecord(data, issueMigrations)
+		if err != nil {
+			return 0, err
+		}
+		if stored == issueSchemaVersion {
+			continue
+		}
+		migrated++
+		if dryRun {
+			continue
+		}
+
+		issue, err := migrateIssue(data, resign)
+		if err != nil {
+			return 0, err
+		}
+		upgraded, err := encodeIssue(issue)
+		if err != nil {
+			return 0, err
+		}
+		entries[entry.Name], err = storeBlob(repo, upgraded)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if migrated == 0 || dryRun {
+		return migrated, nil
+	}
+
+	message := fmt.Sprintf("Migrate %d issue(s) to schema version %d", migrated, issueSchemaVersion)
+	hash, err := writePackedCommit(repo, entries, author, message, commit.Hash)
+	if err != nil {
+		return 0, err
+	}
+
+	return migrated, repo.Storer.CheckAndSetReference(plumbing.NewHashReference(ref.Name(), hash), ref)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueMigrations(t *testing.T) {
+	t.Run("v0 labels are moved to the labels key", func(t *testing.T) {
+		migrated, stored, err := migrateRecord([]byte(`{"id":"abc","Labels":["bug","ui"]}`), issueMigrations[:1])
+		require.NoError(t, err)
+		assert.Equal(t, 0, stored)
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(migrated, &record))
+		assert.Equal(t, []any{"bug", "ui"}, record["labels"])
+		assert.NotContains(t, record, "Labels")
+		assert.Equal(t, float64(1), record["schema_version"])
+	})
+
+	t.Run("current records are left untouched", func(t *testing.T) {
+		data, err := encodeIssue(Issue{Id: "abc", Labels: []string{"bug"}})
+		require.NoError(t, err)
+
+		migrated, stored, err := migrateRecord(data, issueMigrations)
+		require.NoError(t, err)
+		assert.Equal(t, issueSchemaVersion, stored)
+		assert.Equal(t, data, migrated)
+	})
+
+	t.Run("newer records are rejected", func(t *testing.T) {
+		_, err := decodeIssue([]byte(`{"id":"abc","schema_version":999}`))
+		assert.ErrorIs(t, err, errNewerSchema)
+	})
+}
+
+func TestActionMigrations(t *testing.T) {
+	t.Run("v0 actions are stamped", func(t *testing.T) {
+		action, err := decodeAction([]byte(`{"id":"abc","status":"succeeded"}`))
+		require.NoError(t, err)
+		assert.Equal(t, actionSchemaVersion, action.SchemaVersion)
+		assert.Equal(t, succeeded, action.Status)
+	})
+}
+
+func TestMigrateRepo(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+
+	issueBlob, err := storeBlob(repo, []byte(`{"id":"abc","title":"Old","Labels":["bug"]}`))
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(issueRefName("abc"), issueBlob)))
+	actionBlob, err := storeBlob(repo, []byte(`{"id":"def","status":"failed"}`))
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/ubik/actions/def", actionBlob)))
+	milestoneBlob, err := storeBlob(repo, []byte(`{"id":"ghi","title":"v1","due_date":"0001-01-01T00:00:00Z","closed":false}`))
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(milestoneRefName("ghi"), milestoneBlob)))
+
+	author := object.Signature{Email: "alice@example.com", When: time.Now()}
+	report, err := migrateRepo(repo, author, nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationReport{Issues: 1, Actions: 1, Milestones: 1, DryRun: true}, report)
+
+	report, err = migrateRepo(repo, author, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationReport{Issues: 1, Actions: 1, Milestones: 1}, report)
+
+	ref, err := repo.Reference(issueRefName("abc"), true)
+	require.NoError(t, err)
+	data, err := readIssueData(repo, ref.Hash())
+	require.NoError(t, err)
+	assert.JSONEq(t, `["bug"]`, string(mustMarshal(t, mustDecode(t, data)["labels"])))
+
+	ref, err = repo.Reference(milestoneRefName("ghi"), true)
+	require.NoError(t, err)
+	milestone, err := readMilestone(repo, ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "v1", milestone.Title)
+	assert.Equal(t, milestoneSchemaVersion, milestone.SchemaVersion)
+
+	report, err = migrateRepo(repo, author, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationReport{}, report)
+}
+
+func TestMigrateRepoKeepsSignatures(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+	signingConfig, _, _ := sshSigningConfig(t, "alice@example.com")
+	signer := newSigningStore(t, nil, signingConfig)
+
+	signed, err := signer.signIssue(Issue{Id: "abc", Title: "Old", Labels: []string{"bug"}})
+	require.NoError(t, err)
+	record := mustDecode(t, mustMarshal(t, signed))
+	delete(record, "schema_version")
+	record["Labels"] = record["labels"]
+	delete(record, "labels")
+	issueBlob, err := storeBlob(repo, mustMarshal(t, record))
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(issueRefName("abc"), issueBlob)))
+
+	author := object.Signature{Email: "bob@example.com", When: time.Now()}
+	report, err := migrateRepo(repo, author, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationReport{Issues: 1}, report, "the signed content didn't change")
+
+	ref, err := repo.Reference(issueRefName("abc"), true)
+	require.NoError(t, err)
+	migrated, err := readIssueObject(repo, ref.Hash())
+	require.NoError(t, err)
+	assert.True(t, signer.verifyIssue(migrated).Verified)
+}
+
+func mustDecode(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(data, &record))
+	return record
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
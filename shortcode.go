@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// Shortcodes are prefixes of the base64-encoded SHA-256 of an issue's id.
+// Six characters are enough almost always; when a new issue's prefix collides
+// with an existing shortcode it is extended, like an abbreviated git hash,
+// until it is unambiguous.
+const minShortcodeLength = 6
+
+func fullShortcode(id string) string {
+	hash := sha256.Sum256([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// uniqueShortcode returns the shortest prefix of id's full shortcode, at least
+// minShortcodeLength long, that no other issue's shortcode is ambiguous with.
+func uniqueShortcode(id string, issues []Issue) string {
+	full := fullShortcode(id)
+	length := minShortcodeLength
+	for _, issue := range issues {
+		if issue.Id == id || issue.Shortcode == "" {
+			continue
+		}
+		shared := commonPrefixLength(full, issue.Shortcode)
+		if shared+1 > length {
+			length = shared + 1
+		}
+	}
+
+	return full[:min(length, len(full))]
+}
+
+func commonPrefixLength(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+var errAmbiguousIssue = errors.New("ambiguous issue reference")
+
+// resolveIssueRef finds the issue that ref points at. ref may be a shortcode
+// or an id, or any unique prefix of either, with or without a leading '#'.
+// An exact shortcode or id match always wins over a longer shortcode that
+// merely starts with ref.
+func resolveIssueRef(issues []Issue, ref string) (Issue, error) {
+	ref = strings.TrimPrefix(strings.TrimSpace(ref), "#")
+	if ref == "" {
+		return Issue{}, errIssueNotFound
+	}
+
+	var exact, partial []Issue
+	for _, issue := range issues {
+		switch {
+		case issue.Shortcode == ref || issue.Id == ref:
+			exact = append(exact, issue)
+		case issueMatchesRef(issue, ref):
+			partial = append(partial, issue)
+		}
+	}
+
+	candidates := exact
+	if len(candidates) == 0 {
+		candidates = partial
+	}
+
+	switch len(candidates) {
+	case 0:
+		return Issue{}, fmt.Errorf("%w: #%s", errIssueNotFound, ref)
+	case 1:
+		return candidates[0], nil
+	default:
+		var shortcodes []string
+		for _, issue := range candidates {
+			shortcodes = append(shortcodes, "#"+issue.Shortcode)
+		}
+		return Issue{}, fmt.Errorf("%w: #%s could be %s", errAmbiguousIssue, ref, strings.Join(shortcodes, ", "))
+	}
+}
+
+func issueMatchesRef(issue Issue, ref string) bool {
+	return strings.HasPrefix(issue.Shortcode, ref) || strings.HasPrefix(issue.Id, strings.ToLower(ref))
+}
+
+// RefFilter narrows the issue list to issues whose shortcode or id starts
+// with the term, e.g. "#uU0n".
+func RefFilter(term string, targets []string) []list.Rank {
+	ref := strings.TrimPrefix(term, "#")
+
+	var ranks []list.Rank
+	for i, t := range targets {
+		refsPart := strings.Split(t, "\n")[3]
+		for _, field := range strings.Fields(refsPart) {
+			if strings.HasPrefix(field, ref) {
+				ranks = append(ranks, list.Rank{Index: i})
+				break
+			}
+		}
+	}
+
+	return ranks
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniqueShortcode(t *testing.T) {
+	id := "4f1c9a0e-7a8d-4d5e-9b0a-1f2e3d4c5b6a"
+	full := fullShortcode(id)
+	assert.Equal(t, StringToShortcode(id), full[:6], "short shortcodes are unchanged")
+
+	assert.Equal(t, full[:6], uniqueShortcode(id, nil))
+	assert.Equal(t, full[:6], uniqueShortcode(id, []Issue{{Id: "other", Shortcode: "zzzzzz"}}))
+
+	colliding := []Issue{{Id: "other", Shortcode: full[:6]}}
+	assert.Equal(t, full[:7], uniqueShortcode(id, colliding))
+
+	colliding = append(colliding, Issue{Id: "third", Shortcode: full[:8]})
+	assert.Equal(t, full[:9], uniqueShortcode(id, colliding))
+
+	assert.Equal(t, full[:6], uniqueShortcode(id, []Issue{{Id: id, Shortcode: full[:6]}}), "an issue never collides with itself")
+}
+
+func TestResolveIssueRef(t *testing.T) {
+	issues := []Issue{
+		{Id: "0a1b2c3d-0000-0000-0000-000000000000", Shortcode: "abcdef"},
+		{Id: "0a1b9999-0000-0000-0000-000000000000", Shortcode: "abcdefg"},
+		{Id: "ffff0000-0000-0000-0000-000000000000", Shortcode: "xyz123"},
+	}
+
+	tests := []struct {
+		ref      string
+		expected string
+		err      error
+	}{
+		{"#xyz", "xyz123", nil},
+		{"xyz123", "xyz123", nil},
+		{"#abcdef", "abcdef", nil},
+		{"abcdefg", "abcdefg", nil},
+		{"ffff", "xyz123", nil},
+		{"0A1B2C", "abcdef", nil},
+		{"#abc", "", errAmbiguousIssue},
+		{"0a1b", "", errAmbiguousIssue},
+		{"#nope", "", errIssueNotFound},
+		{"#", "", errIssueNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			issue, err := resolveIssueRef(issues, tt.ref)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, issue.Shortcode)
+		})
+	}
+}
+
+func TestCustomFilterByRef(t *testing.T) {
+	issues := []Issue{
+		{Id: "0a1b2c3d", Shortcode: "abcdef", Title: "Fix login"},
+		{Id: "ffff0000", Shortcode: "xyz123", Title: "abc notes"},
+	}
+	var targets []string
+	for _, issue := range issues {
+		targets = append(targets, issue.FilterValue())
+	}
+
+	ranks := CustomFilter("#xyz", targets, defaultWorkflow(), FieldSchema{})
+	require.Len(t, ranks, 1)
+	assert.Equal(t, 1, ranks[0].Index)
+
+	ranks = CustomFilter("#0a1b", targets, defaultWorkflow(), FieldSchema{})
+	require.Len(t, ranks, 1)
+	assert.Equal(t, 0, ranks[0].Index)
+
+	ranks = CustomFilter("abc", targets, defaultWorkflow(), FieldSchema{})
+	require.Len(t, ranks, 1)
+	assert.Equal(t, 1, ranks[0].Index, "plain terms don't match shortcodes")
+}
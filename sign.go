@@ -0,0 +1,716 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// Issues, comments and action results can carry a detached signature over
+// their content, made with the user's signing key. Signing is configured the
+// way git's is:
+//
+//	gpg.format                   openpgp (default) or ssh
+//	user.signingkey              the SSH private key (or its .pub) to sign with
+//	gpg.ssh.allowedSignersFile   the SSH keys trusted to verify signatures
+//
+// go-crypto can't talk to gpg-agent, so OpenPGP keys are read from armored
+// files instead:
+//
+//	ubik.signingKey              an armored OpenPGP secret key
+//	ubik.trustedKeys             an armored keyring trusted to verify signatures
+//
+// ubik.signingKey also overrides user.signingkey for SSH. The signing key is
+// always trusted.
+const (
+	signatureFormatOpenPGP = "openpgp"
+	signatureFormatSSH     = "ssh"
+)
+
+type Signature struct {
+	Format string `json:"format"`
+	// Signer is the email the signature claims to come from. It only counts
+	// as verified if a trusted key is bound to that email.
+	Signer string `json:"signer"`
+	Data   string `json:"data"`
+}
+
+// signaturePayload is the canonical form of a record that gets signed: its
+// JSON with the omitted keys and every empty value dropped, with map keys in
+// sorted order. Dropping empty values keeps old signatures valid when later
+// versions add fields to the record.
+func signaturePayload(record any, omit ...string) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree map[string]any
+	err = json.Unmarshal(data, &tree)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range omit {
+		delete(tree, key)
+	}
+
+	return json.Marshal(pruneEmpty(tree))
+}
+
+func pruneEmpty(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			child = pruneEmpty(child)
+			if isEmptyValue(child) {
+				delete(v, key)
+				continue
+			}
+			v[key] = child
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = pruneEmpty(child)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func isEmptyValue(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == "" || v == "0001-01-01T00:00:00Z"
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+func issuePayload(issue Issue) ([]byte, error) {
+	return signaturePayload(issue, "signature", "schema_version")
+}
+
+// commentPayload leaves out reactions, which other people add, so reacting
+// doesn't break the author's signature.
+func commentPayload(comment Comment) ([]byte, error) {
+	return signaturePayload(comment, "signature", "reactions")
+}
+
+func actionPayload(action Action) ([]byte, error) {
+	return signaturePayload(action, "signature", "schema_version")
+}
+
+type signingKey struct {
+	format string
+	email  string
+	pgp    *openpgp.Entity
+	ssh    ssh.Signer
+}
+
+func (k *signingKey) sign(payload []byte) (*Signature, error) {
+	var data string
+	switch k.format {
+	case signatureFormatSSH:
+		armored, err := sshSign(k.ssh, payload)
+		if err != nil {
+			return nil, err
+		}
+		data = armored
+	default:
+		var buf bytes.Buffer
+		err := openpgp.ArmoredDetachSign(&buf, k.pgp, bytes.NewReader(payload), nil)
+		if err != nil {
+			return nil, err
+		}
+		data = buf.String()
+	}
+
+	return &Signature{Format: k.format, Signer: k.email, Data: data}, nil
+}
+
+type allowedSigner struct {
+	principals []string
+	key        ssh.PublicKey
+}
+
+type trustedKeys struct {
+	pgp openpgp.EntityList
+	ssh []allowedSigner
+}
+
+// verify reports whether sig is a good signature of payload by a trusted key
+// that belongs to the signer it claims.
+func (t *trustedKeys) verify(payload []byte, sig *Signature) bool {
+	if t == nil || sig == nil || sig.Signer == "" {
+		return false
+	}
+
+	switch sig.Format {
+	case signatureFormatSSH:
+		key, err := sshVerify(payload, sig.Data)
+		if err != nil {
+			return false
+		}
+		for _, allowed := range t.ssh {
+			if !bytes.Equal(allowed.key.Marshal(), key.Marshal()) {
+				continue
+			}
+			for _, principal := range allowed.principals {
+				if principal == "*" || strings.EqualFold(principal, sig.Signer) {
+					return true
+				}
+			}
+		}
+		return false
+	case signatureFormatOpenPGP:
+		entity, err := openpgp.CheckArmoredDetachedSignature(t.pgp, bytes.NewReader(payload), strings.NewReader(sig.Data), nil)
+		if err != nil || entity == nil {
+			return false
+		}
+		for _, identity := range entity.Identities {
+			if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, sig.Signer) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// signingOption looks a setting up in the repository config first and then
+// in the user's global config, which the merged repository config leaves out.
+func signingOption(cfgs []*config.Config, section, subsection, key string) string {
+	for _, cfg := range cfgs {
+		if cfg == nil || cfg.Raw == nil {
+			continue
+		}
+		s := cfg.Raw.Section(section)
+		var value string
+		if subsection == "" {
+			value = s.Option(key)
+		} else {
+			value = s.Subsection(subsection).Option(key)
+		}
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// loadSigning reads the signing key and the trusted keys from git config.
+// The signing key is nil when signing isn't configured.
+func loadSigning(cfgs ...*config.Config) (*signingKey, *trustedKeys, error) {
+	var email string
+	for _, cfg := range cfgs {
+		if cfg != nil && email == "" {
+			email = cfg.User.Email
+		}
+	}
+
+	format := signatureFormatOpenPGP
+	if signingOption(cfgs, "gpg", "", "format") == signatureFormatSSH {
+		format = signatureFormatSSH
+	}
+
+	trusted := &trustedKeys{}
+	var key *signingKey
+	keyPath := signingOption(cfgs, "ubik", "", "signingKey")
+
+	switch format {
+	case signatureFormatSSH:
+		if keyPath == "" {
+			keyPath = signingOption(cfgs, "user", "", "signingkey")
+		}
+		if keyPath != "" {
+			signer, err := readSSHSigner(expandHome(keyPath))
+			if err != nil {
+				return nil, nil, err
+			}
+			key = &signingKey{format: format, email: email, ssh: signer}
+			trusted.ssh = append(trusted.ssh, allowedSigner{principals: []string{email}, key: signer.PublicKey()})
+		}
+		if path := signingOption(cfgs, "gpg", "ssh", "allowedSignersFile"); path != "" {
+			allowed, err := readAllowedSigners(expandHome(path))
+			if err != nil {
+				return nil, nil, err
+			}
+			trusted.ssh = append(trusted.ssh, allowed...)
+		}
+	default:
+		if keyPath != "" {
+			entity, err := readOpenPGPSigner(expandHome(keyPath))
+			if err != nil {
+				return nil, nil, err
+			}
+			key = &signingKey{format: format, email: email, pgp: entity}
+			trusted.pgp = append(trusted.pgp, entity)
+		}
+		if path := signingOption(cfgs, "ubik", "", "trustedKeys"); path != "" {
+			f, err := os.Open(expandHome(path))
+			if err != nil {
+				return nil, nil, err
+			}
+			defer f.Close()
+			keyring, err := openpgp.ReadArmoredKeyRing(f)
+			if err != nil {
+				return nil, nil, fmt.Errorf("reading %s: %w", path, err)
+			}
+			trusted.pgp = append(trusted.pgp, keyring...)
+		}
+	}
+
+	if key != nil && email == "" {
+		return nil, nil, errors.New("signing needs user.email to be set")
+	}
+
+	return key, trusted, nil
+}
+
+func readOpenPGPSigner(path string) (*openpgp.Entity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keyring, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	for _, entity := range keyring {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if entity.PrivateKey.Encrypted {
+			return nil, fmt.Errorf("%s: passphrase-protected keys are not supported", path)
+		}
+		return entity, nil
+	}
+
+	return nil, fmt.Errorf("%s: no secret key found", path)
+}
+
+func readSSHSigner(path string) (ssh.Signer, error) {
+	path = strings.TrimSuffix(path, ".pub")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("%s: passphrase-protected keys are not supported", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return signer, nil
+}
+
+// readAllowedSigners parses the allowed signers file format that
+// `ssh-keygen -Y verify` and git use: principals, optional options, then the
+// public key.
+func readAllowedSigners(path string) ([]allowedSigner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var signers []allowedSigner
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		rest := fields[1:]
+		if !isSSHKeyType(rest[0]) {
+			rest = rest[1:]
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.Join(rest, " ")))
+		if err != nil {
+			debug("%#v", err.Error())
+			continue
+		}
+
+		signers = append(signers, allowedSigner{principals: strings.Split(fields[0], ","), key: key})
+	}
+
+	return signers, nil
+}
+
+func isSSHKeyType(field string) bool {
+	return strings.HasPrefix(field, "ssh-") || strings.HasPrefix(field, "ecdsa-") || strings.HasPrefix(field, "sk-")
+}
+
+// SSH signatures use the SSHSIG format, so they can be checked with
+// `ssh-keygen -Y verify -n ubik`.
+const (
+	sshSigMagic     = "SSHSIG"
+	sshSigNamespace = "ubik"
+	sshSigHash      = "sha512"
+)
+
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          string
+}
+
+type sshSigBlob struct {
+	Version       uint32
+	PublicKey     string
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     string
+}
+
+func sshSignedMessage(payload []byte) []byte {
+	hash := sha512.Sum512(payload)
+	return append([]byte(sshSigMagic), ssh.Marshal(sshSignedData{
+		Namespace:     sshSigNamespace,
+		HashAlgorithm: sshSigHash,
+		Hash:          string(hash[:]),
+	})...)
+}
+
+func sshSign(signer ssh.Signer, payload []byte) (string, error) {
+	message := sshSignedMessage(payload)
+
+	var sig *ssh.Signature
+	var err error
+	if algorithmSigner, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		sig, err = algorithmSigner.SignWithAlgorithm(rand.Reader, message, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = signer.Sign(rand.Reader, message)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	blob := append([]byte(sshSigMagic), ssh.Marshal(sshSigBlob{
+		Version:       1,
+		PublicKey:     string(signer.PublicKey().Marshal()),
+		Namespace:     sshSigNamespace,
+		HashAlgorithm: sshSigHash,
+		Signature:     string(ssh.Marshal(sig)),
+	})...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var armored strings.Builder
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n")
+	armored.WriteString("-----END SSH SIGNATURE-----\n")
+
+	return armored.String(), nil
+}
+
+// sshVerify checks an armored SSHSIG signature and returns the key that
+// made it.
+func sshVerify(payload []byte, armored string) (ssh.PublicKey, error) {
+	var encoded strings.Builder
+	for _, line := range strings.Split(armored, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-----") {
+			continue
+		}
+		encoded.WriteString(line)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded.String())
+	if err != nil {
+		return nil, err
+	}
+
+	rest, ok := bytes.CutPrefix(data, []byte(sshSigMagic))
+	if !ok {
+		return nil, errors.New("not an SSH signature")
+	}
+	var blob sshSigBlob
+	err = ssh.Unmarshal(rest, &blob)
+	if err != nil {
+		return nil, err
+	}
+	if blob.Version != 1 || blob.Namespace != sshSigNamespace || blob.HashAlgorithm != sshSigHash {
+		return nil, errors.New("unsupported SSH signature")
+	}
+
+	key, err := ssh.ParsePublicKey([]byte(blob.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	var sig ssh.Signature
+	err = ssh.Unmarshal([]byte(blob.Signature), &sig)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, key.Verify(sshSignedMessage(payload), &sig)
+}
+
+// recordSigner is implemented by stores that sign what they save. Callers
+// that hand the saved record on, like persistIssue, sign it up front so the
+// copy they keep carries the signature.
+type recordSigner interface {
+	signIssue(issue Issue) (Issue, error)
+	signAction(action Action) (Action, error)
+}
+
+// signingStore signs issues, comments and actions on the way into another
+// store and verifies them on the way out.
+type signingStore struct {
+	Store
+	key     *signingKey
+	trusted *trustedKeys
+}
+
+func (s *signingStore) verifyIssue(issue Issue) Issue {
+	payload, err := issuePayload(issue)
+	issue.Verified = err == nil && s.trusted.verify(payload, issue.Signature)
+
+	issue.Comments = slices.Clone(issue.Comments)
+	for i, comment := range issue.Comments {
+		payload, err := commentPayload(comment)
+		comment.Verified = err == nil && s.trusted.verify(payload, comment.Signature) &&
+			strings.EqualFold(comment.Signature.Signer, comment.Author)
+		issue.Comments[i] = comment
+	}
+
+	return issue
+}
+
+// signIssue signs the user's own comments that aren't signed yet (or were
+// changed since) and then the issue as a whole.
+func (s *signingStore) signIssue(issue Issue) (Issue, error) {
+	if s.key == nil {
+		return s.verifyIssue(issue), nil
+	}
+
+	issue.Comments = slices.Clone(issue.Comments)
+	for i, comment := range issue.Comments {
+		if !strings.EqualFold(comment.Author, s.key.email) {
+			continue
+		}
+		payload, err := commentPayload(comment)
+		if err != nil {
+			return issue, err
+		}
+		if s.trusted.verify(payload, comment.Signature) {
+			continue
+		}
+		comment.Signature, err = s.key.sign(payload)
+		if err != nil {
+			return issue, err
+		}
+		issue.Comments[i] = comment
+	}
+
+	payload, err := issuePayload(issue)
+	if err != nil {
+		return issue, err
+	}
+	if !s.trusted.verify(payload, issue.Signature) || issue.Signature.Signer != s.key.email {
+		issue.Signature, err = s.key.sign(payload)
+		if err != nil {
+			return issue, err
+		}
+	}
+
+	return s.verifyIssue(issue), nil
+}
+
+func (s *signingStore) signAction(action Action) (Action, error) {
+	if s.key != nil {
+		payload, err := actionPayload(action)
+		if err != nil {
+			return action, err
+		}
+		action.Signature, err = s.key.sign(payload)
+		if err != nil {
+			return action, err
+		}
+	}
+
+	payload, err := actionPayload(action)
+	action.Verified = err == nil && s.trusted.verify(payload, action.Signature)
+	return action, nil
+}
+
+func (s *signingStore) Issues() ([]Issue, error) {
+	issues, err := s.Store.Issues()
+	for i, issue := range issues {
+		issues[i] = s.verifyIssue(issue)
+	}
+	return issues, err
+}
+
+func (s *signingStore) SaveIssue(issue Issue) error {
+	issue, err := s.signIssue(issue)
+	if err != nil {
+		return err
+	}
+	return s.Store.SaveIssue(issue)
+}
+
+func (s *signingStore) IssueHistory(id string) ([]IssueRevision, error) {
+	revisions, err := s.Store.IssueHistory(id)
+	for i, revision := range revisions {
+		revisions[i].Issue = s.verifyIssue(revision.Issue)
+	}
+	return revisions, err
+}
+
+func (s *signingStore) Actions() ([]Action, error) {
+	actions, err := s.Store.Actions()
+	for i, action := range actions {
+		payload, err := actionPayload(action)
+		actions[i].Verified = err == nil && s.trusted.verify(payload, action.Signature)
+	}
+	return actions, err
+}
+
+func (s *signingStore) SaveAction(action Action) error {
+	action, err := s.signAction(action)
+	if err != nil {
+		return err
+	}
+	return s.Store.SaveAction(action)
+}
+
+// withSigning wraps store so records are signed and verified according to
+// the signing configuration. A broken configuration is logged and leaves
+// records unsigned rather than stopping ubik from starting.
+func withSigning(store Store, cfg *config.Config) Store {
+	signer := loadSigner(cfg)
+	signer.Store = store
+	return signer
+}
+
+// loadSigner reads the signing configuration for code that writes records
+// without going through a store, like sync and migrate.
+func loadSigner(cfg *config.Config) *signingStore {
+	global, err := config.LoadConfig(config.GlobalScope)
+	if err != nil {
+		global = nil
+	}
+
+	key, trusted, err := loadSigning(cfg, global)
+	if err != nil {
+		debug("%#v", err.Error())
+		trusted = &trustedKeys{}
+	}
+
+	return &signingStore{key: key, trusted: trusted}
+}
+
+// resigner keeps signatures honest when sync and migrate rewrite records on
+// the user's behalf. A record whose signed content changed is signed again
+// with the user's key or, when there's no key, left unsigned rather than
+// carrying a signature that no longer matches. Dropped counts the latter so
+// the reports can say so.
+type resigner struct {
+	signer  *signingStore
+	Dropped int
+}
+
+func (r *resigner) canSign() bool {
+	return r.signer != nil && r.signer.key != nil
+}
+
+// issue gives rewritten the signature of whichever source it still matches,
+// or failing that a new one.
+func (r *resigner) issue(rewritten Issue, sources ...Issue) (Issue, error) {
+	payload, err := issuePayload(rewritten)
+	if err != nil {
+		return rewritten, err
+	}
+	for _, source := range sources {
+		sourcePayload, err := issuePayload(source)
+		if err == nil && bytes.Equal(payload, sourcePayload) {
+			rewritten.Signature = source.Signature
+			return rewritten, nil
+		}
+	}
+
+	if r.canSign() {
+		return r.signer.signIssue(rewritten)
+	}
+	if rewritten.Signature != nil {
+		rewritten.Signature = nil
+		r.Dropped++
+	}
+	return rewritten, nil
+}
+
+func (r *resigner) action(rewritten, source Action) (Action, error) {
+	payload, err := actionPayload(rewritten)
+	if err != nil {
+		return rewritten, err
+	}
+	sourcePayload, err := actionPayload(source)
+	if err == nil && bytes.Equal(payload, sourcePayload) {
+		rewritten.Signature = source.Signature
+		return rewritten, nil
+	}
+
+	if r.canSign() {
+		return r.signer.signAction(rewritten)
+	}
+	if rewritten.Signature != nil {
+		rewritten.Signature = nil
+		r.Dropped++
+	}
+	return rewritten, nil
+}
+
+func verificationBadge(verified bool, sig *Signature) string {
+	if verified {
+		return lipgloss.NewStyle().Foreground(styles.Theme.GreenText).Render("✓ verified") +
+			lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render(fmt.Sprintf(" (%s)", sig.Signer))
+	}
+	return lipgloss.NewStyle().Foreground(styles.Theme.YellowText).Render("unverified")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func writeSSHKey(t *testing.T, dir, name string) (string, ssh.PublicKey) {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(private, "")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0o600))
+	signer, err := ssh.NewSignerFromKey(private)
+	require.NoError(t, err)
+	return path, signer.PublicKey()
+}
+
+func sshSigningConfig(t *testing.T, email string) (*config.Config, string, ssh.PublicKey) {
+	t.Helper()
+	dir := t.TempDir()
+	keyPath, public := writeSSHKey(t, dir, "id_ed25519")
+
+	cfg := testGitConfig(email)
+	cfg.Raw.Section("gpg").SetOption("format", "ssh")
+	cfg.Raw.Section("user").SetOption("signingkey", keyPath+".pub")
+	return cfg, dir, public
+}
+
+func openPGPSigningConfig(t *testing.T, email string) *config.Config {
+	t.Helper()
+	entity, err := openpgp.NewEntity("Test", "", email, nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+
+	path := filepath.Join(t.TempDir(), "secret.asc")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	cfg := testGitConfig(email)
+	cfg.Raw.Section("ubik").SetOption("signingKey", path)
+	return cfg
+}
+
+func newSigningStore(t *testing.T, inner Store, cfg *config.Config) *signingStore {
+	t.Helper()
+	key, trusted, err := loadSigning(cfg)
+	require.NoError(t, err)
+	require.NotNil(t, key)
+	return &signingStore{Store: inner, key: key, trusted: trusted}
+}
+
+func TestSigningStore(t *testing.T) {
+	sshConfig, _, _ := sshSigningConfig(t, "alice@example.com")
+	configs := map[string]*config.Config{
+		"ssh":     sshConfig,
+		"openpgp": openPGPSigningConfig(t, "alice@example.com"),
+	}
+
+	for name, cfg := range configs {
+		t.Run(name, func(t *testing.T) {
+			inner := newMemoryStore()
+			store := newSigningStore(t, inner, cfg)
+
+			created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			issue := Issue{
+				Id:     "one",
+				Title:  "Signed",
+				Author: "alice@example.com",
+				Comments: []Comment{
+					{Author: "alice@example.com", Content: "mine", CreatedAt: created},
+					{Author: "bob@example.com", Content: "not mine", CreatedAt: created},
+				},
+			}
+			require.NoError(t, store.SaveIssue(issue))
+
+			issues, err := store.Issues()
+			require.NoError(t, err)
+			require.Len(t, issues, 1)
+			assert.True(t, issues[0].Verified)
+			assert.Equal(t, name, issues[0].Signature.Format)
+			assert.True(t, issues[0].Comments[0].Verified)
+			assert.False(t, issues[0].Comments[1].Verified, "someone else's comment isn't signed for them")
+
+			forged := storedIssue(t, inner, "one")
+			forged.Author = "mallory@example.com"
+			forged.Comments[0].Content = "forged"
+			require.NoError(t, inner.SaveIssue(forged))
+
+			issues, err = store.Issues()
+			require.NoError(t, err)
+			assert.False(t, issues[0].Verified)
+			assert.False(t, issues[0].Comments[0].Verified)
+
+			require.NoError(t, store.SaveAction(Action{Id: "a1", Status: succeeded}))
+			actions, err := store.Actions()
+			require.NoError(t, err)
+			require.Len(t, actions, 1)
+			assert.True(t, actions[0].Verified)
+		})
+	}
+}
+
+func TestSigningStoreUntrustedKey(t *testing.T) {
+	aliceConfig, _, alicePublic := sshSigningConfig(t, "alice@example.com")
+	bobConfig, bobDir, _ := sshSigningConfig(t, "bob@example.com")
+
+	inner := newMemoryStore()
+	alice := newSigningStore(t, inner, aliceConfig)
+	require.NoError(t, alice.SaveIssue(Issue{Id: "one", Title: "From alice"}))
+
+	bob := newSigningStore(t, inner, bobConfig)
+	issues, err := bob.Issues()
+	require.NoError(t, err)
+	assert.False(t, issues[0].Verified, "alice's key isn't trusted by bob yet")
+
+	allowed := filepath.Join(bobDir, "allowed_signers")
+	line := "alice@example.com namespaces=\"ubik\" " + string(ssh.MarshalAuthorizedKey(alicePublic))
+	require.NoError(t, os.WriteFile(allowed, []byte(line), 0o600))
+	bobConfig.Raw.Section("gpg").Subsection("ssh").SetOption("allowedSignersFile", allowed)
+
+	bob = newSigningStore(t, inner, bobConfig)
+	issues, err = bob.Issues()
+	require.NoError(t, err)
+	assert.True(t, issues[0].Verified)
+
+	impostor := issues[0]
+	impostor.Signature = &Signature{Format: impostor.Signature.Format, Signer: "carol@example.com", Data: impostor.Signature.Data}
+	payload, err := issuePayload(impostor)
+	require.NoError(t, err)
+	assert.False(t, bob.trusted.verify(payload, impostor.Signature), "the key must belong to the claimed signer")
+}
+
+func TestSSHSignatureMatchesSSHKeygen(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not installed")
+	}
+
+	dir := t.TempDir()
+	keyPath, public := writeSSHKey(t, dir, "id_ed25519")
+	signer, err := readSSHSigner(keyPath)
+	require.NoError(t, err)
+
+	payload := []byte("payload")
+	armored, err := sshSign(signer, payload)
+	require.NoError(t, err)
+
+	allowed := filepath.Join(dir, "allowed_signers")
+	require.NoError(t, os.WriteFile(allowed, append([]byte("alice@example.com "), ssh.MarshalAuthorizedKey(public)...), 0o600))
+	sigPath := filepath.Join(dir, "payload.sig")
+	require.NoError(t, os.WriteFile(sigPath, []byte(armored), 0o600))
+
+	cmd := exec.Command("ssh-keygen", "-Y", "verify", "-f", allowed, "-I", "alice@example.com", "-n", sshSigNamespace, "-s", sigPath)
+	cmd.Stdin = bytes.NewReader(payload)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
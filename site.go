@@ -0,0 +1,711 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// `ubik site build <dir>` renders the tracker as static HTML for people
+// without a terminal: an index of issues that can be filtered, a page per
+// issue and a list of commits with their action runs. Each page carries its
+// own styles and script, so the directory can be opened from disk or served
+// by anything.
+//
+//	<dir>/index.html
+//	<dir>/commits.html
+//	<dir>/issues/<shortcode>.html
+//	<dir>/attachments/<hash>/<name>
+
+type siteReport struct {
+	Issues      int
+	Commits     int
+	Attachments int
+	Dir         string
+}
+
+func (r siteReport) String() string {
+	return fmt.Sprintf("built %d issue page(s), %d commit(s) and %d attachment(s) in %s", r.Issues, r.Commits, r.Attachments, r.Dir)
+}
+
+// buildSite writes the site for linked issues and commits into dir, with
+// statuses and custom fields as the workflow w and schema define them. It
+// only adds and overwrites files, so pages of issues deleted since the last
+// build stay until dir is cleared.
+func buildSite(dir, title string, issues []Issue, commits []Commit, attachments AttachmentStore, w Workflow, schema FieldSchema, builtAt time.Time) (siteReport, error) {
+	report := siteReport{Dir: dir}
+	for _, sub := range []string{"issues", "attachments"} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0o755)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	page := sitePage{Title: title, BuiltAt: builtAt.Format("2006-01-02 15:04 MST"), Theme: siteThemeCSS()}
+	for _, issue := range issues {
+		page.Issues = append(page.Issues, newSiteIssue(issue, w, schema))
+	}
+	for _, commit := range commits {
+		page.Commits = append(page.Commits, newSiteCommit(commit, w))
+	}
+	for _, status := range w.Statuses {
+		page.Statuses = append(page.Statuses, status.Name)
+	}
+
+	err := writeSitePage(filepath.Join(dir, "index.html"), "index", page)
+	if err != nil {
+		return report, err
+	}
+	commitsPage := page
+	commitsPage.Active = "commits"
+	err = writeSitePage(filepath.Join(dir, "commits.html"), "commits", commitsPage)
+	if err != nil {
+		return report, err
+	}
+	report.Commits = len(page.Commits)
+
+	for i, issue := range issues {
+		for _, attachment := range issueAttachments(issue) {
+			written, err := writeSiteAttachment(dir, attachment, attachments)
+			if err != nil {
+				return report, err
+			}
+			if written {
+				report.Attachments++
+			}
+		}
+
+		issuePage := page
+		issuePage.Root = "../"
+		issuePage.Issue = &page.Issues[i]
+		err := writeSitePage(filepath.Join(dir, "issues", issue.Shortcode+".html"), "issue", issuePage)
+		if err != nil {
+			return report, err
+		}
+		report.Issues++
+	}
+
+	return report, nil
+}
+
+func issueAttachments(issue Issue) []Attachment {
+	attachments := slices.Clone(issue.Attachments)
+	for _, comment := range issue.Comments {
+		if !comment.Deleted {
+			attachments = append(attachments, comment.Attachments...)
+		}
+	}
+	return attachments
+}
+
+// writeSiteAttachment copies an attachment out of the store, unless an
+// earlier build already did.
+func writeSiteAttachment(dir string, attachment Attachment, store AttachmentStore) (bool, error) {
+	path := filepath.Join(dir, attachmentHref(attachment))
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	data, err := store.ReadAttachment(attachment.Hash)
+	if err != nil {
+		return false, fmt.Errorf("attachment %s: %w", attachment.Name, err)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(path, data, 0o644)
+}
+
+// attachmentHref is where an attachment goes, relative to the site's root;
+// issue pages link to it from a level down.
+func attachmentHref(attachment Attachment) string {
+	return filepath.ToSlash(filepath.Join("attachments", attachment.Hash, filepath.Base(attachment.Name)))
+}
+
+func writeSitePage(path, name string, page sitePage) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = siteTemplates.ExecuteTemplate(file, name, page)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return file.Close()
+}
+
+type sitePage struct {
+	Title   string
+	BuiltAt string
+	Theme   template.CSS
+	// Root leads from the page back to the top of the site
+	Root     string
+	Active   string
+	Statuses []issueStatus
+	Issues   []siteIssue
+	Commits  []siteCommit
+	Issue    *siteIssue
+}
+
+type siteStatus struct {
+	Name   string
+	Icon   string
+	Color  template.CSS
+	Closed bool
+}
+
+func newSiteStatus(status issueStatus, w Workflow) siteStatus {
+	return siteStatus{
+		Name:   string(status),
+		Icon:   w.status(status).Icon,
+		Color:  siteColor(w.color(status)),
+		Closed: w.isClosed(status),
+	}
+}
+
+func newSiteActionStatus(status ActionStatus) siteStatus {
+	return siteStatus{Name: string(status), Icon: stripANSI(status.Icon()), Color: siteColor(status.color())}
+}
+
+type siteIssue struct {
+	Shortcode   string
+	Title       string
+	Status      siteStatus
+	Author      string
+	Labels      []string
+	Assignees   []string
+	Milestone   string
+	Priority    string
+	Due         string
+	Fields      [][2]string
+	Imported    string
+	Opened      string
+	Updated     string
+	Description template.HTML
+	Attachments []siteAttachment
+	Related     []siteLink
+	Commits     []siteLink
+	Comments    []siteComment
+	// Search is what the index's filter box matches plain words against
+	Search string
+}
+
+type siteLink struct {
+	Kind   string
+	Href   string
+	Ref    string
+	Title  string
+	Status siteStatus
+}
+
+type siteAttachment struct {
+	Name string
+	Href string
+	Size string
+}
+
+type siteComment struct {
+	Author      string
+	At          string
+	Reply       bool
+	Edited      bool
+	Deleted     bool
+	Body        template.HTML
+	Reactions   string
+	Attachments []siteAttachment
+}
+
+func newSiteIssue(issue Issue, w Workflow, schema FieldSchema) siteIssue {
+	site := siteIssue{
+		Shortcode:   issue.Shortcode,
+		Title:       issue.Title,
+		Status:      newSiteStatus(issue.Status, w),
+		Author:      issue.Author,
+		Labels:      issue.Labels,
+		Assignees:   issue.Assignees,
+		Milestone:   issue.MilestoneTitle,
+		Due:         formatDueDate(issue.DueDate),
+		Opened:      issue.CreatedAt.Format(time.DateOnly),
+		Updated:     issue.UpdatedAt.Format(time.DateOnly),
+		Description: markdownHTML(issue.Description),
+		Attachments: siteAttachments(issue.Attachments),
+		Search:      strings.ToLower(strings.Join([]string{issue.Shortcode, issue.Title, strings.Join(issue.Labels, " "), string(issue.Status)}, " ")),
+	}
+	if issue.Priority != noPriority {
+		site.Priority = string(issue.Priority)
+	}
+	for _, field := range schema.Fields {
+		if value := issue.Fields[field.Name]; value != "" {
+			site.Fields = append(site.Fields, [2]string{field.Name, value})
+		}
+	}
+	if issue.Imported != nil {
+		site.Imported = issue.Imported.String()
+	}
+	for _, related := range issue.RelatedIssues {
+		site.Related = append(site.Related, siteLink{
+			Kind:   related.Kind.PrettyString(),
+			Href:   related.Shortcode + ".html",
+			Ref:    "#" + related.Shortcode,
+			Title:  related.Title,
+			Status: newSiteStatus(related.Status, w),
+		})
+	}
+	for _, link := range issue.LinkedCommits {
+		kind := "referenced in"
+		if link.Fixes {
+			kind = "fixed by"
+		}
+		site.Commits = append(site.Commits, siteLink{
+			Kind:  kind,
+			Href:  "../commits.html#" + link.CommitHash,
+			Ref:   link.CommitHash[:min(8, len(link.CommitHash))],
+			Title: link.CommitSummary,
+		})
+	}
+
+	for _, threaded := range threadComments(issue.Comments) {
+		comment := issue.Comments[threaded.index]
+		site.Comments = append(site.Comments, siteComment{
+			Author:      comment.Author,
+			At:          comment.CreatedAt.Format("2006-01-02 15:04"),
+			Reply:       threaded.reply,
+			Edited:      comment.Edited,
+			Deleted:     comment.Deleted,
+			Body:        markdownHTML(comment.Content),
+			Reactions:   stripANSI(renderReactions(comment.Reactions)),
+			Attachments: siteAttachments(comment.Attachments),
+		})
+	}
+
+	return site
+}
+
+func siteAttachments(attachments []Attachment) []siteAttachment {
+	return convertSlice(attachments, func(a Attachment) siteAttachment {
+		return siteAttachment{Name: a.Name, Href: "../" + attachmentHref(a), Size: formatBytes(a.Size)}
+	})
+}
+
+type siteCommit struct {
+	Hash    string
+	Short   string
+	Summary string
+	Message string
+	Author  string
+	At      string
+	Status  *siteStatus
+	Issues  []siteLink
+	Actions []siteAction
+}
+
+type siteAction struct {
+	Name     string
+	Status   siteStatus
+	Optional bool
+	Duration string
+	Output   string
+}
+
+func newSiteCommit(commit Commit, w Workflow) siteCommit {
+	summary, body, _ := strings.Cut(commit.Message, "\n")
+	site := siteCommit{
+		Hash:    commit.Hash,
+		Short:   commit.AbbreviatedHash,
+		Summary: summary,
+		Message: strings.TrimSpace(body),
+		Author:  cmp.Or(commit.AuthorName, commit.AuthorEmail),
+		At:      commit.Timestamp.Format("2006-01-02 15:04"),
+	}
+	if status := commit.AggregateActionStatus(); status != "" {
+		aggregate := newSiteActionStatus(status)
+		site.Status = &aggregate
+	}
+	for _, link := range commit.ReferencedIssues {
+		kind := "refs"
+		if link.Fixes {
+			kind = "fixes"
+		}
+		site.Issues = append(site.Issues, siteLink{
+			Kind:   kind,
+			Href:   "issues/" + link.Shortcode + ".html",
+			Ref:    "#" + link.Shortcode,
+			Title:  link.Title,
+			Status: newSiteStatus(link.Status, w),
+		})
+	}
+	for _, action := range commit.LatestActions {
+		var duration string
+		if !action.FinishedAt.IsZero() {
+			duration = action.FinishedAt.Sub(action.StartedAt).Round(time.Millisecond).String()
+		}
+		site.Actions = append(site.Actions, siteAction{
+			Name:     action.Name,
+			Status:   newSiteActionStatus(action.Status),
+			Optional: action.Optional,
+			Duration: duration,
+			Output:   stripANSI(action.Output),
+		})
+	}
+	return site
+}
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07]*\x07`)
+
+// stripANSI removes terminal colors and the like, e.g. from action output.
+func stripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// siteColor turns a theme color into CSS. The theme's own colors become the
+// variables siteThemeCSS defines, so they follow the reader's light or dark
+// preference.
+func siteColor(color lipgloss.TerminalColor) template.CSS {
+	for _, themeColor := range siteThemeColors() {
+		if themeColor.color == color {
+			return template.CSS(fmt.Sprintf("var(--%s)", themeColor.name))
+		}
+	}
+	switch color := color.(type) {
+	case lipgloss.AdaptiveColor:
+		return template.CSS(ansiToHex(color.Light))
+	case lipgloss.Color:
+		return template.CSS(ansiToHex(string(color)))
+	default:
+		return "inherit"
+	}
+}
+
+type siteThemeColor struct {
+	name  string
+	color lipgloss.AdaptiveColor
+}
+
+func siteThemeColors() []siteThemeColor {
+	theme := styles.Theme
+	return []siteThemeColor{
+		{"selected-background", theme.SelectedBackground},
+		{"primary-border", theme.PrimaryBorder},
+		{"faint-border", theme.FaintBorder},
+		{"secondary-border", theme.SecondaryBorder},
+		{"faint-text", theme.FaintText},
+		{"primary-text", theme.PrimaryText},
+		{"secondary-text", theme.SecondaryText},
+		{"inverted-text", theme.InvertedText},
+		{"green-text", theme.GreenText},
+		{"yellow-text", theme.YellowText},
+		{"red-text", theme.RedText},
+	}
+}
+
+// siteThemeCSS defines a CSS variable for each theme color, with its light
+// and dark values. The page background is the theme's inverted text color,
+// which is the terminal's background by design.
+func siteThemeCSS() template.CSS {
+	var light, dark strings.Builder
+	for _, c := range siteThemeColors() {
+		light.WriteString(fmt.Sprintf("--%s: %s; ", c.name, ansiToHex(c.color.Light)))
+		dark.WriteString(fmt.Sprintf("--%s: %s; ", c.name, ansiToHex(c.color.Dark)))
+	}
+	return template.CSS(fmt.Sprintf(
+		":root { %s--background: %s; }\n@media (prefers-color-scheme: dark) { :root { %s--background: %s; } }",
+		light.String(), ansiToHex(styles.Theme.InvertedText.Light), dark.String(), ansiToHex(styles.Theme.InvertedText.Dark),
+	))
+}
+
+// siteMarkdown renders the Markdown of descriptions and comments as HTML.
+// It's goldmark with GitHub's extensions, the parser glamour uses to render
+// them in the terminal. Line breaks are kept, and raw HTML is shown as
+// written rather than dropped.
+var siteMarkdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(siteMarkdownTransformer{}, 100))),
+	goldmark.WithRendererOptions(
+		goldmarkhtml.WithHardWraps(),
+		renderer.WithNodeRenderers(util.Prioritized(escapedHTMLRenderer{}, 100)),
+	),
+)
+
+func markdownHTML(source string) template.HTML {
+	var s strings.Builder
+	err := siteMarkdown.Convert([]byte(source), &s)
+	if err != nil {
+		return template.HTML("<p>" + html.EscapeString(source) + "</p>\n")
+	}
+	return template.HTML(s.String())
+}
+
+// siteMarkdownTransformer moves headings down two levels, since the page
+// title is the h1 and sections are h2, and turns links that aren't safe (see
+// safeLink) into their text.
+type siteMarkdownTransformer struct{}
+
+func (siteMarkdownTransformer) Transform(document *ast.Document, _ text.Reader, _ parser.Context) {
+	var unsafe []*ast.Link
+	_ = ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := node.(type) {
+		case *ast.Heading:
+			node.Level = min(node.Level+2, 6)
+		case *ast.Link:
+			if !safeLink(string(node.Destination)) {
+				unsafe = append(unsafe, node)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, link := range unsafe {
+		parent := link.Parent()
+		for child := link.FirstChild(); child != nil; child = link.FirstChild() {
+			parent.InsertBefore(parent, link, child)
+		}
+		parent.RemoveChild(parent, link)
+	}
+}
+
+// escapedHTMLRenderer renders raw HTML in the Markdown as text, so that
+// "Crash <on> start" reads as written.
+type escapedHTMLRenderer struct{}
+
+func (escapedHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindRawHTML, func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			segments := node.(*ast.RawHTML).Segments
+			for i := 0; i < segments.Len(); i++ {
+				segment := segments.At(i)
+				_, _ = w.Write(util.EscapeHTML(segment.Value(source)))
+			}
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	reg.Register(ast.KindHTMLBlock, func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		block := node.(*ast.HTMLBlock)
+		var lines []string
+		for i := 0; i < block.Lines().Len(); i++ {
+			line := block.Lines().At(i)
+			lines = append(lines, string(util.EscapeHTML(bytes.TrimSpace(line.Value(source)))))
+		}
+		if block.HasClosure() {
+			lines = append(lines, string(util.EscapeHTML(bytes.TrimSpace(block.ClosureLine.Value(source)))))
+		}
+		_, _ = w.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// safeLink allows links to web pages, email and relative paths, and not
+// javascript: and the like.
+func safeLink(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	return slices.Contains([]string{"", "http", "https", "mailto"}, strings.ToLower(u.Scheme))
+}
+
+var siteTemplates = template.Must(template.New("site").Parse(`
+{{define "head"}}<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.}}</title>
+{{end}}
+
+{{define "style"}}<style>
+{{.Theme}}
+body { margin: 0 auto; max-width: 60rem; padding: 1rem 2rem 3rem; background: var(--background); color: var(--primary-text); font: 15px/1.5 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+a { color: inherit; }
+nav { display: flex; gap: 1.5rem; align-items: baseline; border-bottom: 1px solid var(--faint-text); padding-bottom: .5rem; }
+nav .tab { text-decoration: none; color: var(--secondary-text); padding: .1rem .5rem; border: 1px solid transparent; }
+nav .tab.active { color: var(--primary-text); border-color: var(--primary-border); }
+nav .built { margin-left: auto; color: var(--faint-text); font-size: .85em; }
+h1 { font-size: 1.4em; margin: 1.5rem 0 .25rem; }
+h2 { font-size: 1.1em; margin: 2rem 0 .5rem; color: var(--secondary-text); }
+.faint { color: var(--faint-text); }
+.secondary { color: var(--secondary-text); }
+.status { white-space: pre; }
+.labels { color: var(--faint-text); }
+.filters { display: flex; gap: .5rem; margin: 1rem 0; }
+.filters input, .filters select { font: inherit; color: inherit; background: transparent; border: 1px solid var(--secondary-border); padding: .25rem .5rem; }
+.filters input { flex: 1; }
+ul.items { list-style: none; padding: 0; margin: 0; }
+ul.items > li { padding: .4rem .5rem; border-bottom: 1px solid var(--faint-border); }
+ul.items > li:target { background: var(--selected-background); }
+.meta { color: var(--secondary-text); font-size: .9em; }
+dl.details { display: grid; grid-template-columns: max-content auto; gap: .1rem 1rem; margin: 1rem 0; }
+dl.details dt { color: var(--faint-text); }
+dl.details dd { margin: 0; }
+.markdown pre, pre.output { border-left: 2px solid var(--faint-text); padding: .25rem .75rem; overflow-x: auto; white-space: pre; }
+.markdown blockquote { margin: 0; padding-left: .75rem; border-left: 2px solid var(--faint-text); color: var(--secondary-text); }
+.markdown code { color: var(--yellow-text); }
+.markdown input[type=checkbox] { accent-color: var(--green-text); margin: 0 .25rem 0 0; }
+.markdown table { border-collapse: collapse; }
+.markdown th, .markdown td { border: 1px solid var(--faint-text); padding: .1rem .5rem; }
+.comment { border: 1px solid var(--secondary-border); margin: 1rem 0; padding: 0 .75rem .5rem; }
+.comment.reply { margin-left: 2.5rem; }
+.comment header { border-bottom: 1px solid var(--secondary-border); padding: .4rem 0; margin-bottom: .5rem; }
+.comment.deleted { color: var(--faint-text); font-style: italic; }
+details.action { margin: .25rem 0 .25rem 1.5rem; }
+details.action summary { cursor: pointer; }
+.empty { color: var(--faint-text); margin: 2rem 0; }
+</style>
+</head>
+{{end}}
+
+{{define "nav"}}<nav>
+<strong>{{.Title}}</strong>
+<a class="tab{{if ne .Active "commits"}} active{{end}}" href="{{.Root}}index.html">Issues</a>
+<a class="tab{{if eq .Active "commits"}} active{{end}}" href="{{.Root}}commits.html">Actions</a>
+<span class="built">built {{.BuiltAt}}</span>
+</nav>
+{{end}}
+
+{{define "status"}}<span class="status" style="color: {{.Color}}" title="{{.Name}}">{{.Icon}}</span>{{end}}
+
+{{define "attachments"}}{{if .}}<ul class="attachments">{{range .}}<li><a href="{{.Href}}">{{.Name}}</a> <span class="faint">{{.Size}}</span></li>{{end}}</ul>{{end}}{{end}}
+
+{{define "index"}}{{template "head" .Title}}{{template "style" .}}
+<body>
+{{template "nav" .}}
+<div class="filters">
+<input id="search" type="search" placeholder="search: words, label:bug, status:todo, assignee:…, milestone:…, priority:…" autofocus>
+<select id="state">
+<option value="open">open</option>
+<option value="closed">closed</option>
+<option value="">all</option>
+{{range .Statuses}}<option value="status:{{.}}">{{.}}</option>{{end}}
+</select>
+</div>
+{{if .Issues}}<ul class="items" id="issues">
+{{range .Issues}}<li data-search="{{.Search}}" data-status="{{.Status.Name}}" data-closed="{{.Status.Closed}}" data-labels="{{range .Labels}} {{.}}{{end}} " data-assignees="{{range .Assignees}} {{.}}{{end}} " data-milestone="{{.Milestone}}" data-priority="{{.Priority}}">
+{{template "status" .Status}} <a href="issues/{{.Shortcode}}.html">{{.Title}}</a>{{if .Priority}} <span class="secondary">{{.Priority}}</span>{{end}} <span class="labels">{{range $i, $l := .Labels}}{{if $i}},{{end}}{{$l}}{{end}}</span>
+<div class="meta">#{{.Shortcode}} opened by {{.Author}} on {{.Opened}}{{if .Assignees}} <span class="faint">→ {{range $i, $a := .Assignees}}{{if $i}}, {{end}}{{$a}}{{end}}</span>{{end}}{{if .Due}} <span class="faint">due {{.Due}}</span>{{end}}</div>
+</li>
+{{end}}</ul>
+<p class="empty" id="none" hidden>No issues match.</p>
+{{else}}<p class="empty">No issues yet.</p>{{end}}
+<script>
+(function () {
+  var search = document.getElementById("search");
+  var state = document.getElementById("state");
+  var items = Array.prototype.slice.call(document.querySelectorAll("#issues > li"));
+  var fields = { "label:": "labels", "assignee:": "assignees", "milestone:": "milestone", "priority:": "priority", "status:": "status" };
+
+  function matches(item, term) {
+    for (var prefix in fields) {
+      if (term.indexOf(prefix) === 0) {
+        var want = term.slice(prefix.length);
+        var have = (item.dataset[fields[prefix]] || "").toLowerCase();
+        return prefix === "status:" || prefix === "priority:" ? have === want : have.indexOf(want) >= 0;
+      }
+    }
+    return item.dataset.search.indexOf(term) >= 0;
+  }
+
+  function filter() {
+    var terms = search.value.toLowerCase().split(/\s+/).filter(Boolean);
+    if (state.value.indexOf("status:") === 0) terms.push(state.value);
+    var shown = 0;
+    items.forEach(function (item) {
+      var closed = item.dataset.closed === "true";
+      var visible = (state.value !== "open" || !closed) && (state.value !== "closed" || closed) &&
+        terms.every(function (term) { return matches(item, term); });
+      item.hidden = !visible;
+      if (visible) shown++;
+    });
+    var none = document.getElementById("none");
+    if (none) none.hidden = shown > 0;
+    history.replaceState(null, "", "#" + encodeURIComponent(search.value) + "|" + encodeURIComponent(state.value));
+  }
+
+  var saved = decodeURIComponent(location.hash.slice(1)).split("|");
+  if (saved.length === 2) { search.value = saved[0]; state.value = saved[1]; }
+  search.addEventListener("input", filter);
+  state.addEventListener("change", filter);
+  filter();
+})();
+</script>
+</body>
+</html>
+{{end}}
+
+{{define "issue"}}{{with .Issue}}{{template "head" (printf "#%s %s" .Shortcode .Title)}}{{end}}{{template "style" .}}
+<body>
+{{template "nav" .}}
+{{with .Issue}}
+<h1>{{template "status" .Status}} {{.Title}} <span class="secondary">#{{.Shortcode}}</span></h1>
+<div class="meta">opened by {{.Author}} on {{.Opened}}, updated {{.Updated}}</div>
+<dl class="details">
+<dt>Status</dt><dd style="color: {{.Status.Color}}">{{.Status.Name}}</dd>
+{{if .Labels}}<dt>Labels</dt><dd>{{range $i, $l := .Labels}}{{if $i}}, {{end}}<a href="../index.html#label%3A{{$l}}|">{{$l}}</a>{{end}}</dd>{{end}}
+{{if .Assignees}}<dt>Assignees</dt><dd>{{range $i, $a := .Assignees}}{{if $i}}, {{end}}{{$a}}{{end}}</dd>{{end}}
+{{if .Milestone}}<dt>Milestone</dt><dd>{{.Milestone}}</dd>{{end}}
+{{if .Priority}}<dt>Priority</dt><dd>{{.Priority}}</dd>{{end}}
+{{if .Due}}<dt>Due</dt><dd>{{.Due}}</dd>{{end}}
+{{range .Fields}}<dt>{{index . 0}}</dt><dd>{{index . 1}}</dd>{{end}}
+{{if .Imported}}<dt>Imported from</dt><dd>{{.Imported}}</dd>{{end}}
+</dl>
+<div class="markdown">{{.Description}}</div>
+{{template "attachments" .Attachments}}
+{{if .Related}}<h2>Relationships</h2>
+<ul class="items">{{range .Related}}<li><span class="faint">{{.Kind}}</span> {{template "status" .Status}} <a href="{{.Href}}">{{.Ref}}</a> {{.Title}}</li>{{end}}</ul>{{end}}
+{{if .Commits}}<h2>Commits</h2>
+<ul class="items">{{range .Commits}}<li><span class="faint">{{.Kind}}</span> <a href="{{.Href}}">{{.Ref}}</a> {{.Title}}</li>{{end}}</ul>{{end}}
+<h2>Comments</h2>
+{{range .Comments}}{{if .Deleted}}<div class="comment deleted{{if .Reply}} reply{{end}}"><header>{{.Author}} · {{.At}}</header>comment deleted</div>
+{{else}}<div class="comment{{if .Reply}} reply{{end}}">
+<header>{{.Author}} {{if .Reply}}replied{{else}}commented{{end}} at {{.At}}{{if .Edited}} <span class="faint">(edited)</span>{{end}}</header>
+<div class="markdown">{{.Body}}</div>
+{{template "attachments" .Attachments}}
+{{if .Reactions}}<div class="secondary">{{.Reactions}}</div>{{end}}
+</div>
+{{end}}{{else}}<p class="empty">No comments.</p>{{end}}
+{{end}}
+</body>
+</html>
+{{end}}
+
+{{define "commits"}}{{template "head" (printf "%s commits" .Title)}}{{template "style" .}}
+<body>
+{{template "nav" .}}
+{{if .Commits}}<ul class="items">
+{{range .Commits}}<li id="{{.Hash}}">
+{{with .Status}}{{template "status" .}}{{else}}<span class="status faint">[ ]</span>{{end}} <a href="#{{.Hash}}">{{.Short}}</a> {{.Summary}}
+<div class="meta">{{.Author}} on {{.At}}{{range .Issues}} · <span class="faint">{{.Kind}}</span> {{template "status" .Status}} <a href="{{.Href}}">{{.Ref}}</a>{{end}}</div>
+{{range .Actions}}<details class="action"><summary>{{template "status" .Status}} {{.Name}}{{if .Optional}} <span class="faint">(optional)</span>{{end}}{{if .Duration}} <span class="faint">{{.Duration}}</span>{{end}}</summary>
+<pre class="output">{{if .Output}}{{.Output}}{{else}}no output{{end}}</pre>
+</details>{{end}}
+</li>
+{{end}}</ul>
+{{else}}<p class="empty">No commits yet.</p>{{end}}
+</body>
+</html>
+{{end}}
+`))
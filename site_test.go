@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdownHTML(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"Some **bold** <b>", "<p>Some <strong>bold</strong> &lt;b&gt;</p>\n"},
+		{"# Title", "<h3>Title</h3>\n"},
+		{"- [ ] todo\n- [x] done", "<ul>\n<li><input disabled=\"\" type=\"checkbox\"> todo</li>\n<li><input checked=\"\" disabled=\"\" type=\"checkbox\"> done</li>\n</ul>\n"},
+		{"1. one\n2. two", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+		{"```go\na < b\n```", "<pre><code class=\"language-go\">a &lt; b\n</code></pre>\n"},
+		{"<div onclick=\"x()\">\nhi\n</div>", "<p>&lt;div onclick=&quot;x()&quot;&gt;<br>\nhi<br>\n&lt;/div&gt;</p>\n"},
+		{"one\ntwo", "<p>one<br>\ntwo</p>\n"},
+		{"> quoted", "<blockquote>\n<p>quoted</p>\n</blockquote>\n"},
+		{"[docs](https://example.com) and `x<y`", "<p><a href=\"https://example.com\">docs</a> and <code>x&lt;y</code></p>\n"},
+		{"[click](javascript:alert)", "<p>click</p>\n"},
+		{"snake_case_name", "<p>snake_case_name</p>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			assert.Equal(t, tt.expected, string(markdownHTML(tt.source)))
+		})
+	}
+}
+
+func TestBuildSite(t *testing.T) {
+	store := newMemoryStore()
+	hash, err := store.SaveAttachment([]byte("log line"))
+	require.NoError(t, err)
+
+	crash := testIssue("one", "Crash <on> start")
+	crash.Labels = []string{"bug"}
+	crash.Description = "It **crashes**."
+	crash.Attachments = []Attachment{{Name: "crash.log", Size: 8, Hash: hash}}
+	crash.Comments = []Comment{
+		{Author: "alice@example.com", Content: "Same here", CreatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{Author: "bob@example.com", Deleted: true, CreatedAt: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	fixed := testIssue("two", "Old bug")
+	fixed.Status = done
+
+	commitHash := "0123456789abcdef0123456789abcdef01234567"
+	commits := []Commit{{
+		Hash:            commitHash,
+		AbbreviatedHash: commitHash[:8],
+		AuthorEmail:     "bob@example.com",
+		Message:         "Handle missing config\n\nfixes #" + crash.Shortcode,
+		IssueRefs:       parseIssueRefs("fixes #" + crash.Shortcode),
+		LatestActions: []Action{
+			{Name: "Tests", Status: failed, Output: "\x1b[31mFAIL\x1b[0m TestConfig"},
+			{Name: "Lint", Status: succeeded, Optional: true},
+		},
+	}}
+	issues, commits := linkCommits(linkRelations([]Issue{crash, fixed}), commits)
+
+	dir := t.TempDir()
+	report, err := buildSite(dir, "acme", issues, commits, store, defaultWorkflow(), FieldSchema{}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, siteReport{Issues: 2, Commits: 1, Attachments: 1, Dir: dir}, report)
+
+	read := func(path string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	index := read("index.html")
+	assert.Contains(t, index, `href="issues/`+crash.Shortcode+`.html">Crash &lt;on&gt; start</a>`)
+	assert.Contains(t, index, `data-closed="true"`)
+	assert.Contains(t, index, "--green-text: #3B875E")
+	assert.Contains(t, index, `<option value="status:in-progress">`)
+
+	page := read(filepath.Join("issues", crash.Shortcode+".html"))
+	assert.Contains(t, page, "It <strong>crashes</strong>.")
+	assert.Contains(t, page, `<a href="../attachments/`+hash+`/crash.log">crash.log</a>`)
+	assert.Contains(t, page, "alice@example.com commented at 2024-01-02 00:00")
+	assert.Contains(t, page, "comment deleted")
+	assert.Contains(t, page, `<a href="../commits.html#`+commitHash+`">01234567</a> Handle missing config`)
+	assert.Equal(t, "log line", read(filepath.Join("attachments", hash, "crash.log")))
+
+	commitsPage := read("commits.html")
+	assert.Contains(t, commitsPage, `<li id="`+commitHash+`">`)
+	assert.Contains(t, commitsPage, `style="color: var(--red-text)" title="failed">[×]</span>`)
+	assert.Contains(t, commitsPage, "FAIL TestConfig")
+	assert.NotContains(t, commitsPage, "\x1b[")
+	assert.Contains(t, commitsPage, `<a href="issues/`+crash.Shortcode+`.html">#`+crash.Shortcode+`</a>`)
+
+	report, err = buildSite(dir, "acme", issues, commits, store, defaultWorkflow(), FieldSchema{}, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, report.Attachments, "attachments already copied aren't copied again")
+}
+
+func TestLoadCommitsWithoutHead(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+
+	_, err = loadCommits(repo, newMemoryStore())
+	assert.Error(t, err, "a repo with no commits is an error, not a panic")
+}
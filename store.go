@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// IssueStore persists issues and their revision history.
+type IssueStore interface {
+	// Issues returns every stored issue, including soft-deleted ones.
+	Issues() ([]Issue, error)
+	SaveIssue(issue Issue) error
+	IssueHistory(id string) ([]IssueRevision, error)
+	// PurgeIssue removes an issue for good, unlike setting DeletedAt.
+	PurgeIssue(id string) error
+}
+
+// ActionStore persists the results of action runs.
+type ActionStore interface {
+	Actions() ([]Action, error)
+	SaveAction(action Action) error
+	DeleteAction(id string) error
+	// PruneAction removes an action run for good, so that syncing doesn't
+	// bring it back.
+	PruneAction(id string) error
+}
+
+type Store interface {
+	IssueStore
+	ActionStore
+	AttachmentStore
+	MilestoneStore
+}
+
+var errIssueNotFound = errors.New("issue not found")
+
+// gitStore keeps issues and actions under refs/ubik in a git repository.
+type gitStore struct {
+	repo *git.Repository
+	cfg  *config.Config
+}
+
+func newGitStore(repo *git.Repository, cfg *config.Config) *gitStore {
+	return &gitStore{repo: repo, cfg: cfg}
+}
+
+func (s *gitStore) refsWithPrefix(prefix string) ([]*plumbing.Reference, error) {
+	refs, err := s.repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []*plumbing.Reference
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), prefix) {
+			matching = append(matching, ref)
+		}
+		return nil
+	})
+
+	return matching, err
+}
+
+func (s *gitStore) Issues() ([]Issue, error) {
+	refs, err := s.refsWithPrefix("refs/ubik/issues/")
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	for _, ref := range refs {
+		issue, err := readIssueObject(s.repo, ref.Hash())
+		if err != nil {
+			debug("%#v", err.Error())
+			continue
+		}
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
+
+func (s *gitStore) SaveIssue(issue Issue) error {
+	_, err := commitIssue(s.repo, issue, gitSignature(s.cfg))
+	return err
+}
+
+func (s *gitStore) IssueHistory(id string) ([]IssueRevision, error) {
+	return readIssueHistory(s.repo, id)
+}
+
+func (s *gitStore) PurgeIssue(id string) error {
+	err := s.repo.Storer.RemoveReference(issueRefName(id))
+	if err != nil {
+		return err
+	}
+	return writePurgeTombstone(s.repo, id, gitSignature(s.cfg))
+}
+
+// A purge leaves a tombstone under refs/ubik/purged so it reaches other
+// clones when syncing; without it, their copy of the issue would be pushed
+// straight back. Tombstones point at an empty commit, so the purged issue's
+// objects can still be collected.
+const purgedRefPrefix = "refs/ubik/purged/"
+
+func purgedRefName(id string) plumbing.ReferenceName {
+	return plumbing.ReferenceName(purgedRefPrefix + id)
+}
+
+func writePurgeTombstone(repo *git.Repository, id string, author object.Signature) error {
+	return writeTombstone(repo, purgedRefName(id), author, fmt.Sprintf("Purge issue\n\n%s: purged\n", id))
+}
+
+// Pruned action runs get the same kind of tombstone under refs/ubik/pruned.
+const prunedRefPrefix = "refs/ubik/pruned/"
+
+func prunedRefName(id string) plumbing.ReferenceName {
+	return plumbing.ReferenceName(prunedRefPrefix + id)
+}
+
+func writePruneTombstone(repo *git.Repository, id string, author object.Signature) error {
+	return writeTombstone(repo, prunedRefName(id), author, fmt.Sprintf("Prune action\n\n%s: pruned\n", id))
+}
+
+func writeTombstone(repo *git.Repository, name plumbing.ReferenceName, author object.Signature, message string) error {
+	treeHash, err := storeObject(repo, &object.Tree{})
+	if err != nil {
+		return err
+	}
+	hash, err := storeObject(repo, &object.Commit{
+		Author:    author,
+		Committer: author,
+		Message:   message,
+		TreeHash:  treeHash,
+	})
+	if err != nil {
+		return err
+	}
+	return repo.Storer.SetReference(plumbing.NewHashReference(name, hash))
+}
+
+// purgedIssueIds lists the issues with a tombstone.
+func purgedIssueIds(repo *git.Repository) ([]string, error) {
+	return tombstonedIds(repo, purgedRefPrefix)
+}
+
+// prunedActionIds lists the action runs with a tombstone.
+func prunedActionIds(repo *git.Repository) ([]string, error) {
+	return tombstonedIds(repo, prunedRefPrefix)
+}
+
+func tombstonedIds(repo *git.Repository, prefix string) ([]string, error) {
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if id, ok := strings.CutPrefix(ref.Name().String(), prefix); ok {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func actionRefName(id string) plumbing.ReferenceName {
+	return plumbing.ReferenceName(fmt.Sprintf("refs/ubik/actions/%s", id))
+}
+
+func (s *gitStore) Actions() ([]Action, error) {
+	refs, err := s.refsWithPrefix("refs/ubik/actions/")
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []Action
+	for _, ref := range refs {
+		blob, err := s.repo.BlobObject(ref.Hash())
+		if err != nil {
+			debug("%#v", err.Error())
+			continue
+		}
+		b, err := readBlob(blob)
+		if err != nil {
+			debug("%#v", err.Error())
+			continue
+		}
+		action, err := decodeAction(b)
+		if err != nil {
+			debug("%#v", err.Error())
+			continue
+		}
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+func (s *gitStore) SaveAction(action Action) error {
+	jsonData, err := encodeAction(action)
+	if err != nil {
+		return err
+	}
+
+	hash, err := storeBlob(s.repo, jsonData)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Storer.SetReference(plumbing.NewHashReference(actionRefName(action.Id), hash))
+}
+
+func (s *gitStore) DeleteAction(id string) error {
+	return s.repo.Storer.RemoveReference(actionRefName(id))
+}
+
+func (s *gitStore) PruneAction(id string) error {
+	err := s.DeleteAction(id)
+	if err != nil {
+		return err
+	}
+	return writePruneTombstone(s.repo, id, gitSignature(s.cfg))
+}
+
+// memoryStore keeps everything in memory. It backs the handler tests.
+type memoryStore struct {
+	mu          sync.Mutex
+	issues      map[string][]IssueRevision
+	actions     map[string]Action
+	attachments map[string][]byte
+	milestones  map[string]Milestone
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		issues:      make(map[string][]IssueRevision),
+		actions:     make(map[string]Action),
+		attachments: make(map[string][]byte),
+		milestones:  make(map[string]Milestone),
+	}
+}
+
+func (s *memoryStore) Issues() ([]Issue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var issues []Issue
+	for _, id := range slices.Sorted(maps.Keys(s.issues)) {
+		revisions := s.issues[id]
+		issues = append(issues, revisions[0].Issue)
+	}
+
+	return issues, nil
+}
+
+func (s *memoryStore) SaveIssue(issue Issue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revision := IssueRevision{
+		Hash:      fmt.Sprintf("%040d", len(s.issues[issue.Id])),
+		Author:    issue.Author,
+		Timestamp: time.Now(),
+		Issue:     issue,
+		Changes:   []string{"created"},
+	}
+	if previous, ok := s.issues[issue.Id]; ok {
+		revision.Changes = diffIssues(previous[0].Issue, issue)
+		revision.Previous = &previous[0].Issue
+	}
+
+	s.issues[issue.Id] = append([]IssueRevision{revision}, s.issues[issue.Id]...)
+	return nil
+}
+
+func (s *memoryStore) IssueHistory(id string) ([]IssueRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revisions, ok := s.issues[id]
+	if !ok {
+		return nil, errIssueNotFound
+	}
+
+	return slices.Clone(revisions), nil
+}
+
+func (s *memoryStore) PurgeIssue(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.issues, id)
+	return nil
+}
+
+func (s *memoryStore) Actions() ([]Action, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Collect(maps.Values(s.actions)), nil
+}
+
+func (s *memoryStore) SaveAction(action Action) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.actions[action.Id] = action
+	return nil
+}
+
+func (s *memoryStore) DeleteAction(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.actions, id)
+	return nil
+}
+
+func (s *memoryStore) PruneAction(id string) error {
+	return s.DeleteAction(id)
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// Remote ubik refs are fetched into a staging namespace outside refs/ubik so
+// they never show up as local data until they've been merged.
+const syncStagingPrefix = "refs/ubik-sync"
+
+func syncStagingRef(remote string) string {
+	return fmt.Sprintf("%s/%s", syncStagingPrefix, remote)
+}
+
+type SyncReport struct {
+	Remote        string
+	Fetched       int
+	Created       int
+	FastForwarded int
+	Merged        int
+	// Purged counts local issues removed because another clone purged them.
+	Purged int
+	// Unsigned counts merged issues whose signature was dropped because
+	// there's no signing key to sign the merge with.
+	Unsigned int
+	Pushed   bool
+}
+
+func (r SyncReport) String() string {
+	var pushed string
+	if r.Pushed {
+		pushed = ", pushed"
+	} else {
+		pushed = ", remote up to date"
+	}
+	var unsigned string
+	if r.Unsigned > 0 {
+		unsigned = fmt.Sprintf(" (%d left unsigned, no signing key)", r.Unsigned)
+	}
+	return fmt.Sprintf(
+		"synced with %s: %d fetched, %d new, %d fast-forwarded, %d merged%s, %d purged%s",
+		r.Remote, r.Fetched, r.Created, r.FastForwarded, r.Merged, unsigned, r.Purged, pushed,
+	)
+}
+
+// syncRemoteName returns the remote configured with `git config ubik.remote`,
+// falling back to origin.
+func syncRemoteName(repo *git.Repository) string {
+	cfg, err := repo.Config()
+	if err != nil {
+		return "origin"
+	}
+	if remote := cfg.Raw.Section("ubik").Option("remote"); remote != "" {
+		return remote
+	}
+	return "origin"
+}
+
+// syncRepo fetches refs/ubik/* from remoteName, merges it into the local refs
+// and pushes the result back. Issues purged and action runs pruned on either
+// side are removed from both, and action runs gc would prune aren't fetched.
+// Remote issues are merged into whichever layout the repo uses: a
+// packed repo folds per-ref issues into its index, while a repo still on
+// per-ref issues can't take in a packed index and has to be packed first.
+// Merged issues are signed by signer, which may be nil when signing isn't
+// configured.
+func syncRepo(repo *git.Repository, remoteName string, author object.Signature, signer *signingStore) (SyncReport, error) {
+	report := SyncReport{Remote: remoteName}
+	resign := &resigner{signer: signer}
+
+	remote, err := repo.Remote(remoteName)
+	if err != nil {
+		return report, fmt.Errorf("remote %q: %w", remoteName, err)
+	}
+
+	staging := syncStagingRef(remoteName)
+	err = remote.Fetch(&git.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("+refs/ubik/*:%s/*", staging))},
+	})
+	var noMatch git.NoMatchingRefSpecError
+	switch {
+	case err == nil, errors.Is(err, git.NoErrAlreadyUpToDate), errors.Is(err, transport.ErrEmptyRemoteRepository), errors.As(err, &noMatch):
+		// nothing new on the remote
+	default:
+		return report, fmt.Errorf("fetch from %s: %w", remoteName, err)
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return report, err
+	}
+	var stagedRefs []*plumbing.Reference
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), staging+"/") {
+			stagedRefs = append(stagedRefs, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	// everything staged is merged below, and leaving it would keep purged
+	// issues' objects reachable
+	clearStaged := func() error {
+		for _, ref := range stagedRefs {
+			err := repo.Storer.RemoveReference(ref.Name())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	layout := storageLayout(repo)
+	if layout == layoutRefs && slices.ContainsFunc(stagedRefs, func(ref *plumbing.Reference) bool {
+		return ref.Name().String() == staging+"/"+strings.TrimPrefix(packedIndexRef.String(), "refs/ubik/")
+	}) {
+		err = clearStaged()
+		if err != nil {
+			return report, err
+		}
+		return report, fmt.Errorf("%s keeps issues in the %s layout; run `ubik migrate --layout %s` before syncing", remoteName, layoutPacked, layoutPacked)
+	}
+
+	// take in the remote's tombstones first, so the issues and action runs
+	// they removed aren't merged back in below
+	for _, theirs := range stagedRefs {
+		localName := plumbing.ReferenceName("refs/ubik/" + strings.TrimPrefix(theirs.Name().String(), staging+"/"))
+		if !strings.HasPrefix(localName.String(), purgedRefPrefix) && !strings.HasPrefix(localName.String(), prunedRefPrefix) {
+			continue
+		}
+		_, err := repo.Storer.Reference(localName)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			err = repo.Storer.SetReference(plumbing.NewHashReference(localName, theirs.Hash()))
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+	purged, err := purgedIssueIds(repo)
+	if err != nil {
+		return report, err
+	}
+	report.Purged, err = removePurgedIssues(repo, layout, purged, author)
+	if err != nil {
+		return report, err
+	}
+	pruned, err := prunedActionIds(repo)
+	if err != nil {
+		return report, err
+	}
+	err = removePrunedActions(repo, pruned)
+	if err != nil {
+		return report, err
+	}
+	gcOpts := defaultGCOptions()
+
+	pushSpecs := []config.RefSpec{
+		"refs/ubik/issues/*:refs/ubik/issues/*",
+		config.RefSpec(fmt.Sprintf("%s:%s", packedIndexRef, packedIndexRef)),
+		"+refs/ubik/actions/*:refs/ubik/actions/*",
+		"+refs/ubik/milestones/*:refs/ubik/milestones/*",
+		// two clones can purge the same issue; either tombstone will do
+		"+refs/ubik/purged/*:refs/ubik/purged/*",
+		"+refs/ubik/pruned/*:refs/ubik/pruned/*",
+	}
+	var issueRefs []*plumbing.Reference
+	for _, theirs := range stagedRefs {
+		report.Fetched++
+		localName := plumbing.ReferenceName("refs/ubik/" + strings.TrimPrefix(theirs.Name().String(), staging+"/"))
+
+		if strings.HasPrefix(localName.String(), purgedRefPrefix) || strings.HasPrefix(localName.String(), prunedRefPrefix) {
+			continue
+		}
+		if id, ok := strings.CutPrefix(localName.String(), "refs/ubik/actions/"); ok {
+			if slices.Contains(pruned, id) {
+				pushSpecs = append(pushSpecs, config.RefSpec(":"+localName))
+				continue
+			}
+			_, err := repo.Storer.Reference(localName)
+			if errors.Is(err, plumbing.ErrReferenceNotFound) && fetchedActionExpired(repo, theirs.Hash(), gcOpts) {
+				// past the retention window, so gc would only prune it again
+				continue
+			}
+		}
+		if id, ok := strings.CutPrefix(localName.String(), "refs/ubik/issues/"); ok {
+			if slices.Contains(purged, id) {
+				// purged here or elsewhere: take it off the remote too
+				pushSpecs = append(pushSpecs, config.RefSpec(":"+localName))
+				continue
+			}
+			if layout == layoutPacked {
+				issueRefs = append(issueRefs, theirs)
+				continue
+			}
+		}
+
+		ours, err := repo.Storer.Reference(localName)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			err = repo.Storer.SetReference(plumbing.NewHashReference(localName, theirs.Hash()))
+			if err != nil {
+				return report, err
+			}
+			report.Created++
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		if ours.Hash() == theirs.Hash() {
+			continue
+		}
+
+		var result syncResult
+		switch {
+		case localName == packedIndexRef:
+			result, err = syncPackedIndexRef(repo, ours, theirs, author, resign)
+		case strings.HasPrefix(localName.String(), "refs/ubik/issues/"):
+			result, err = syncIssueRef(repo, ours, theirs, author, resign)
+		case strings.HasPrefix(localName.String(), milestoneRefPrefix):
+			result, err = syncMilestoneRef(repo, ours, theirs)
+		default:
+			// actions are immutable once they've finished, so there's nothing
+			// to merge; keep whatever we have locally.
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("merge %s: %w", localName, err)
+		}
+		switch result {
+		case syncFastForwarded:
+			report.FastForwarded++
+		case syncMerged:
+			report.Merged++
+		}
+	}
+
+	if len(issueRefs) > 0 {
+		created, merged, err := foldIssueRefs(repo, issueRefs, author, resign)
+		if err != nil {
+			return report, fmt.Errorf("merge issue refs into %s: %w", packedIndexRef, err)
+		}
+		report.Created += created
+		report.Merged += merged
+	}
+	report.Unsigned = resign.Dropped
+
+	err = clearStaged()
+	if err != nil {
+		return report, err
+	}
+
+	err = remote.Push(&git.PushOptions{
+		RemoteName: remoteName,
+		RefSpecs:   pushSpecs,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return report, nil
+	}
+	if err != nil {
+		return report, fmt.Errorf("push to %s: %w", remoteName, err)
+	}
+	report.Pushed = true
+
+	return report, nil
+}
+
+// removePurgedIssues removes the local copies of issues with a tombstone,
+// returning how many there were.
+func removePurgedIssues(repo *git.Repository, layout string, purged []string, author object.Signature) (int, error) {
+	if layout == layoutPacked {
+		store := &packedStore{gitStore: newGitStore(repo, nil)}
+		issues, err := store.Issues()
+		if err != nil {
+			return 0, err
+		}
+		var ids []string
+		for _, issue := range issues {
+			if slices.Contains(purged, issue.Id) {
+				ids = append(ids, issue.Id)
+			}
+		}
+		if len(ids) == 0 {
+			return 0, nil
+		}
+		return len(ids), store.removeIssues(author, ids...)
+	}
+
+	var removed int
+	for _, id := range purged {
+		_, err := repo.Storer.Reference(issueRefName(id))
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		err = repo.Storer.RemoveReference(issueRefName(id))
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// removePrunedActions removes the local copies of action runs with a
+// tombstone.
+func removePrunedActions(repo *git.Repository, pruned []string) error {
+	for _, id := range pruned {
+		err := repo.Storer.RemoveReference(actionRefName(id))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchedActionExpired reports whether the action run stored at hash is older
+// than the retention window gc keeps action runs for.
+func fetchedActionExpired(repo *git.Repository, hash plumbing.Hash, opts GCOptions) bool {
+	blob, err := repo.BlobObject(hash)
+	if err != nil {
+		return false
+	}
+	data, err := readBlob(blob)
+	if err != nil {
+		return false
+	}
+	action, err := decodeAction(data)
+	if err != nil {
+		return false
+	}
+	return actionExpired(action, opts.Now, opts.ActionRetention)
+}
+
+type syncResult int
+
+const (
+	syncUnchanged syncResult = iota
+	syncFastForwarded
+	syncMerged
+)
+
+// fastForward moves ours to theirs if ours is behind. It reports whether the
+// two refs had diverged and still need a merge.
+func fastForward(repo *git.Repository, ours, theirs *plumbing.Reference, ourCommit, theirCommit *object.Commit) (syncResult, bool, error) {
+	ahead, err := theirCommit.IsAncestor(ourCommit)
+	if err != nil {
+		return syncUnchanged, false, err
+	}
+	if ahead {
+		return syncUnchanged, false, nil
+	}
+
+	behind, err := ourCommit.IsAncestor(theirCommit)
+	if err != nil {
+		return syncUnchanged, false, err
+	}
+	if behind {
+		err = repo.Storer.CheckAndSetReference(plumbing.NewHashReference(ours.Name(), theirs.Hash()), ours)
+		return syncFastForwarded, false, err
+	}
+
+	return syncUnchanged, true, nil
+}
+
+func syncPackedIndexRef(repo *git.Repository, ours, theirs *plumbing.Reference, author object.Signature, resign *resigner) (syncResult, error) {
+	ourCommit, err := repo.CommitObject(ours.Hash())
+	if err != nil {
+		return syncUnchanged, err
+	}
+	theirCommit, err := repo.CommitObject(theirs.Hash())
+	if err != nil {
+		return syncUnchanged, err
+	}
+
+	result, diverged, err := fastForward(repo, ours, theirs, ourCommit, theirCommit)
+	if err != nil || !diverged {
+		return result, err
+	}
+
+	hash, err := mergePackedIndex(repo, ourCommit, theirCommit, author, resign)
+	if err != nil {
+		return syncUnchanged, err
+	}
+
+	err = repo.Storer.CheckAndSetReference(plumbing.NewHashReference(ours.Name(), hash), ours)
+	return syncMerged, err
+}
+
+func syncIssueRef(repo *git.Repository, ours, theirs *plumbing.Reference, author object.Signature, resign *resigner) (syncResult, error) {
+	ourCommit, ourErr := repo.CommitObject(ours.Hash())
+	theirCommit, theirErr := repo.CommitObject(theirs.Hash())
+
+	if ourErr == nil && theirErr == nil {
+		result, diverged, err := fastForward(repo, ours, theirs, ourCommit, theirCommit)
+		if err != nil || !diverged {
+			return result, err
+		}
+	}
+
+	ourIssue, err := readIssueObject(repo, ours.Hash())
+	if err != nil {
+		return syncUnchanged, err
+	}
+	theirIssue, err := readIssueObject(repo, theirs.Hash())
+	if err != nil {
+		return syncUnchanged, err
+	}
+
+	var base Issue
+	if ourErr == nil && theirErr == nil {
+		bases, err := ourCommit.MergeBase(theirCommit)
+		if err != nil {
+			return syncUnchanged, err
+		}
+		if len(bases) > 0 {
+			base, err = readIssueObject(repo, bases[0].Hash)
+			if err != nil {
+				return syncUnchanged, err
+			}
+		}
+	}
+
+	merged, err := resign.issue(mergeIssues(base, ourIssue, theirIssue), ourIssue, theirIssue)
+	if err != nil {
+		return syncUnchanged, err
+	}
+
+	message := "Merge remote changes"
+	if len(merged.Conflicts) > 0 {
+		var fields []string
+		for _, c := range merged.Conflicts {
+			fields = append(fields, c.Field)
+		}
+		message = fmt.Sprintf("%s\n\nConflicts: %s\n", message, strings.Join(fields, ", "))
+	}
+
+	var parents []plumbing.Hash
+	if ourErr == nil {
+		parents = append(parents, ours.Hash())
+	}
+	if theirErr == nil {
+		parents = append(parents, theirs.Hash())
+	}
+
+	hash, err := writeIssueCommit(repo, merged, author, message, parents...)
+	if err != nil {
+		return syncUnchanged, err
+	}
+
+	err = repo.Storer.CheckAndSetReference(plumbing.NewHashReference(ours.Name(), hash), ours)
+	return syncMerged, err
+}
+
+type syncFinishedMsg struct {
+	Report SyncReport
+	Err    error
+}
+
+func syncIssues(repo *git.Repository, cfg *config.Config) tea.Cmd {
+	return func() tea.Msg {
+		report, err := syncRepo(repo, syncRemoteName(repo), gitSignature(cfg), loadSigner(cfg))
+		if err != nil {
+			debug("%#v", err.Error())
+		}
+		return syncFinishedMsg{Report: report, Err: err}
+	}
+}
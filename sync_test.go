@@ -0,0 +1,303 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSyncClone(t *testing.T, remoteDir string) *git.Repository {
+	t.Helper()
+
+	repo, err := git.PlainInit(t.TempDir(), false)
+	require.NoError(t, err)
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remoteDir}})
+	require.NoError(t, err)
+
+	return repo
+}
+
+func TestSyncRepo(t *testing.T) {
+	remoteDir := t.TempDir()
+	_, err := git.PlainInit(remoteDir, true)
+	require.NoError(t, err)
+
+	alice := newSyncClone(t, remoteDir)
+	bob := newSyncClone(t, remoteDir)
+	aliceSig := object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()}
+	bobSig := object.Signature{Name: "Bob", Email: "bob@example.com", When: time.Now()}
+
+	issue := Issue{Id: "abc", Title: "Shared", Status: todo, UpdatedAt: time.Now()}
+	_, err = commitIssue(alice, issue, aliceSig)
+	require.NoError(t, err)
+
+	report, err := syncRepo(alice, "origin", aliceSig, nil)
+	require.NoError(t, err)
+	assert.True(t, report.Pushed)
+
+	report, err = syncRepo(bob, "origin", bobSig, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+
+	ref, err := bob.Reference(issueRefName("abc"), true)
+	require.NoError(t, err)
+	bobIssue, err := readIssueObject(bob, ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "Shared", bobIssue.Title)
+
+	// Bob edits first and pushes, then Alice fast-forwards onto it.
+	bobIssue.Status = done
+	bobIssue.UpdatedAt = time.Now()
+	_, err = commitIssue(bob, bobIssue, bobSig)
+	require.NoError(t, err)
+	_, err = syncRepo(bob, "origin", bobSig, nil)
+	require.NoError(t, err)
+
+	report, err = syncRepo(alice, "origin", aliceSig, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.FastForwarded)
+
+	// Both edit concurrently; the second sync has to merge.
+	ref, err = alice.Reference(issueRefName("abc"), true)
+	require.NoError(t, err)
+	aliceIssue, err := readIssueObject(alice, ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, done, aliceIssue.Status)
+
+	aliceIssue.Title = "Alice's title"
+	aliceIssue.UpdatedAt = time.Now()
+	_, err = commitIssue(alice, aliceIssue, aliceSig)
+	require.NoError(t, err)
+
+	bobIssue.Labels = []string{"urgent"}
+	bobIssue.UpdatedAt = time.Now().Add(time.Minute)
+	_, err = commitIssue(bob, bobIssue, bobSig)
+	require.NoError(t, err)
+
+	_, err = syncRepo(alice, "origin", aliceSig, nil)
+	require.NoError(t, err)
+	report, err = syncRepo(bob, "origin", bobSig, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Merged)
+	assert.True(t, report.Pushed)
+
+	revisions, err := readIssueHistory(bob, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "Merge remote changes", revisions[0].Message)
+	assert.Equal(t, "Alice's title", revisions[0].Issue.Title)
+	assert.Equal(t, []string{"urgent"}, revisions[0].Issue.Labels)
+	assert.Empty(t, revisions[0].Issue.Conflicts)
+
+	report, err = syncRepo(alice, "origin", aliceSig, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.FastForwarded)
+}
+
+func TestSyncPurgedIssues(t *testing.T) {
+	remoteDir := t.TempDir()
+	_, err := git.PlainInit(remoteDir, true)
+	require.NoError(t, err)
+
+	alice := newSyncClone(t, remoteDir)
+	bob := newSyncClone(t, remoteDir)
+	aliceSig := object.Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()}
+	bobSig := object.Signature{Name: "Bob", Email: "bob@example.com", When: time.Now()}
+	aliceStore := newGitStore(alice, testGitConfig("alice@example.com"))
+
+	now := time.Now()
+	gone := Issue{Id: "gone", Shortcode: "aaaaaa", Title: "Gone", DeletedAt: now.Add(-60 * 24 * time.Hour)}
+	kept := Issue{Id: "kept", Shortcode: "bbbbbb", Title: "Kept"}
+	for _, issue := range []Issue{gone, kept} {
+		require.NoError(t, aliceStore.SaveIssue(issue))
+	}
+	_, err = syncRepo(alice, "origin", aliceSig, nil)
+	require.NoError(t, err)
+	_, err = syncRepo(bob, "origin", bobSig, nil)
+	require.NoError(t, err)
+
+	opts := defaultGCOptions()
+	opts.Now = now.Add(2 * time.Hour)
+	report, err := collectGarbage(alice, aliceStore, opts)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.IssuesPurged)
+	assert.Greater(t, report.ObjectsRemoved, 0, "refs staged by the last sync don't keep the issue's objects")
+
+	synced, err := syncRepo(alice, "origin", aliceSig, nil)
+	require.NoError(t, err)
+	assert.Zero(t, synced.Created, "the remote's copy isn't brought back")
+	_, err = alice.Reference(issueRefName("gone"), true)
+	assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+
+	synced, err = syncRepo(bob, "origin", bobSig, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, synced.Purged)
+	for _, repo := range []*git.Repository{alice, bob} {
+		_, err = syncRepo(repo, "origin", aliceSig, nil)
+		require.NoError(t, err)
+		_, err = repo.Reference(issueRefName("gone"), true)
+		assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound, "purges stick on every clone")
+		_, err = repo.Reference(issueRefName("kept"), true)
+		assert.NoError(t, err)
+
+		refs, err := repo.References()
+		require.NoError(t, err)
+		require.NoError(t, refs.ForEach(func(ref *plumbing.Reference) error {
+			assert.NotContains(t, ref.Name().String(), syncStagingPrefix, "staged refs are cleared after a sync")
+			return nil
+		}))
+	}
+
+	remote, err := git.PlainOpen(remoteDir)
+	require.NoError(t, err)
+	_, err = remote.Reference(issueRefName("gone"), true)
+	assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+	_, err = remote.Reference(purgedRefName("gone"), true)
+	assert.NoError(t, err)
+}
+
+func TestSyncPrunedActions(t *testing.T) {
+	remoteDir := t.TempDir()
+	_, err := git.PlainInit(remoteDir, true)
+	require.NoError(t, err)
+
+	alice := newSyncClone(t, remoteDir)
+	bob := newSyncClone(t, remoteDir)
+	sig := object.Signature{Email: "sync@example.com", When: time.Now()}
+	aliceStore := newGitStore(alice, testGitConfig("alice@example.com"))
+
+	now := time.Now()
+	require.NoError(t, aliceStore.SaveAction(Action{Id: "stale", CommitId: "c1", FinishedAt: now.Add(-20 * 24 * time.Hour)}))
+	require.NoError(t, aliceStore.SaveAction(Action{Id: "fresh", CommitId: "c1", FinishedAt: now}))
+	_, err = syncRepo(alice, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = bob.Reference(actionRefName("stale"), true)
+	require.NoError(t, err, "bob has a copy of the old run before anyone prunes it")
+
+	// the run is still inside sync's retention window, so only the
+	// tombstone keeps it from coming back
+	opts := defaultGCOptions()
+	opts.ActionRetention = 10 * 24 * time.Hour
+	report, err := collectGarbage(alice, aliceStore, opts)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.ActionsPruned)
+
+	for _, repo := range []*git.Repository{alice, bob, alice} {
+		_, err = syncRepo(repo, "origin", sig, nil)
+		require.NoError(t, err)
+		_, err = repo.Reference(actionRefName("stale"), true)
+		assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound, "pruned runs stay gone after syncing")
+		_, err = repo.Reference(actionRefName("fresh"), true)
+		assert.NoError(t, err)
+	}
+
+	remote, err := git.PlainOpen(remoteDir)
+	require.NoError(t, err)
+	_, err = remote.Reference(actionRefName("stale"), true)
+	assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+	_, err = remote.Reference(prunedRefName("stale"), true)
+	assert.NoError(t, err)
+
+	// a clone that never saw the run doesn't fetch one gc would prune
+	carol := newSyncClone(t, remoteDir)
+	require.NoError(t, newGitStore(bob, testGitConfig("bob@example.com")).SaveAction(Action{Id: "ancient", CommitId: "c1", FinishedAt: now.Add(-90 * 24 * time.Hour)}))
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = syncRepo(carol, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = carol.Reference(actionRefName("ancient"), true)
+	assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+	_, err = carol.Reference(actionRefName("fresh"), true)
+	assert.NoError(t, err)
+}
+
+func TestSyncIssuePurgedTwice(t *testing.T) {
+	remoteDir := t.TempDir()
+	_, err := git.PlainInit(remoteDir, true)
+	require.NoError(t, err)
+
+	alice := newSyncClone(t, remoteDir)
+	bob := newSyncClone(t, remoteDir)
+	sig := object.Signature{Email: "sync@example.com", When: time.Now()}
+	aliceStore := newGitStore(alice, testGitConfig("alice@example.com"))
+	bobStore := newGitStore(bob, testGitConfig("bob@example.com"))
+
+	require.NoError(t, aliceStore.SaveIssue(Issue{Id: "gone", Title: "Gone"}))
+	_, err = syncRepo(alice, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, aliceStore.PurgeIssue("gone"))
+	require.NoError(t, bobStore.PurgeIssue("gone"))
+	_, err = syncRepo(alice, "origin", sig, nil)
+	require.NoError(t, err)
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err, "the other clone's tombstone doesn't block the push")
+}
+
+func TestSyncMergeSignatures(t *testing.T) {
+	remoteDir := t.TempDir()
+	_, err := git.PlainInit(remoteDir, true)
+	require.NoError(t, err)
+
+	alice := newSyncClone(t, remoteDir)
+	bob := newSyncClone(t, remoteDir)
+	sig := object.Signature{Email: "sync@example.com", When: time.Now()}
+	signingConfig, _, _ := sshSigningConfig(t, "alice@example.com")
+	aliceStore := newSigningStore(t, newGitStore(alice, signingConfig), signingConfig)
+	bobStore := newGitStore(bob, testGitConfig("bob@example.com"))
+
+	issue := Issue{Id: "abc", Title: "Signed", Status: todo}
+	require.NoError(t, aliceStore.SaveIssue(issue))
+	_, err = syncRepo(alice, "origin", sig, aliceStore)
+	require.NoError(t, err)
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+
+	edit := func(store Store, change func(*Issue)) {
+		t.Helper()
+		issues, err := store.Issues()
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		change(&issues[0])
+		require.NoError(t, store.SaveIssue(issues[0]))
+	}
+
+	// without a key, bob's merge can't carry alice's signature over
+	edit(aliceStore, func(issue *Issue) { issue.Title = "Renamed" })
+	edit(bobStore, func(issue *Issue) { issue.Status = done })
+	_, err = syncRepo(alice, "origin", sig, aliceStore)
+	require.NoError(t, err)
+	report, err := syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Merged)
+	assert.Equal(t, 1, report.Unsigned)
+	assert.Contains(t, report.String(), "1 left unsigned")
+	issues, err := bobStore.Issues()
+	require.NoError(t, err)
+	assert.Nil(t, issues[0].Signature)
+
+	// with one, alice's merge is signed again
+	_, err = syncRepo(alice, "origin", sig, aliceStore)
+	require.NoError(t, err)
+	edit(aliceStore, func(issue *Issue) { issue.Description = "Details" })
+	edit(bobStore, func(issue *Issue) { issue.Labels = []string{"bug"} })
+	_, err = syncRepo(bob, "origin", sig, nil)
+	require.NoError(t, err)
+	report, err = syncRepo(alice, "origin", sig, aliceStore)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Merged)
+	assert.Zero(t, report.Unsigned)
+	issues, err = aliceStore.Issues()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bug"}, issues[0].Labels)
+	assert.True(t, issues[0].Verified)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+)
+
+// A repo can offer templates for new issues in .ubik/templates/*.md. Each is
+// Markdown that becomes the description, with optional front matter, e.g.
+//
+//	---
+//	name: Bug report
+//	about: Something isn't working
+//	title: "[bug] "
+//	labels: bug, triage
+//	status: todo
+//	---
+//	## Steps to reproduce
+//
+// title is what the issue's title starts with and status is the one it's
+// created in. When there are templates, n asks which one to use.
+const templatesPath = ".ubik/templates"
+
+type issueTemplate struct {
+	Name   string
+	About  string
+	Title  string
+	Labels []string
+	Status issueStatus
+	Body   string
+}
+
+var errInvalidTemplate = errors.New("invalid template")
+
+// parseTemplate reads a template file's front matter and body. name is what
+// the template is called if the front matter doesn't say.
+func parseTemplate(name, content string) (issueTemplate, error) {
+	template := issueTemplate{Name: name}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if strings.TrimSpace(lines[0]) != "---" {
+		template.Body = strings.Join(lines, "\n")
+		return template, nil
+	}
+
+	end := slices.IndexFunc(lines[1:], func(line string) bool { return strings.TrimSpace(line) == "---" })
+	if end < 0 {
+		return template, fmt.Errorf("%w: %s: front matter isn't closed with ---", errInvalidTemplate, name)
+	}
+	template.Body = strings.TrimLeft(strings.Join(lines[end+2:], "\n"), "\n")
+
+	for _, line := range lines[1 : end+1] {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return template, fmt.Errorf("%w: %s: %q isn't a key: value line", errInvalidTemplate, name, line)
+		}
+		value = unquote(strings.TrimSpace(value))
+
+		switch strings.TrimSpace(field) {
+		case "name":
+			template.Name = value
+		case "about":
+			template.About = value
+		case "title":
+			template.Title = value
+		case "labels":
+			value = strings.Trim(value, "[]")
+			template.Labels = strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+			for i, label := range template.Labels {
+				template.Labels[i] = unquote(label)
+			}
+		case "status":
+			template.Status = issueStatus(value)
+		default:
+			return template, fmt.Errorf("%w: %s: unknown key %q", errInvalidTemplate, name, strings.TrimSpace(field))
+		}
+	}
+
+	return template, nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// readTemplates reads every template in dir, in file name order. A template
+// that can't be read is left out and reported along with the rest.
+func readTemplates(dir string) ([]issueTemplate, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+
+	var templates []issueTemplate
+	var errs []error
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		template, err := parseTemplate(strings.TrimSuffix(filepath.Base(path), ".md"), string(content))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, errors.Join(errs...)
+}
+
+type templatesLoadedMsg struct {
+	Templates []issueTemplate
+	Err       error
+}
+
+func loadTemplates(repo *git.Repository) tea.Cmd {
+	return func() tea.Msg {
+		templates, err := readTemplates(repoConfigPath(repo, templatesPath))
+		if err != nil {
+			debug("%#v", err.Error())
+		}
+		return templatesLoadedMsg{Templates: templates, Err: err}
+	}
+}
+
+// status is the status issues made from the template start in, as long as
+// the workflow still has it.
+func (t issueTemplate) status(w Workflow) issueStatus {
+	if slices.ContainsFunc(w.Statuses, func(s workflowStatus) bool { return s.Name == t.Status }) {
+		return t.Status
+	}
+	return w.initial()
+}
+
+// openNewIssueForm opens the new issue form, filled in from template if
+// there is one.
+func (m Model) openNewIssueForm(template *issueTemplate) (Model, tea.Cmd) {
+	m.path = issuesNewTitlePath
+	m.underlayPath = 0
+	m.issueForm = newIssueForm("", "", "", []string{}, "", false)
+	if template != nil {
+		m.issueForm = newIssueForm("", template.Title, template.Body, template.Labels, "", false)
+		m.issueForm.template = template.Name
+		m.issueForm.status = template.status(m.workflow)
+	}
+	m.issueForm.milestonePicker = newMilestonePicker(m.loadedMilestones(), "")
+	m.issueForm.fieldInputs = newFieldInputs(m.fieldSchema, nil)
+	m.issueForm.titleInput.CursorEnd()
+	cmd := m.issueForm.titleInput.Focus()
+	m.UpdateLayout(m.layout.TerminalSize)
+	return m, cmd
+}
+
+func issuesTemplatePickerHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Up):
+			m.templatePicker = max(m.templatePicker-1, 0)
+			return m, cmd
+		case key.Matches(msg, keys.Down):
+			m.templatePicker = min(m.templatePicker+1, len(m.templates))
+			return m, cmd
+		case key.Matches(msg, keys.Submit):
+			// the first option is a blank issue
+			if m.templatePicker == 0 {
+				return m.openNewIssueForm(nil)
+			}
+			return m.openNewIssueForm(&m.templates[m.templatePicker-1])
+		case key.Matches(msg, keys.Back):
+			m.path = issuesIndexPath
+			m.underlayPath = 0
+			return m, cmd
+		}
+	}
+
+	return m, cmd
+}
+
+func (m Model) templatePickerView() string {
+	var s strings.Builder
+	s.WriteString("New issue from a template\n")
+
+	faint := lipgloss.NewStyle().Foreground(styles.Theme.FaintText)
+	selected := lipgloss.NewStyle().Foreground(styles.Theme.PrimaryText).Background(styles.Theme.SelectedBackground)
+	options := append([]issueTemplate{{Name: "Blank issue"}}, m.templates...)
+	for i, template := range options {
+		s.WriteString("\n")
+		name := template.Name
+		if i == m.templatePicker {
+			name = selected.Render(name)
+		}
+		s.WriteString(name)
+		if template.About != "" {
+			s.WriteString(" " + faint.Render(template.About))
+		}
+	}
+
+	return s.String()
+}
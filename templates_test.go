@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bugTemplate = `---
+name: Bug report
+about: Something isn't working
+title: "[bug] "
+labels: [bug, triage]
+status: in-progress
+---
+
+## Steps to reproduce
+`
+
+func TestParseTemplate(t *testing.T) {
+	template, err := parseTemplate("bug", bugTemplate)
+	require.NoError(t, err)
+	assert.Equal(t, issueTemplate{
+		Name:   "Bug report",
+		About:  "Something isn't working",
+		Title:  "[bug] ",
+		Labels: []string{"bug", "triage"},
+		Status: inProgress,
+		Body:   "## Steps to reproduce\n",
+	}, template)
+
+	template, err = parseTemplate("plain", "Just a body")
+	require.NoError(t, err)
+	assert.Equal(t, issueTemplate{Name: "plain", Body: "Just a body"}, template)
+
+	tests := map[string]string{
+		"unclosed":    "---\ntitle: oops\n",
+		"unknown key": "---\nassignee: bob\n---\n",
+		"not a field": "---\njust words\n---\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseTemplate(name, content)
+			assert.ErrorIs(t, err, errInvalidTemplate)
+		})
+	}
+}
+
+func TestReadTemplates(t *testing.T) {
+	dir := t.TempDir()
+
+	templates, err := readTemplates(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, templates)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bug.md"), []byte(bugTemplate), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "feature.md"), []byte("---\nlabels: feature\n---\nWhat should it do?\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.md"), []byte("---\nname: broken\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a template"), 0o644))
+
+	templates, err = readTemplates(dir)
+	assert.ErrorIs(t, err, errInvalidTemplate)
+	require.Len(t, templates, 2)
+	assert.Equal(t, "Bug report", templates[0].Name)
+	assert.Equal(t, "feature", templates[1].Name)
+	assert.Equal(t, []string{"feature"}, templates[1].Labels)
+}
+
+func TestIssueTemplates(t *testing.T) {
+	bug, err := parseTemplate("bug", bugTemplate)
+	require.NoError(t, err)
+	m, store := newTestModel(t)
+	m = update(t, m, templatesLoadedMsg{Templates: []issueTemplate{bug, {Name: "chore", Status: "someday"}}})
+
+	m, _ = press(t, m, "n")
+	require.Equal(t, issuesTemplatePickerPath, m.path)
+	assert.Contains(t, m.View(), "Blank issue")
+	assert.Contains(t, m.View(), "Something isn't working")
+	m, _ = press(t, m, "esc")
+	assert.Equal(t, issuesIndexPath, m.path)
+
+	m, _ = press(t, m, "n", "enter")
+	assert.Equal(t, issuesNewTitlePath, m.path)
+	assert.Empty(t, m.issueForm.titleInput.Value())
+	m, _ = press(t, m, "esc")
+
+	m, _ = press(t, m, "n", "down", "enter")
+	require.Equal(t, issuesNewTitlePath, m.path)
+	assert.Equal(t, "[bug] ", m.issueForm.titleInput.Value())
+	assert.Equal(t, "bug triage", m.issueForm.labelsInput.Value())
+	assert.Equal(t, "## Steps to reproduce\n", m.issueForm.descriptionInput.Value())
+	assert.Contains(t, m.View(), "from the Bug report template")
+
+	m, _ = press(t, m, "c", "r", "a", "s", "h")
+	for m.path != issuesNewConfirmationPath {
+		m, _ = press(t, m, "tab")
+	}
+	m, cmd := press(t, m, "enter")
+	m, _ = deliver(t, m, cmd)
+
+	issues, err := store.Issues()
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "[bug] crash", issues[0].Title)
+	assert.Equal(t, []string{"bug", "triage"}, issues[0].Labels)
+	assert.Equal(t, inProgress, issues[0].Status)
+
+	assert.Equal(t, todo, issueTemplate{Status: "someday"}.status(defaultWorkflow()), "a status the workflow doesn't have falls back to the first")
+}
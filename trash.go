@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+)
+
+// deletedIssue is how a soft-deleted issue shows up in the trash.
+type deletedIssue struct {
+	Issue
+}
+
+// trashDelegate renders deleted issues with who deleted them and when
+// instead of who opened them.
+type trashDelegate struct {
+	workflow Workflow
+}
+
+func (d trashDelegate) Height() int                             { return 2 }
+func (d trashDelegate) Spacing() int                            { return 1 }
+func (d trashDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+
+func (d trashDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	i, ok := listItem.(deletedIssue)
+
+	if !ok {
+		return
+	}
+
+	defaultItemStyles := list.NewDefaultItemStyles()
+
+	titleFn := defaultItemStyles.NormalTitle.Padding(0).Render
+	if index == m.Index() {
+		titleFn = func(s ...string) string {
+			return defaultItemStyles.SelectedTitle.
+				Border(lipgloss.NormalBorder(), false, false, false, false).
+				Padding(0).
+				Render(strings.Join(s, " "))
+		}
+	}
+	title := fmt.Sprintf("%s %s", d.workflow.icon(i.Status), titleFn(truncate.StringWithTail(i.Title, 50, "...")))
+
+	deletedBy := ""
+	if i.DeletedBy != "" {
+		deletedBy = fmt.Sprintf(" by %s", i.DeletedBy)
+	}
+	description := lipgloss.NewStyle().Foreground(styles.Theme.SecondaryText).Render(fmt.Sprintf(
+		"#%s deleted%s on %s",
+		i.Shortcode,
+		deletedBy,
+		i.DeletedAt.Local().Format(time.DateTime),
+	))
+	item := lipgloss.JoinVertical(lipgloss.Left, title, description)
+
+	fmt.Fprint(w, item)
+}
+
+type TrashReadyMsg []Issue
+
+// loadFailedMsg reports that something, named by What, couldn't be read from
+// the store.
+type loadFailedMsg struct {
+	What string
+	Err  error
+}
+
+// getTrash loads the soft-deleted issues, most recently deleted first.
+func getTrash(store IssueStore) tea.Cmd {
+	return func() tea.Msg {
+		var issues []Issue
+
+		storedIssues, err := store.Issues()
+		if err != nil {
+			debug("%#v", err.Error())
+			return loadFailedMsg{What: "trash", Err: err}
+		}
+
+		for _, issue := range storedIssues {
+			if !issue.DeletedAt.IsZero() {
+				issues = append(issues, issue)
+			}
+		}
+
+		slices.SortFunc(issues, func(a, b Issue) int {
+			return b.DeletedAt.Compare(a.DeletedAt)
+		})
+
+		return TrashReadyMsg(issues)
+	}
+}
+
+type issueRestoredMsg struct {
+	Issue Issue
+}
+
+func restoreIssue(issue Issue, store IssueStore) tea.Cmd {
+	return func() tea.Msg {
+		issue.DeletedAt = time.Time{}
+		issue.DeletedBy = ""
+		issue.UpdatedAt = time.Now().UTC()
+
+		err := store.SaveIssue(issue)
+		if err != nil {
+			debug("%#v", err.Error())
+			return err
+		}
+
+		return issueRestoredMsg{Issue: issue}
+	}
+}
+
+type issuePurgedMsg struct {
+	Id string
+}
+
+func purgeIssue(issue Issue, store IssueStore) tea.Cmd {
+	return func() tea.Msg {
+		err := store.PurgeIssue(issue.Id)
+		if err != nil {
+			debug("%#v", err.Error())
+			return err
+		}
+
+		return issuePurgedMsg{Id: issue.Id}
+	}
+}
+
+func (m *Model) removeFromTrash(id string) {
+	for i, item := range m.trashIndex.Items() {
+		if item.(deletedIssue).Id == id {
+			m.trashIndex.RemoveItem(i)
+			m.trashIndex.Select(clamp(i-1, 0, len(m.trashIndex.Items())))
+			return
+		}
+	}
+}
+
+func trashIndexHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	if m.trashIndex.SettingFilter() {
+		m.trashIndex.Filter = m.issueFilter()
+		m.trashIndex, cmd = m.trashIndex.Update(msg)
+		return m, cmd
+	}
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+			return m, nil
+		case key.Matches(msg, keys.TrashRestore):
+			selectedItem := m.trashIndex.SelectedItem()
+			if selectedItem == nil {
+				return m, nil
+			}
+			return m, restoreIssue(selectedItem.(deletedIssue).Issue, m.store)
+		case key.Matches(msg, keys.TrashPurge):
+			if m.trashIndex.SelectedItem() == nil {
+				return m, nil
+			}
+			m.path = trashPurgeConfirmationPath
+			return m, nil
+		case key.Matches(msg, keys.NextPage):
+			m.path = inboxIndexPath
+			return m, m.loadInbox()
+		case key.Matches(msg, keys.PrevPage):
+			m.path = actionsIndexPath
+			return m, nil
+		}
+	}
+
+	m.trashIndex, cmd = m.trashIndex.Update(msg)
+	return m, cmd
+}
+
+func trashPurgeHandler(m Model, msg tea.Msg) (Model, tea.Cmd) {
+	keys := m.HelpKeys()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.TrashConfirmPurge):
+			selectedItem := m.trashIndex.SelectedItem()
+			m.path = trashIndexPath
+			if selectedItem == nil {
+				return m, nil
+			}
+			return m, purgeIssue(selectedItem.(deletedIssue).Issue, m.store)
+		case key.Matches(msg, keys.Back):
+			m.path = trashIndexPath
+			return m, nil
+		}
+	}
+
+	return m, nil
+}
+
+func (m Model) renderTrashView() string {
+	left := m.trashIndex.View()
+	if len(m.trashIndex.Items()) == 0 {
+		left = lipgloss.NewStyle().Foreground(styles.Theme.FaintText).Render("Trash is empty.")
+	}
+	return m.renderMainLayout(m.renderTabs("Trash"), left, "", m.footerView())
+}
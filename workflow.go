@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+)
+
+// A repo can replace the built-in statuses with its own in
+// .ubik/workflow.json, e.g.
+//
+//	{
+//	  "statuses": [
+//	    {"name": "todo", "icon": "[·]", "color": "secondary"},
+//	    {"name": "review", "icon": "[?]", "color": "yellow", "key": "v"},
+//	    {"name": "done", "icon": "[✓]", "color": "green", "terminal": true, "key": " "}
+//	  ],
+//	  "transitions": {"todo": ["review"], "review": ["todo", "done"]}
+//	}
+//
+// The first status is the one new issues start in, and the one a status key
+// toggles back to. Terminal statuses count as closed. Statuses missing from
+// transitions can move anywhere. Colors are theme names (primary, secondary,
+// faint, green, yellow, red) or anything lipgloss accepts.
+const workflowPath = ".ubik/workflow.json"
+
+type workflowStatus struct {
+	Name     issueStatus `json:"name"`
+	Icon     string      `json:"icon"`
+	Color    string      `json:"color"`
+	Terminal bool        `json:"terminal"`
+	Key      string      `json:"key,omitempty"`
+}
+
+type Workflow struct {
+	Statuses    []workflowStatus              `json:"statuses"`
+	Transitions map[issueStatus][]issueStatus `json:"transitions,omitempty"`
+	// Done is what an issue fixed by a commit is moved to; it defaults to the
+	// first terminal status.
+	Done issueStatus `json:"done,omitempty"`
+	// Duplicate is what an issue marked duplicate-of is moved to; it
+	// defaults to the last terminal status.
+	Duplicate issueStatus `json:"duplicate,omitempty"`
+}
+
+func defaultWorkflow() Workflow {
+	return Workflow{
+		Statuses: []workflowStatus{
+			{Name: todo, Icon: "[·]", Color: "secondary"},
+			{Name: inProgress, Icon: "[⋯]", Color: "yellow", Key: "p"},
+			{Name: done, Icon: "[✓]", Color: "green", Terminal: true, Key: " "},
+			{Name: wontDo, Icon: "[×]", Color: "red", Terminal: true, Key: "w"},
+		},
+		Done:      done,
+		Duplicate: wontDo,
+	}
+}
+
+// reservedKeys are bound to something else on the issue screens and can't
+// be given to a status.
+var reservedKeys = []string{
+	"?", "/", "q", "ctrl+c", "ctrl+z", "esc", "enter", "tab", "backspace",
+	"up", "down", "left", "right", "k", "j", "g", "G",
+	"n", "e", "c", "h", "o", "t", "f", "x", "a", "A", "S", "E", "r", "[", "]", "+", "`",
+}
+
+var errInvalidWorkflow = errors.New("invalid workflow")
+
+func (w Workflow) validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", errInvalidWorkflow, fmt.Sprintf(format, args...))
+	}
+
+	if len(w.Statuses) == 0 {
+		return invalid("no statuses")
+	}
+
+	var names []issueStatus
+	var keys []string
+	for _, status := range w.Statuses {
+		switch {
+		case status.Name == "" || strings.ContainsAny(string(status.Name), " \n"):
+			return invalid("status names can't be empty or contain spaces")
+		case slices.Contains(names, status.Name):
+			return invalid("status %q is listed twice", status.Name)
+		case status.Key != "" && slices.Contains(keys, status.Key):
+			return invalid("key %q is used by two statuses", status.Key)
+		case slices.Contains(reservedKeys, status.Key):
+			return invalid("key %q is already taken", status.Key)
+		}
+		names = append(names, status.Name)
+		if status.Key != "" {
+			keys = append(keys, status.Key)
+		}
+	}
+
+	if w.Statuses[0].Terminal {
+		return invalid("the first status, %q, can't be terminal", w.Statuses[0].Name)
+	}
+	if !slices.ContainsFunc(w.Statuses, func(s workflowStatus) bool { return s.Terminal }) {
+		return invalid("at least one status has to be terminal")
+	}
+	for _, special := range []issueStatus{w.Done, w.Duplicate} {
+		if special != "" && !w.status(special).Terminal {
+			return invalid("%q has to be a terminal status", special)
+		}
+	}
+	for from, targets := range w.Transitions {
+		for _, status := range append([]issueStatus{from}, targets...) {
+			if !slices.Contains(names, status) {
+				return invalid("transition mentions unknown status %q", status)
+			}
+		}
+	}
+
+	return nil
+}
+
+// readWorkflow reads the workflow at path, falling back to the default when
+// there isn't one.
+func readWorkflow(path string) (Workflow, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultWorkflow(), nil
+	}
+	if err != nil {
+		return defaultWorkflow(), err
+	}
+
+	var w Workflow
+	err = json.Unmarshal(data, &w)
+	if err != nil {
+		return defaultWorkflow(), fmt.Errorf("%w: %v", errInvalidWorkflow, err)
+	}
+	err = w.validate()
+	if err != nil {
+		return defaultWorkflow(), err
+	}
+
+	terminal := slices.DeleteFunc(slices.Clone(w.Statuses), func(s workflowStatus) bool { return !s.Terminal })
+	if w.Done == "" {
+		w.Done = terminal[0].Name
+	}
+	if w.Duplicate == "" {
+		w.Duplicate = terminal[len(terminal)-1].Name
+	}
+
+	return w, nil
+}
+
+type workflowLoadedMsg struct {
+	Workflow Workflow
+	Err      error
+}
+
+// repoConfigPath finds one of the .ubik/ files in repo's worktree.
+func repoConfigPath(repo *git.Repository, name string) string {
+	if repo != nil {
+		if worktree, err := repo.Worktree(); err == nil {
+			return filepath.Join(worktree.Filesystem.Root(), name)
+		}
+	}
+	return name
+}
+
+func loadWorkflow(repo *git.Repository) tea.Cmd {
+	return func() tea.Msg {
+		w, err := readWorkflow(repoConfigPath(repo, workflowPath))
+		if err != nil {
+			debug("%#v", err.Error())
+		}
+		return workflowLoadedMsg{Workflow: w, Err: err}
+	}
+}
+
+// status looks up a status by name. Statuses the workflow doesn't know about,
+// e.g. from before it changed, are shown as a faint "[?]" and count as open.
+func (w Workflow) status(name issueStatus) workflowStatus {
+	i := slices.IndexFunc(w.Statuses, func(s workflowStatus) bool { return s.Name == name })
+	if i < 0 {
+		return workflowStatus{Name: name, Icon: "[?]", Color: "faint"}
+	}
+	return w.Statuses[i]
+}
+
+func (w Workflow) initial() issueStatus {
+	return w.Statuses[0].Name
+}
+
+func (w Workflow) canMove(from, to issueStatus) bool {
+	targets, ok := w.Transitions[from]
+	return !ok || from == to || slices.Contains(targets, to)
+}
+
+// toggle works out where a status key moves an issue: into the key's status,
+// or back to the initial one if it's already there.
+func (w Workflow) toggle(current, target issueStatus) (issueStatus, error) {
+	next := target
+	if current == target {
+		next = w.initial()
+	}
+	if !w.canMove(current, next) {
+		return current, fmt.Errorf("can't move from %s to %s", current, next)
+	}
+	return next, nil
+}
+
+func (w Workflow) keyBindings() []key.Binding {
+	var bindings []key.Binding
+	for _, status := range w.Statuses {
+		if status.Key == "" {
+			continue
+		}
+		help := status.Key
+		if help == " " {
+			help = "space"
+		}
+		bindings = append(bindings, key.NewBinding(
+			key.WithKeys(status.Key),
+			key.WithHelp(help, fmt.Sprintf("toggle %s", status.Name)),
+		))
+	}
+	return bindings
+}
+
+// statusForKey returns the status bound to the pressed key, if any.
+func (w Workflow) statusForKey(msg tea.KeyMsg) (issueStatus, bool) {
+	for _, status := range w.Statuses {
+		if status.Key != "" && msg.String() == status.Key {
+			return status.Name, true
+		}
+	}
+	return "", false
+}
+
+func (w Workflow) isClosed(s issueStatus) bool {
+	return w.status(s).Terminal
+}
+
+func (w Workflow) icon(s issueStatus) string {
+	return lipgloss.NewStyle().Foreground(w.color(s)).Render(w.status(s).Icon)
+}
+
+func (w Workflow) prettyString(s issueStatus) string {
+	return lipgloss.NewStyle().Foreground(w.color(s)).Render(string(s))
+}
+
+func (w Workflow) color(s issueStatus) lipgloss.TerminalColor {
+	color := w.status(s).Color
+	themeColors := map[string]lipgloss.AdaptiveColor{
+		"primary":   styles.Theme.PrimaryText,
+		"secondary": styles.Theme.SecondaryText,
+		"faint":     styles.Theme.FaintText,
+		"green":     styles.Theme.GreenText,
+		"yellow":    styles.Theme.YellowText,
+		"red":       styles.Theme.RedText,
+	}
+	if themeColor, ok := themeColors[color]; ok {
+		return themeColor
+	}
+	return lipgloss.Color(color)
+}
+
+// changeStatus applies a status key to the selected issue, flashing instead
+// when the workflow doesn't allow the move.
+func (m *Model) changeStatus(target issueStatus) (Issue, bool) {
+	currentIssue := m.issueIndex.SelectedItem().(Issue)
+	next, err := m.workflow.toggle(currentIssue.Status, target)
+	if err != nil {
+		m.flash = err.Error()
+		m.UpdateLayout(m.layout.TerminalSize)
+		return currentIssue, false
+	}
+	currentIssue.Status = next
+	m.warnOpenBlockers(currentIssue)
+	return currentIssue, true
+}
+
+// statusKeyRows lays the status keys out two to a row for the full help.
+func statusKeyRows(bindings []key.Binding) [][]key.Binding {
+	var rows [][]key.Binding
+	for chunk := range slices.Chunk(bindings, 2) {
+		rows = append(rows, chunk)
+	}
+	return rows
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reviewWorkflow = `{
+  "statuses": [
+    {"name": "todo", "icon": "[ ]", "color": "secondary"},
+    {"name": "review", "icon": "[r]", "color": "#ff8800", "key": "v"},
+    {"name": "shipped", "icon": "[s]", "color": "green", "terminal": true, "key": " "},
+    {"name": "dropped", "icon": "[d]", "color": "red", "terminal": true, "key": "w"}
+  ],
+  "transitions": {"todo": ["review", "dropped"]}
+}`
+
+func TestReadWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workflow.json")
+
+	w, err := readWorkflow(path)
+	require.NoError(t, err)
+	assert.Equal(t, defaultWorkflow(), w, "no file means the default workflow")
+
+	require.NoError(t, os.WriteFile(path, []byte(reviewWorkflow), 0o644))
+	w, err = readWorkflow(path)
+	require.NoError(t, err)
+	assert.Equal(t, issueStatus("todo"), w.initial())
+	assert.Equal(t, issueStatus("shipped"), w.Done)
+	assert.Equal(t, issueStatus("dropped"), w.Duplicate)
+
+	tests := map[string]string{
+		"no terminal status": `{"statuses": [{"name": "todo"}]}`,
+		"reserved key":       `{"statuses": [{"name": "todo"}, {"name": "done", "terminal": true, "key": "n"}]}`,
+		"shared key":         `{"statuses": [{"name": "todo", "key": "v"}, {"name": "done", "terminal": true, "key": "v"}]}`,
+		"unknown transition": `{"statuses": [{"name": "todo"}, {"name": "done", "terminal": true}], "transitions": {"todo": ["qa"]}}`,
+		"not json":           `statuses: [todo, done]`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			w, err := readWorkflow(path)
+			assert.ErrorIs(t, err, errInvalidWorkflow)
+			assert.Equal(t, defaultWorkflow(), w)
+		})
+	}
+}
+
+func TestWorkflowToggle(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workflow.json")
+	require.NoError(t, os.WriteFile(path, []byte(reviewWorkflow), 0o644))
+	w, err := readWorkflow(path)
+	require.NoError(t, err)
+
+	next, err := w.toggle("todo", "review")
+	require.NoError(t, err)
+	assert.Equal(t, issueStatus("review"), next)
+
+	next, err = w.toggle("review", "review")
+	require.NoError(t, err)
+	assert.Equal(t, issueStatus("todo"), next, "toggling again goes back to the first status")
+
+	_, err = w.toggle("todo", "shipped")
+	assert.ErrorContains(t, err, "can't move from todo to shipped")
+}
+
+func TestWorkflowDrivesStatusHandlers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workflow.json")
+	require.NoError(t, os.WriteFile(path, []byte(reviewWorkflow), 0o644))
+	w, err := readWorkflow(path)
+	require.NoError(t, err)
+
+	m, store := newTestModel(t, testIssue("one", "First"))
+	m = update(t, m, workflowLoadedMsg{Workflow: w})
+
+	m, _ = press(t, m, " ")
+	assert.Contains(t, m.flash, "can't move from todo to shipped")
+
+	m, cmd := press(t, m, "v")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, issueStatus("review"), storedIssue(t, store, "one").Status)
+	assert.Contains(t, m.issueIndex.View(), "[r]")
+
+	m, cmd = press(t, m, " ")
+	m, _ = deliver(t, m, cmd)
+	assert.Equal(t, issueStatus("shipped"), storedIssue(t, store, "one").Status)
+	assert.True(t, m.workflow.isClosed(m.issueIndex.SelectedItem().(Issue).Status))
+
+	m.help.ShowAll = true
+	assert.Contains(t, m.View(), "toggle review")
+}
+
+func TestReservedKeysCoverIssueBindings(t *testing.T) {
+	otherTabBindings := []string{"Milestone", "Commit", "RunAction", "Trash", "Inbox", "GarbageCollectConfirm"}
+	m, _ := newTestModel(t)
+	keys := reflect.ValueOf(m.HelpKeys())
+	for i := 0; i < keys.NumField(); i++ {
+		name := keys.Type().Field(i).Name
+		binding, ok := keys.Field(i).Interface().(key.Binding)
+		// bound on other tabs, where status keys don't apply
+		otherTab := slices.ContainsFunc(otherTabBindings, func(prefix string) bool {
+			return strings.HasPrefix(name, prefix)
+		})
+		if !ok || otherTab {
+			continue
+		}
+		for _, k := range binding.Keys() {
+			assert.Contains(t, reservedKeys, k, "%s is bound to %q on the issue screens", name, k)
+		}
+	}
+}